@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -41,26 +43,43 @@ func main() {
 
 	// Init clients
 	exchangeRateClient := clients.NewExchangeRateAPIClient()
-	mailClient := clients.NewMailClient()
+	mailer := clients.NewMailer()
+	blobStorage := clients.NewLocalBlobStorage()
 	log.Println("👍 [4] Clients initiated successfully")
 
 	// Feature flags
 	legalComplianceEnabled := strings.ToLower(os.Getenv("LEGAL_COMPLIANCE_ENABLED")) != "false"
+	emailVerificationRequired := strings.ToLower(os.Getenv("EMAIL_VERIFICATION_REQUIRED")) != "false"
 
 	// Init services
 	healthService := services.NewHealthService(db)
-	mailService := services.NewMailService(mailClient)
+	mailService := services.NewMailService(mailer)
 	legalDocumentService := services.NewLegalDocumentService(db, legalComplianceEnabled)
-	userService := services.NewUserService(db, mailService, legalDocumentService)
-	tokenMaker := token.NewJWTMaker()
+	outboxService := services.NewOutboxService(db, mailService)
+	userService := services.NewUserService(db, mailService, outboxService, legalDocumentService)
+	signingKeyService := services.NewSigningKeyService(db)
+	if err := signingKeyService.EnsureSigningKey(context.Background()); err != nil {
+		log.Fatalf("⛔ Exit!!! Cannot load JWT signing keys: %v", err)
+	}
+	tokenMaker := token.NewJWTMaker(signingKeyService)
 	sessionService := services.NewSessionService(db)
+	authThrottleService := services.NewAuthThrottleService(services.NewAttemptStore(db), userService)
+	mfaService := services.NewMFAService(db)
+	passkeyService := services.NewPasskeyService(db)
+	oidcService := services.NewOIDCService(db)
+	personalAccessTokenService := services.NewPersonalAccessTokenService(db)
 	settingService := services.NewSettingService(db)
 	currencyService := services.NewCurrencyService(db, exchangeRateClient)
 	categoryService := services.NewCategoryService(db, settingService, currencyService)
 	recordService := services.NewRecordService(db, settingService, categoryService, currencyService)
 	paymentMethodService := services.NewPaymentMethodService(db)
-	exportService := services.NewExportService(db, settingService, legalComplianceEnabled)
 	trendReportService := services.NewTrendReportService(db, settingService, currencyService)
+	statementService := services.NewStatementService(db, settingService, categoryService, currencyService, trendReportService)
+	backupService := services.NewBackupService(db)
+	exportService := services.NewExportService(db, settingService, statementService, backupService, legalComplianceEnabled)
+	exportJobService := services.NewExportJobService(db, exportService, mailService, blobStorage)
+	exportScheduleService := services.NewExportScheduleService(db, exportService, exportJobService, mailService)
+	digestService := services.NewDigestService(db, categoryService, settingService, currencyService, mailService)
 	log.Println("👍 [5] All services initiated successfully")
 
 	// Start background jobs
@@ -72,10 +91,25 @@ func main() {
 	resetTokenCleanupJob.Start()
 	accountDeletionJob := jobs.NewAccountDeletionJob(userService, 24*time.Hour)
 	accountDeletionJob.Start()
+	exportJobWorker := jobs.NewExportJobWorker(exportJobService, 10*time.Second)
+	exportJobWorker.Start()
+	exportCleanupJob := jobs.NewExportCleanupJob(exportJobService, time.Hour)
+	exportCleanupJob.Start()
+	scheduledExportJob := jobs.NewScheduledExportJob(exportScheduleService, time.Hour)
+	scheduledExportJob.Start()
+	digestJob := jobs.NewDigestJob(digestService, time.Hour)
+	digestJob.Start()
+	outboxWorker := jobs.NewOutboxWorker(outboxService, 10*time.Second)
+	outboxWorker.Start()
+	outboxCleanupJob := jobs.NewOutboxCleanupJob(outboxService, 24*time.Hour)
+	outboxCleanupJob.Start()
+	authAttemptCleanupJob := jobs.NewAuthAttemptCleanupJob(authThrottleService, time.Hour)
+	authAttemptCleanupJob.Start()
 	log.Println("👍 [6] Background jobs started successfully")
 
 	// Init new echo client
 	e := echo.New()
+	e.IPExtractor = server.IPExtractor()
 	log.Println("👍 [7] New Echo HTTP client initiated successfully")
 
 	// Init middlewares
@@ -94,21 +128,41 @@ func main() {
 		MaxAge:           300,
 	}))
 
+	middlewares.SetTokenMaker(tokenMaker)
+	middlewares.EnablePersonalAccessTokens(personalAccessTokenService)
+	middlewares.EnableAccessTokenRevocation(sessionService)
+
 	var restrictedMiddlewares []echo.MiddlewareFunc
 	if legalComplianceEnabled {
 		restrictedMiddlewares = append(restrictedMiddlewares, middlewares.LegalComplianceMiddleware(legalDocumentService))
 	}
+	// Features that email the account, or export and restore all of its data,
+	// need a verified address
+	verifiedMiddlewares := slices.Clone(restrictedMiddlewares)
+	if emailVerificationRequired {
+		verifiedMiddlewares = append(verifiedMiddlewares, middlewares.VerifiedEmailMiddleware(userService))
+	}
 	log.Println("👍 [8] All middlewares initiated successfully")
 
 	// Register handlers and routes
 	handlers.RegisterHealthHandler(e, healthService)
-	handlers.RegisterAuthHandler(e, userService, tokenMaker, sessionService, legalDocumentService)
-	handlers.RegisterUserHandler(e, userService, exportService, restrictedMiddlewares...)
+	handlers.RegisterJWKSHandler(e, signingKeyService)
+	handlers.RegisterAuthHandler(e, userService, tokenMaker, sessionService, legalDocumentService, mfaService, passkeyService, oidcService, authThrottleService)
+	handlers.RegisterMFAHandler(e, userService, mfaService, authThrottleService)
+	handlers.RegisterPasskeyHandler(e, userService, passkeyService)
+	handlers.RegisterOIDCHandler(e, oidcService)
+	handlers.RegisterPersonalAccessTokenHandler(e, personalAccessTokenService, restrictedMiddlewares...)
+	handlers.RegisterUserHandler(e, userService, exportService, backupService, restrictedMiddlewares, verifiedMiddlewares)
 	handlers.RegisterRecordHandler(e, recordService, restrictedMiddlewares...)
 	handlers.RegisterPaymentMethodHandler(e, paymentMethodService, restrictedMiddlewares...)
 	handlers.RegisterCategoryHandler(e, categoryService, restrictedMiddlewares...)
 	handlers.RegisterSettingHandler(e, settingService, restrictedMiddlewares...)
 	handlers.RegisterTrendReportHandler(e, trendReportService, restrictedMiddlewares...)
+	handlers.RegisterStatementHandler(e, statementService, restrictedMiddlewares...)
+	handlers.RegisterExportJobHandler(e, exportJobService, verifiedMiddlewares...)
+	handlers.RegisterExportScheduleHandler(e, exportScheduleService, verifiedMiddlewares...)
+	handlers.RegisterDigestHandler(e, digestService, verifiedMiddlewares...)
+	handlers.RegisterOutboxHandler(e, outboxService, userService)
 	if legalComplianceEnabled {
 		handlers.RegisterLegalDocumentHandler(e, legalDocumentService)
 	}
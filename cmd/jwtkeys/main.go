@@ -0,0 +1,131 @@
+// Command jwtkeys manages the keys that sign JWTs.
+//
+//	jwtkeys list
+//	jwtkeys rotate [-algorithm EdDSA|ES256] [-activate-in 10m]
+//	jwtkeys retire <kid>
+//
+// rotate retires the keys no valid token can be signed with anymore and adds
+// a key that starts signing after -activate-in. Until then it is already
+// published in the JWKS and accepted for verification. retire drops a key
+// right away, for example after it leaked, which signs out its tokens.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/database"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/token"
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage:
+  jwtkeys list
+  jwtkeys rotate [-algorithm EdDSA|ES256] [-activate-in 10m]
+  jwtkeys retire <kid>
+`
+
+func main() {
+	if err := godotenv.Load(); err != nil && os.Getenv("APP_ENV") == "" {
+		log.Fatal("⛔ Exit!!! Error loading .env file")
+	}
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	db := database.ConnectDB()
+	database.Migrate(db)
+	signingKeyService := services.NewSigningKeyService(db)
+	ctx := context.Background()
+
+	var err error
+	switch os.Args[1] {
+	case "list":
+		err = list(ctx, signingKeyService)
+	case "rotate":
+		err = rotate(ctx, signingKeyService, os.Args[2:])
+	case "retire":
+		if len(os.Args) != 3 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		err = retire(ctx, signingKeyService, os.Args[2])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("⛔ Exit!!! %v", err)
+	}
+}
+
+func list(ctx context.Context, signingKeyService *services.SigningKeyService) error {
+	keys, err := signingKeyService.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALGORITHM\tCREATED\tACTIVATES\tRETIRED")
+	for _, key := range keys {
+		retired := "-"
+		if key.RetiredAt != nil {
+			retired = key.RetiredAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.Kid, key.Algorithm,
+			key.CreatedAt.Format(time.RFC3339), key.ActivatesAt.Format(time.RFC3339), retired)
+	}
+	return w.Flush()
+}
+
+func rotate(ctx context.Context, signingKeyService *services.SigningKeyService, args []string) error {
+	flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+	algorithm := flags.String("algorithm", "", "EdDSA or ES256 (defaults to JWT_SIGNING_ALGORITHM or EdDSA)")
+	activateIn := flags.Duration("activate-in", services.DefaultSigningKeyActivationDelay, "delay before the new key signs tokens")
+	_ = flags.Parse(args)
+
+	if *algorithm != "" && !token.IsValidSigningAlgorithm(*algorithm) {
+		return fmt.Errorf("unsupported algorithm %q", *algorithm)
+	}
+	if *activateIn < 0 {
+		return errors.New("activate-in cannot be negative")
+	}
+
+	accessDuration, refreshDuration := token.TokenDurations()
+	retired, err := signingKeyService.RetireSupersededKeys(ctx, max(accessDuration, refreshDuration))
+	if err != nil {
+		return fmt.Errorf("failed to retire superseded keys: %w", err)
+	}
+
+	key, err := signingKeyService.Create(ctx, *algorithm, *activateIn)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Retired %d superseded key(s)\n", retired)
+	fmt.Printf("Added %s key %s, signing from %s\n", key.Algorithm, key.Kid, key.ActivatesAt.Format(time.RFC3339))
+	return nil
+}
+
+func retire(ctx context.Context, signingKeyService *services.SigningKeyService, kid string) error {
+	if err := signingKeyService.Retire(ctx, kid); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("no active key %s", kid)
+		}
+		return err
+	}
+
+	fmt.Printf("Retired key %s, instances stop accepting it within %s\n", kid, services.SigningKeyReloadInterval)
+	return nil
+}
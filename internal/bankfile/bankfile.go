@@ -0,0 +1,36 @@
+package bankfile
+
+import "time"
+
+type Transaction struct {
+	// ID must stay the same across exports so importers can skip transactions
+	// they have already seen. It is written as the OFX FITID.
+	ID       string
+	Date     time.Time
+	Amount   float64
+	Payee    string
+	Memo     string
+	Category string
+}
+
+// Statement holds the transactions of a single account in a single currency,
+// since neither OFX nor QIF supports mixed currencies in one account.
+type Statement struct {
+	AccountID   string
+	AccountName string
+	Currency    string
+	StartDate   time.Time
+	EndDate     time.Time
+	Balance     float64
+	// EachTransaction calls fn for every transaction in date order, so the
+	// transactions are streamed rather than held in memory.
+	EachTransaction func(fn func(t Transaction) error) error
+}
+
+// eachTransaction is EachTransaction for statements that may have none.
+func (st Statement) eachTransaction(fn func(t Transaction) error) error {
+	if st.EachTransaction == nil {
+		return nil
+	}
+	return st.EachTransaction(fn)
+}
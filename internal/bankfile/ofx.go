@@ -0,0 +1,100 @@
+package bankfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ofxBankID       = "MONEXA"
+	ofxMaxNameRunes = 32
+	ofxMaxMemoRunes = 255
+)
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// WriteOFX writes the statement as an OFX 1.0.2 bank statement response, the
+// dialect most personal finance applications import.
+func WriteOFX(w io.Writer, st Statement, generatedAt time.Time) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("OFXHEADER:100\r\n" +
+		"DATA:OFXSGML\r\n" +
+		"VERSION:102\r\n" +
+		"SECURITY:NONE\r\n" +
+		"ENCODING:UTF-8\r\n" +
+		"CHARSET:NONE\r\n" +
+		"COMPRESSION:NONE\r\n" +
+		"OLDFILEUID:NONE\r\n" +
+		"NEWFILEUID:NONE\r\n\r\n")
+
+	bw.WriteString("<OFX>\r\n")
+	bw.WriteString("<SIGNONMSGSRSV1><SONRS>\r\n")
+	bw.WriteString("<STATUS><CODE>0<SEVERITY>INFO</STATUS>\r\n")
+	fmt.Fprintf(bw, "<DTSERVER>%s\r\n", ofxDateTime(generatedAt))
+	bw.WriteString("<LANGUAGE>ENG\r\n")
+	bw.WriteString("</SONRS></SIGNONMSGSRSV1>\r\n")
+
+	bw.WriteString("<BANKMSGSRSV1><STMTTRNRS>\r\n")
+	bw.WriteString("<TRNUID>0\r\n")
+	bw.WriteString("<STATUS><CODE>0<SEVERITY>INFO</STATUS>\r\n")
+	bw.WriteString("<STMTRS>\r\n")
+	fmt.Fprintf(bw, "<CURDEF>%s\r\n", st.Currency)
+	fmt.Fprintf(bw, "<BANKACCTFROM><BANKID>%s<ACCTID>%s<ACCTTYPE>CHECKING</BANKACCTFROM>\r\n", ofxBankID, ofxText(st.AccountID, 22))
+
+	bw.WriteString("<BANKTRANLIST>\r\n")
+	fmt.Fprintf(bw, "<DTSTART>%s\r\n", ofxDate(st.StartDate))
+	fmt.Fprintf(bw, "<DTEND>%s\r\n", ofxDate(st.EndDate))
+	err := st.eachTransaction(func(t Transaction) error {
+		trnType := "CREDIT"
+		if t.Amount < 0 {
+			trnType = "DEBIT"
+		}
+		bw.WriteString("<STMTTRN>\r\n")
+		fmt.Fprintf(bw, "<TRNTYPE>%s\r\n", trnType)
+		fmt.Fprintf(bw, "<DTPOSTED>%s\r\n", ofxDate(t.Date))
+		fmt.Fprintf(bw, "<TRNAMT>%s\r\n", strconv.FormatFloat(t.Amount, 'f', 2, 64))
+		fmt.Fprintf(bw, "<FITID>%s\r\n", ofxText(t.ID, 255))
+		if t.Payee != "" {
+			fmt.Fprintf(bw, "<NAME>%s\r\n", ofxText(t.Payee, ofxMaxNameRunes))
+		}
+		if t.Memo != "" {
+			fmt.Fprintf(bw, "<MEMO>%s\r\n", ofxText(t.Memo, ofxMaxMemoRunes))
+		}
+		_, err := bw.WriteString("</STMTTRN>\r\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString("</BANKTRANLIST>\r\n")
+
+	fmt.Fprintf(bw, "<LEDGERBAL><BALAMT>%s<DTASOF>%s</LEDGERBAL>\r\n", strconv.FormatFloat(st.Balance, 'f', 2, 64), ofxDate(st.EndDate))
+	bw.WriteString("</STMTRS>\r\n")
+	bw.WriteString("</STMTTRNRS></BANKMSGSRSV1>\r\n")
+	bw.WriteString("</OFX>\r\n")
+
+	return bw.Flush()
+}
+
+func ofxDate(t time.Time) string {
+	return t.Format("20060102")
+}
+
+func ofxDateTime(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+// ofxText escapes SGML markup characters and truncates the value to the maximum
+// length the OFX specification allows for the element.
+func ofxText(value string, maxRunes int) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if runes := []rune(value); len(runes) > maxRunes {
+		value = string(runes[:maxRunes])
+	}
+	return ofxEscaper.Replace(value)
+}
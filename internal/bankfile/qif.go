@@ -0,0 +1,49 @@
+package bankfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteQIF writes the statement as a QIF bank account. QIF has no transaction
+// ID field, so the stable ID goes into the reference number (N) field.
+func WriteQIF(w io.Writer, st Statement) error {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("!Type:Bank\n")
+	err := st.eachTransaction(func(t Transaction) error {
+		fmt.Fprintf(bw, "D%s\n", t.Date.Format("01/02/2006"))
+		fmt.Fprintf(bw, "T%s\n", strconv.FormatFloat(t.Amount, 'f', 2, 64))
+		fmt.Fprintf(bw, "N%s\n", qifText(t.ID))
+		if t.Payee != "" {
+			fmt.Fprintf(bw, "P%s\n", qifText(t.Payee))
+		}
+		if t.Memo != "" {
+			fmt.Fprintf(bw, "M%s\n", qifText(t.Memo))
+		}
+		if t.Category != "" {
+			fmt.Fprintf(bw, "L%s\n", qifCategory(t.Category))
+		}
+		_, err := bw.WriteString("^\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// qifText keeps values on a single line, since every QIF field is one line.
+func qifText(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// qifCategory escapes the characters QIF gives a special meaning in categories:
+// brackets mark transfers, a colon starts a subcategory and a slash a class.
+func qifCategory(value string) string {
+	return strings.NewReplacer("[", "(", "]", ")", ":", "-", "/", "-").Replace(qifText(value))
+}
@@ -0,0 +1,345 @@
+package charts
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Kind string
+
+const (
+	KindBar  Kind = "bar"
+	KindLine Kind = "line"
+)
+
+func IsValidKind(kind Kind) bool {
+	switch kind {
+	case KindBar, KindLine:
+		return true
+	default:
+		return false
+	}
+}
+
+type Format string
+
+const (
+	FormatSVG Format = "svg"
+	FormatPNG Format = "png"
+)
+
+type Theme string
+
+const (
+	ThemeLight Theme = "light"
+	ThemeDark  Theme = "dark"
+)
+
+func IsValidTheme(theme Theme) bool {
+	switch theme {
+	case ThemeLight, ThemeDark:
+		return true
+	default:
+		return false
+	}
+}
+
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 400
+	MinWidth      = 200
+	MinHeight     = 150
+	MaxWidth      = 2400
+	MaxHeight     = 1600
+)
+
+type Chart struct {
+	Title    string
+	Subtitle string
+	Labels   []string
+	Values   []float64
+	Color    string
+	Kind     Kind
+
+	// FormatValue formats axis tick values. Defaults to a plain integer format.
+	FormatValue func(float64) string
+}
+
+type Options struct {
+	Width  int
+	Height int
+	Theme  Theme
+}
+
+type palette struct {
+	background color.NRGBA
+	text       color.NRGBA
+	muted      color.NRGBA
+	grid       color.NRGBA
+	axis       color.NRGBA
+}
+
+var palettes = map[Theme]palette{
+	ThemeLight: {
+		background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		text:       color.NRGBA{R: 0x1b, G: 0x14, B: 0x3d, A: 0xff},
+		muted:      color.NRGBA{R: 0x6b, G: 0x6e, B: 0x8a, A: 0xff},
+		grid:       color.NRGBA{R: 0xe4, G: 0xe5, B: 0xef, A: 0xff},
+		axis:       color.NRGBA{R: 0xa8, G: 0xaa, B: 0xc2, A: 0xff},
+	},
+	ThemeDark: {
+		background: color.NRGBA{R: 0x25, G: 0x1e, B: 0x4e, A: 0xff},
+		text:       color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		muted:      color.NRGBA{R: 0x77, G: 0x7a, B: 0xb6, A: 0xff},
+		grid:       color.NRGBA{R: 0x3a, G: 0x33, B: 0x66, A: 0xff},
+		axis:       color.NRGBA{R: 0x5c, G: 0x57, B: 0x8f, A: 0xff},
+	},
+}
+
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+type point struct {
+	X, Y float64
+}
+
+type rectShape struct {
+	X, Y, W, H float64
+	Fill       color.NRGBA
+}
+
+type lineShape struct {
+	Points []point
+	Width  float64
+	Stroke color.NRGBA
+}
+
+type polygonShape struct {
+	Points []point
+	Fill   color.NRGBA
+}
+
+type circleShape struct {
+	Center point
+	Radius float64
+	Fill   color.NRGBA
+}
+
+type textShape struct {
+	Pos    point
+	Text   string
+	Size   float64
+	Bold   bool
+	Anchor anchor
+	Fill   color.NRGBA
+}
+
+// scene is the backend-independent result of laying out a chart. Both the SVG
+// and the PNG renderers draw the same scene so the two outputs stay identical.
+type scene struct {
+	Width      int
+	Height     int
+	Background color.NRGBA
+	Shapes     []any
+}
+
+func (s *scene) add(shape any) {
+	s.Shapes = append(s.Shapes, shape)
+}
+
+func normalizeOptions(opts Options) (Options, error) {
+	if opts.Width == 0 {
+		opts.Width = DefaultWidth
+	}
+	if opts.Height == 0 {
+		opts.Height = DefaultHeight
+	}
+	if opts.Theme == "" {
+		opts.Theme = ThemeLight
+	}
+	if opts.Width < MinWidth || opts.Width > MaxWidth {
+		return opts, fmt.Errorf("width must be between %d and %d", MinWidth, MaxWidth)
+	}
+	if opts.Height < MinHeight || opts.Height > MaxHeight {
+		return opts, fmt.Errorf("height must be between %d and %d", MinHeight, MaxHeight)
+	}
+	if !IsValidTheme(opts.Theme) {
+		return opts, errors.New("invalid theme")
+	}
+	return opts, nil
+}
+
+func layout(chart Chart, opts Options) (*scene, error) {
+	if len(chart.Labels) != len(chart.Values) {
+		return nil, errors.New("labels and values must have the same length")
+	}
+	if len(chart.Values) == 0 {
+		return nil, errors.New("chart has no values")
+	}
+	if chart.Kind == "" {
+		chart.Kind = KindBar
+	}
+	if !IsValidKind(chart.Kind) {
+		return nil, errors.New("invalid chart kind")
+	}
+	formatValue := chart.FormatValue
+	if formatValue == nil {
+		formatValue = func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) }
+	}
+
+	colors := palettes[opts.Theme]
+	seriesColor := ParseHexColor(chart.Color, color.NRGBA{R: 0x66, G: 0x69, B: 0xff, A: 0xff})
+
+	width := float64(opts.Width)
+	height := float64(opts.Height)
+	fontSize := math.Max(9, math.Min(18, height/28))
+	titleSize := fontSize * 1.4
+	padding := fontSize * 1.5
+
+	sc := &scene{Width: opts.Width, Height: opts.Height, Background: colors.background}
+
+	top := padding
+	if chart.Title != "" {
+		top += titleSize
+		sc.add(textShape{Pos: point{padding, top}, Text: chart.Title, Size: titleSize, Bold: true, Fill: colors.text})
+		top += fontSize * 0.6
+	}
+	if chart.Subtitle != "" {
+		top += fontSize
+		sc.add(textShape{Pos: point{padding, top}, Text: chart.Subtitle, Size: fontSize, Fill: colors.muted})
+	}
+	top += fontSize * 1.6
+
+	minValue, maxValue := 0.0, 0.0
+	for _, v := range chart.Values {
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+	}
+	ticks := niceTicks(minValue, maxValue, 5)
+	axisMin, axisMax := ticks[0], ticks[len(ticks)-1]
+
+	tickLabels := make([]string, len(ticks))
+	labelWidth := 0.0
+	for i, t := range ticks {
+		tickLabels[i] = formatValue(t)
+		labelWidth = math.Max(labelWidth, estimateTextWidth(tickLabels[i], fontSize))
+	}
+
+	plotLeft := padding + labelWidth + fontSize*0.6
+	plotRight := width - padding
+	plotTop := top
+	plotBottom := height - padding - fontSize*1.2
+	if plotRight-plotLeft < 10 || plotBottom-plotTop < 10 {
+		return nil, errors.New("chart size is too small")
+	}
+
+	yFor := func(v float64) float64 {
+		return plotBottom - (v-axisMin)/(axisMax-axisMin)*(plotBottom-plotTop)
+	}
+
+	for i, t := range ticks {
+		y := yFor(t)
+		gridColor := colors.grid
+		if t == 0 {
+			gridColor = colors.axis
+		}
+		sc.add(lineShape{Points: []point{{plotLeft, y}, {plotRight, y}}, Width: 1, Stroke: gridColor})
+		sc.add(textShape{Pos: point{plotLeft - fontSize*0.6, y + fontSize*0.35}, Text: tickLabels[i], Size: fontSize, Anchor: anchorEnd, Fill: colors.muted})
+	}
+
+	slot := (plotRight - plotLeft) / float64(len(chart.Values))
+	zeroY := yFor(0)
+
+	switch chart.Kind {
+	case KindBar:
+		barWidth := slot * 0.6
+		for i, v := range chart.Values {
+			x := plotLeft + slot*float64(i) + (slot-barWidth)/2
+			y := yFor(v)
+			sc.add(rectShape{X: x, Y: math.Min(y, zeroY), W: barWidth, H: math.Abs(zeroY - y), Fill: seriesColor})
+		}
+	case KindLine:
+		points := make([]point, len(chart.Values))
+		for i, v := range chart.Values {
+			points[i] = point{plotLeft + slot*(float64(i)+0.5), yFor(v)}
+		}
+
+		area := make([]point, 0, len(points)+2)
+		area = append(area, point{points[0].X, zeroY})
+		area = append(area, points...)
+		area = append(area, point{points[len(points)-1].X, zeroY})
+		areaColor := seriesColor
+		areaColor.A = 0x33
+		sc.add(polygonShape{Points: area, Fill: areaColor})
+
+		sc.add(lineShape{Points: points, Width: math.Max(1.5, fontSize/5), Stroke: seriesColor})
+		for _, p := range points {
+			sc.add(circleShape{Center: p, Radius: math.Max(2.5, fontSize/4), Fill: seriesColor})
+		}
+	}
+
+	for i, label := range chart.Labels {
+		x := plotLeft + slot*(float64(i)+0.5)
+		sc.add(textShape{Pos: point{x, plotBottom + fontSize*1.3}, Text: label, Size: fontSize, Anchor: anchorMiddle, Fill: colors.muted})
+	}
+
+	return sc, nil
+}
+
+// niceTicks returns evenly spaced, human friendly tick values covering [min, max].
+func niceTicks(minValue, maxValue float64, count int) []float64 {
+	if minValue == maxValue {
+		maxValue = minValue + 1
+	}
+
+	rawStep := (maxValue - minValue) / float64(count)
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+	step := magnitude
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if m*magnitude >= rawStep {
+			step = m * magnitude
+			break
+		}
+	}
+
+	start := math.Floor(minValue/step) * step
+	end := math.Ceil(maxValue/step) * step
+
+	ticks := make([]float64, 0, count+2)
+	for v := start; v <= end+step/2; v += step {
+		// Snap values like 0.30000000000000004 back onto the step grid.
+		ticks = append(ticks, math.Round(v/step)*step)
+	}
+	return ticks
+}
+
+// estimateTextWidth approximates the rendered width of a string. It is used for
+// layout only, so it only needs to be close enough for both renderers.
+func estimateTextWidth(text string, size float64) float64 {
+	return float64(len([]rune(text))) * size * 0.58
+}
+
+// ParseHexColor parses #RGB and #RRGGBB colors and returns fallback for anything else.
+func ParseHexColor(hex string, fallback color.NRGBA) color.NRGBA {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return fallback
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return color.NRGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xff}
+}
@@ -0,0 +1,187 @@
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// The Go fonts cover Latin and Cyrillic, so labels in both supported languages
+// render without any system fonts installed.
+var (
+	fontsOnce   sync.Once
+	fontsErr    error
+	regularFont *opentype.Font
+	boldFont    *opentype.Font
+)
+
+const circleSegments = 24
+
+type faceKey struct {
+	size float64
+	bold bool
+}
+
+func loadFonts() error {
+	fontsOnce.Do(func() {
+		regularFont, fontsErr = opentype.Parse(goregular.TTF)
+		if fontsErr != nil {
+			return
+		}
+		boldFont, fontsErr = opentype.Parse(gobold.TTF)
+	})
+	return fontsErr
+}
+
+// faceCache holds the faces of a single render. Faces keep internal buffers and
+// must not be shared between goroutines, so the cache is never global.
+type faceCache map[faceKey]font.Face
+
+func (fc faceCache) face(size float64, bold bool) (font.Face, error) {
+	if err := loadFonts(); err != nil {
+		return nil, fmt.Errorf("failed to load chart fonts: %w", err)
+	}
+
+	key := faceKey{size: math.Round(size*4) / 4, bold: bold}
+	if face, ok := fc[key]; ok {
+		return face, nil
+	}
+
+	f := regularFont
+	if bold {
+		f = boldFont
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: key.size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	fc[key] = face
+	return face, nil
+}
+
+func RenderPNG(chart Chart, opts Options) ([]byte, error) {
+	img, err := RenderImage(chart, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode chart png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderImage rasterizes the chart into an in-memory image, e.g. for embedding
+// into other documents.
+func RenderImage(chart Chart, opts Options) (*image.RGBA, error) {
+	opts, err := normalizeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := layout(chart, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	faces := make(faceCache)
+	img := image.NewRGBA(image.Rect(0, 0, sc.Width, sc.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(sc.Background), image.Point{}, draw.Src)
+
+	for _, shape := range sc.Shapes {
+		switch s := shape.(type) {
+		case rectShape:
+			fillPolygon(img, []point{{s.X, s.Y}, {s.X + s.W, s.Y}, {s.X + s.W, s.Y + s.H}, {s.X, s.Y + s.H}}, s.Fill)
+		case lineShape:
+			strokePolyline(img, s.Points, s.Width, s.Stroke)
+		case polygonShape:
+			fillPolygon(img, s.Points, s.Fill)
+		case circleShape:
+			fillCircle(img, s.Center, s.Radius, s.Fill)
+		case textShape:
+			if err := drawText(img, faces, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return img, nil
+}
+
+func fillPolygon(img *image.RGBA, points []point, fill color.NRGBA) {
+	if len(points) < 3 {
+		return
+	}
+
+	bounds := img.Bounds()
+	r := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	r.DrawOp = draw.Over
+	r.MoveTo(float32(points[0].X), float32(points[0].Y))
+	for _, p := range points[1:] {
+		r.LineTo(float32(p.X), float32(p.Y))
+	}
+	r.ClosePath()
+	r.Draw(img, bounds, image.NewUniform(fill), image.Point{})
+}
+
+func fillCircle(img *image.RGBA, center point, radius float64, fill color.NRGBA) {
+	points := make([]point, circleSegments)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / float64(circleSegments)
+		points[i] = point{center.X + radius*math.Cos(angle), center.Y + radius*math.Sin(angle)}
+	}
+	fillPolygon(img, points, fill)
+}
+
+// strokePolyline draws each segment as a quad and caps the joints with circles,
+// which gives round joins without a dedicated stroker.
+func strokePolyline(img *image.RGBA, points []point, width float64, stroke color.NRGBA) {
+	half := width / 2
+	for i := 0; i+1 < len(points); i++ {
+		a, b := points[i], points[i+1]
+		dx, dy := b.X-a.X, b.Y-a.Y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*half, dx/length*half
+		fillPolygon(img, []point{{a.X + nx, a.Y + ny}, {b.X + nx, b.Y + ny}, {b.X - nx, b.Y - ny}, {a.X - nx, a.Y - ny}}, stroke)
+	}
+	if len(points) > 2 {
+		for _, p := range points[1 : len(points)-1] {
+			fillCircle(img, p, half, stroke)
+		}
+	}
+}
+
+func drawText(img *image.RGBA, faces faceCache, s textShape) error {
+	face, err := faces.face(s.Size, s.Bold)
+	if err != nil {
+		return err
+	}
+
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(s.Fill), Face: face}
+	x := fixed.Int26_6(s.Pos.X * 64)
+	switch s.Anchor {
+	case anchorMiddle:
+		x -= drawer.MeasureString(s.Text) / 2
+	case anchorEnd:
+		x -= drawer.MeasureString(s.Text)
+	}
+	drawer.Dot = fixed.Point26_6{X: x, Y: fixed.Int26_6(s.Pos.Y * 64)}
+	drawer.DrawString(s.Text)
+	return nil
+}
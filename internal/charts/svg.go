@@ -0,0 +1,89 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+const svgFontFamily = "'Go', 'DejaVu Sans', Arial, Helvetica, sans-serif"
+
+func RenderSVG(chart Chart, opts Options) ([]byte, error) {
+	opts, err := normalizeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := layout(chart, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="%s">`,
+		sc.Width, sc.Height, sc.Width, sc.Height, svgFontFamily)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, svgColor(sc.Background))
+
+	for _, shape := range sc.Shapes {
+		switch s := shape.(type) {
+		case rectShape:
+			fmt.Fprintf(&buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"%s/>`,
+				s.X, s.Y, s.W, s.H, svgColor(s.Fill), svgOpacity("fill-opacity", s.Fill))
+		case lineShape:
+			fmt.Fprintf(&buf, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%.2f" stroke-linejoin="round" stroke-linecap="round"%s/>`,
+				svgPoints(s.Points), svgColor(s.Stroke), s.Width, svgOpacity("stroke-opacity", s.Stroke))
+		case polygonShape:
+			fmt.Fprintf(&buf, `<polygon points="%s" fill="%s"%s/>`,
+				svgPoints(s.Points), svgColor(s.Fill), svgOpacity("fill-opacity", s.Fill))
+		case circleShape:
+			fmt.Fprintf(&buf, `<circle cx="%.2f" cy="%.2f" r="%.2f" fill="%s"%s/>`,
+				s.Center.X, s.Center.Y, s.Radius, svgColor(s.Fill), svgOpacity("fill-opacity", s.Fill))
+		case textShape:
+			weight := "normal"
+			if s.Bold {
+				weight = "bold"
+			}
+			fmt.Fprintf(&buf, `<text x="%.2f" y="%.2f" font-size="%.2f" font-weight="%s" text-anchor="%s" fill="%s">`,
+				s.Pos.X, s.Pos.Y, s.Size, weight, svgAnchor(s.Anchor), svgColor(s.Fill))
+			if err := xml.EscapeText(&buf, []byte(s.Text)); err != nil {
+				return nil, fmt.Errorf("failed to escape chart text: %w", err)
+			}
+			buf.WriteString(`</text>`)
+		}
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func svgOpacity(attribute string, c color.NRGBA) string {
+	if c.A == 0xff {
+		return ""
+	}
+	return fmt.Sprintf(` %s="%.2f"`, attribute, float64(c.A)/0xff)
+}
+
+func svgPoints(points []point) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = fmt.Sprintf("%.2f,%.2f", p.X, p.Y)
+	}
+	return strings.Join(parts, " ")
+}
+
+func svgAnchor(a anchor) string {
+	switch a {
+	case anchorMiddle:
+		return "middle"
+	case anchorEnd:
+		return "end"
+	default:
+		return "start"
+	}
+}
@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every email as an .eml file to MAIL_DIR instead of
+// delivering it, which is handy during development and in integration tests.
+type FileMailer struct {
+	dir  string
+	from mail.Address
+}
+
+func NewFileMailer() *FileMailer {
+	dir := os.Getenv("MAIL_DIR")
+	if dir == "" {
+		dir = filepath.Join("storage", "mail")
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		log.Fatal("⛔ Exit!!! Cannot create MAIL_DIR")
+	}
+
+	fromName, fromAddress := mailSender()
+	if fromAddress == "" {
+		fromAddress = "no-reply@localhost"
+	}
+
+	return &FileMailer{
+		dir:  dir,
+		from: mail.Address{Name: fromName, Address: fromAddress},
+	}
+}
+
+func (m *FileMailer) Send(message MailMessage) error {
+	now := time.Now()
+	body, err := buildMIMEMessage(m.from, message, now)
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+
+	// The timestamp prefix keeps the files in the order they were sent.
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), randomMessageID()[:8])
+	if err := os.WriteFile(filepath.Join(m.dir, name), body, 0o640); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package clients
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	MailDriverResend = "resend"
+	MailDriverSMTP   = "smtp"
+	MailDriverFile   = "file"
+	MailDriverMemory = "memory"
+)
+
+type MailAttachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// MailMessage is a single email. Either Text or HTML is set as the body.
+type MailMessage struct {
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []MailAttachment
+}
+
+// Mailer delivers email through one of the configured backends.
+type Mailer interface {
+	Send(message MailMessage) error
+}
+
+// NewMailer returns the backend selected by MAIL_DRIVER. Without MAIL_DRIVER,
+// Resend is used when RESEND_API_KEY is set. Otherwise mail is written to
+// files, except in production, which refuses to start without a provider.
+// Keeping mail in memory drops it, so it has to be chosen explicitly and is
+// never allowed in production.
+func NewMailer() Mailer {
+	production := os.Getenv("APP_ENV") == "production"
+
+	driver := strings.ToLower(os.Getenv("MAIL_DRIVER"))
+	if driver == "" {
+		switch {
+		case os.Getenv("RESEND_API_KEY") != "":
+			driver = MailDriverResend
+		case production:
+			log.Fatal("⛔ Exit!!! Missing MAIL_DRIVER or RESEND_API_KEY")
+		default:
+			driver = MailDriverFile
+		}
+	}
+
+	switch driver {
+	case MailDriverResend:
+		return NewResendMailer()
+	case MailDriverSMTP:
+		return NewSMTPMailer()
+	case MailDriverFile:
+		return NewFileMailer()
+	case MailDriverMemory:
+		if production {
+			log.Fatal("⛔ Exit!!! MAIL_DRIVER=memory drops all emails and cannot be used in production")
+		}
+		log.Println("⚠️ Warning!!! Emails are kept in memory and not delivered (MAIL_DRIVER=memory)")
+		return NewMemoryMailer()
+	default:
+		log.Fatalf("⛔ Exit!!! Unknown MAIL_DRIVER %q", driver)
+		return nil
+	}
+}
+
+// mailSender returns the From name and address. MAIL_FROM_* takes precedence
+// over the older RESEND_FROM_* variables.
+func mailSender() (string, string) {
+	name := os.Getenv("MAIL_FROM_NAME")
+	if name == "" {
+		name = os.Getenv("RESEND_FROM_NAME")
+	}
+
+	address := os.Getenv("MAIL_FROM_ADDRESS")
+	if address == "" {
+		address = os.Getenv("RESEND_FROM_ADDRESS")
+	}
+
+	return name, address
+}
+
+// buildMIMEMessage renders the message in RFC 5322 format, as sent over SMTP
+// and stored in .eml files.
+func buildMIMEMessage(from mail.Address, message MailMessage, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+
+	to := make([]string, 0, len(message.To))
+	for _, address := range message.To {
+		to = append(to, (&mail.Address{Address: address}).String())
+	}
+
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from.Address, "@"); ok {
+		domain = d
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("From", from.String())
+	header.Set("To", strings.Join(to, ", "))
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", message.Subject))
+	header.Set("Date", date.Format(time.RFC1123Z))
+	header.Set("Message-ID", fmt.Sprintf("<%s@%s>", randomMessageID(), domain))
+	header.Set("MIME-Version", "1.0")
+
+	contentType, body := "text/plain; charset=utf-8", message.Text
+	if message.HTML != "" {
+		contentType, body = "text/html; charset=utf-8", message.HTML
+	}
+
+	if len(message.Attachments) == 0 {
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writeMIMEHeader(&buf, header)
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	writer := multipart.NewWriter(&buf)
+	header.Set("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", writer.Boundary()))
+	writeMIMEHeader(&buf, header)
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(part, body); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range message.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(part, attachment.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeMIMEHeader(buf *bytes.Buffer, header textproto.MIMEHeader) {
+	for _, key := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if value := header.Get(key); value != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64Lines wraps the encoded content at 76 characters as required by
+// RFC 2045.
+func writeBase64Lines(w io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		if _, err := w.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := w.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+func randomMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
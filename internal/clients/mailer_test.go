@@ -0,0 +1,43 @@
+package clients
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewMailerWritesFilesByDefault(t *testing.T) {
+	t.Setenv("APP_ENV", "")
+	t.Setenv("MAIL_DRIVER", "")
+	t.Setenv("RESEND_API_KEY", "")
+	t.Setenv("MAIL_DIR", t.TempDir())
+
+	if mailer, ok := NewMailer().(*FileMailer); !ok {
+		t.Errorf("NewMailer() = %T, want *FileMailer", mailer)
+	}
+}
+
+func TestNewMailerKeepsMailInMemoryOnlyWhenAsked(t *testing.T) {
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("MAIL_DRIVER", MailDriverMemory)
+
+	if mailer, ok := NewMailer().(*MemoryMailer); !ok {
+		t.Errorf("NewMailer() = %T, want *MemoryMailer", mailer)
+	}
+}
+
+func TestMemoryMailerKeepsTheLastMessages(t *testing.T) {
+	mailer := NewMemoryMailer()
+	for i := range MaxMemoryMessages + 10 {
+		if err := mailer.Send(MailMessage{To: []string{"ana@example.com"}, Subject: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != MaxMemoryMessages {
+		t.Fatalf("kept %d messages, want %d", len(messages), MaxMemoryMessages)
+	}
+	if messages[0].Subject != "10" || messages[len(messages)-1].Subject != fmt.Sprint(MaxMemoryMessages+9) {
+		t.Errorf("kept messages %s to %s, want the last ones", messages[0].Subject, messages[len(messages)-1].Subject)
+	}
+}
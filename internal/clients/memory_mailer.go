@@ -0,0 +1,48 @@
+package clients
+
+import (
+	"slices"
+	"sync"
+)
+
+// MaxMemoryMessages is the number of emails a MemoryMailer keeps, older ones
+// are dropped.
+const MaxMemoryMessages = 1000
+
+// MemoryMailer keeps sent emails in memory so tests can assert on them. It
+// delivers nothing, so it is meant for tests and sandboxes only.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []MailMessage
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(message MailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.messages) == MaxMemoryMessages {
+		m.messages = slices.Delete(m.messages, 0, 1)
+	}
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Messages returns the last MaxMemoryMessages emails sent, oldest first.
+func (m *MemoryMailer) Messages() []MailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]MailMessage(nil), m.messages...)
+}
+
+// Reset forgets all sent emails.
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
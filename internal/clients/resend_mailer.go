@@ -0,0 +1,60 @@
+package clients
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/resend/resend-go/v3"
+)
+
+type ResendMailer struct {
+	client      *resend.Client
+	fromName    string
+	fromAddress string
+}
+
+func NewResendMailer() *ResendMailer {
+	apiKey := os.Getenv("RESEND_API_KEY")
+	if apiKey == "" {
+		log.Fatal("⛔ Exit!!! Missing RESEND_API_KEY")
+	}
+
+	fromName, fromAddress := mailSender()
+	if fromName == "" {
+		log.Fatal("⛔ Exit!!! Missing MAIL_FROM_NAME")
+	}
+	if fromAddress == "" {
+		log.Fatal("⛔ Exit!!! Missing MAIL_FROM_ADDRESS")
+	}
+
+	client := resend.NewClient(apiKey)
+
+	return &ResendMailer{
+		client:      client,
+		fromName:    fromName,
+		fromAddress: fromAddress,
+	}
+}
+
+func (m *ResendMailer) Send(message MailMessage) error {
+	params := &resend.SendEmailRequest{
+		From:    fmt.Sprintf("%s <%s>", m.fromName, m.fromAddress),
+		To:      message.To,
+		Subject: message.Subject,
+		Text:    message.Text,
+		Html:    message.HTML,
+	}
+	for _, attachment := range message.Attachments {
+		params.Attachments = append(params.Attachments, &resend.Attachment{
+			Filename:    attachment.Filename,
+			ContentType: attachment.ContentType,
+			Content:     attachment.Content,
+		})
+	}
+	_, err := m.client.Emails.Send(params)
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package clients
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SMTPSecurityStartTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+	SMTPSecurityNone     = "none"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer delivers mail to an SMTP server. SMTP_SECURITY selects implicit
+// TLS (usually port 465), STARTTLS (usually port 587, the default) or a plain
+// connection for local relays.
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	security string
+	from     mail.Address
+}
+
+func NewSMTPMailer() *SMTPMailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Fatal("⛔ Exit!!! Missing SMTP_HOST")
+	}
+
+	security := strings.ToLower(os.Getenv("SMTP_SECURITY"))
+	if security == "" {
+		security = SMTPSecurityStartTLS
+	}
+	if security != SMTPSecurityStartTLS && security != SMTPSecurityTLS && security != SMTPSecurityNone {
+		log.Fatalf("⛔ Exit!!! Invalid SMTP_SECURITY %q, expected starttls, tls or none", security)
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+		if security == SMTPSecurityTLS {
+			port = "465"
+		}
+	}
+
+	fromName, fromAddress := mailSender()
+	if fromAddress == "" {
+		log.Fatal("⛔ Exit!!! Missing MAIL_FROM_ADDRESS")
+	}
+
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		security: security,
+		from:     mail.Address{Name: fromName, Address: fromAddress},
+	}
+}
+
+func (m *SMTPMailer) Send(message MailMessage) error {
+	body, err := buildMIMEMessage(m.from, message, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to build email: %w", err)
+	}
+
+	client, err := m.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	defer client.Close()
+
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("failed to authenticate with smtp server: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	for _, to := range message.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to send email to %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return client.Quit()
+}
+
+func (m *SMTPMailer) dial() (*smtp.Client, error) {
+	address := net.JoinHostPort(m.host, m.port)
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	tlsConfig := &tls.Config{ServerName: m.host, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	if m.security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// One deadline for the whole conversation keeps a stuck server from
+	// blocking the caller forever.
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if m.security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("smtp server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
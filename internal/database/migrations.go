@@ -561,6 +561,268 @@ func Migrate(db *gorm.DB) {
 				return nil
 			},
 		},
+		{
+			ID: "20261019100000_add_filters_to_trend_reports",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.TrendReport{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("trend_report_payment_methods"); err != nil {
+					return err
+				}
+				for _, column := range []string{"Search", "MinAmount", "MaxAmount"} {
+					if tx.Migrator().HasColumn(&models.TrendReport{}, column) {
+						if err := tx.Migrator().DropColumn(&models.TrendReport{}, column); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261019110000_create_export_jobs_table",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.ExportJob{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.export_jobs
+					ADD CONSTRAINT fk_export_jobs_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("export_jobs")
+			},
+		},
+		{
+			ID: "20261019120000_create_export_schedules_table",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.ExportSchedule{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.export_schedules
+					ADD CONSTRAINT fk_export_schedules_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("export_schedules")
+			},
+		},
+		{
+			ID: "20261019130000_create_digest_subscriptions_table",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.DigestSubscription{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.digest_subscriptions
+					ADD CONSTRAINT fk_digest_subscriptions_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("digest_subscriptions")
+			},
+		},
+		{
+			ID: "20261019140000_create_outbox_emails_table",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.OutboxEmail{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.outbox_emails
+					ADD CONSTRAINT fk_outbox_emails_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("outbox_emails")
+			},
+		},
+		{
+			ID: "20261019150000_create_user_totps_and_recovery_codes_tables",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.UserTOTP{}, &models.RecoveryCode{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.user_totps
+					ADD CONSTRAINT fk_user_totps_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+
+					ALTER TABLE public.recovery_codes
+					ADD CONSTRAINT fk_recovery_codes_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("recovery_codes", "user_totps")
+			},
+		},
+		{
+			ID: "20261019160000_create_passkey_tables",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.PasskeyCredential{}, &models.PasskeyCeremony{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.passkey_credentials
+					ADD CONSTRAINT fk_passkey_credentials_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("passkey_ceremonies", "passkey_credentials")
+			},
+		},
+		{
+			ID: "20261019170000_create_oidc_tables",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.OIDCIdentity{}, &models.OIDCState{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.oidc_identities
+					ADD CONSTRAINT fk_oidc_identities_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("oidc_states", "oidc_identities")
+			},
+		},
+		{
+			ID: "20261019180000_create_personal_access_tokens_table",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.PersonalAccessToken{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.personal_access_tokens
+					ADD CONSTRAINT fk_personal_access_tokens_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("personal_access_tokens")
+			},
+		},
+		{
+			ID: "20261019190000_add_device_info_to_sessions",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Session{}); err != nil {
+					return err
+				}
+				return tx.Exec(`UPDATE public.sessions SET signed_in_at = created_at;`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"SignedInAt", "LastUsedAt", "UserAgent", "IPAddress", "DeviceName"} {
+					if tx.Migrator().HasColumn(&models.Session{}, column) {
+						if err := tx.Migrator().DropColumn(&models.Session{}, column); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261019200000_add_tokens_revoked_at_to_users",
+			Migrate: func(tx *gorm.DB) error {
+				if !tx.Migrator().HasColumn(&models.User{}, "TokensRevokedAt") {
+					return tx.Migrator().AddColumn(&models.User{}, "TokensRevokedAt")
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if tx.Migrator().HasColumn(&models.User{}, "TokensRevokedAt") {
+					return tx.Migrator().DropColumn(&models.User{}, "TokensRevokedAt")
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261019210000_create_signing_keys_table",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.SigningKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("signing_keys")
+			},
+		},
+		{
+			ID: "20261019220000_create_auth_attempts_table",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.AuthAttempt{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("auth_attempts")
+			},
+		},
+		{
+			ID: "20261019230000_add_email_verified_at_to_users",
+			Migrate: func(tx *gorm.DB) error {
+				if !tx.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt") {
+					if err := tx.Migrator().AddColumn(&models.User{}, "EmailVerifiedAt"); err != nil {
+						return err
+					}
+				}
+				// Accounts from before verification existed keep their features.
+				return tx.Exec(`UPDATE public.users SET email_verified_at = created_at WHERE email_verified_at IS NULL;`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if tx.Migrator().HasColumn(&models.User{}, "EmailVerifiedAt") {
+					return tx.Migrator().DropColumn(&models.User{}, "EmailVerifiedAt")
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261020000000_create_email_verification_tokens_table",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.EmailVerificationToken{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.email_verification_tokens
+					ADD CONSTRAINT fk_email_verification_tokens_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("email_verification_tokens")
+			},
+		},
+		{
+			ID: "20261020010000_create_email_change_requests_table",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.EmailChangeRequest{}); err != nil {
+					return err
+				}
+				return tx.Exec(`
+					ALTER TABLE public.email_change_requests
+					ADD CONSTRAINT fk_email_change_requests_user
+					FOREIGN KEY (user_id) REFERENCES public.users(id);
+				`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("email_change_requests")
+			},
+		},
+		{
+			ID: "20261020020000_create_mfa_challenges_table",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.MFAChallenge{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("mfa_challenges")
+			},
+		},
 	})
 
 	if err := m.Migrate(); err != nil {
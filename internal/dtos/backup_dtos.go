@@ -0,0 +1,60 @@
+package dtos
+
+import (
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+type BackupManifest struct {
+	Format        string               `json:"format"`
+	SchemaVersion int                  `json:"schemaVersion"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	Files         []BackupManifestFile `json:"files"`
+}
+
+type BackupManifestFile struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type BackupCategory struct {
+	ID          uint               `json:"id"`
+	Name        string             `json:"name"`
+	Type        types.CategoryType `json:"type"`
+	Description *string            `json:"description"`
+	Color       *string            `json:"color"`
+}
+
+type BackupPaymentMethod struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+type BackupRecord struct {
+	ID              uint               `json:"id"`
+	CategoryID      uint               `json:"categoryId"`
+	PaymentMethodID uint               `json:"paymentMethodId"`
+	Amount          float64            `json:"amount"`
+	Currency        types.CurrencyType `json:"currency"`
+	Description     *string            `json:"description"`
+	Date            time.Time          `json:"date"`
+}
+
+type BackupTrendReport struct {
+	ID               uint     `json:"id"`
+	Title            *string  `json:"title"`
+	Description      *string  `json:"description"`
+	Color            *string  `json:"color"`
+	Search           *string  `json:"search"`
+	MinAmount        *float64 `json:"minAmount"`
+	MaxAmount        *float64 `json:"maxAmount"`
+	CategoryIDs      []uint   `json:"categoryIds"`
+	PaymentMethodIDs []uint   `json:"paymentMethodIds"`
+}
+
+type BackupSettings struct {
+	Language types.LanguageType `json:"language"`
+	Currency types.CurrencyType `json:"currency"`
+}
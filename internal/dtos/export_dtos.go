@@ -43,3 +43,51 @@ type RecordExportRow struct {
 	Date              time.Time          `json:"date"`
 	Description       *string            `json:"description"`
 }
+
+type RecordTotalExportRow struct {
+	Currency types.CurrencyType `json:"currency"`
+	Income   float64            `json:"income"`
+	Expense  float64            `json:"expense"`
+}
+
+type TransactionExportRow struct {
+	ID                uint               `json:"id"`
+	PaymentMethodID   uint               `json:"paymentMethodId"`
+	PaymentMethodName string             `json:"paymentMethod"`
+	CategoryName      string             `json:"category"`
+	CategoryType      types.CategoryType `json:"categoryType"`
+	Amount            float64            `json:"amount"`
+	Currency          types.CurrencyType `json:"currency"`
+	Date              time.Time          `json:"date"`
+	Description       *string            `json:"description"`
+}
+
+// EncryptedExportManifest sits unencrypted next to the payload of a passphrase
+// protected export and describes how to decrypt it.
+type EncryptedExportManifest struct {
+	Format       string                    `json:"format"`
+	Version      int                       `json:"version"`
+	CreatedAt    time.Time                 `json:"createdAt"`
+	Payload      string                    `json:"payload"`
+	ContentType  string                    `json:"contentType"`
+	Encryption   EncryptedExportEncryption `json:"encryption"`
+	Instructions string                    `json:"instructions"`
+}
+
+type EncryptedExportEncryption struct {
+	Scheme string             `json:"scheme"`
+	Cipher string             `json:"cipher"`
+	KDF    EncryptedExportKDF `json:"kdf"`
+}
+
+// EncryptedExportKDF holds the scrypt parameters used to derive the key that
+// wraps the file key. The salt is random per export and stored in the age header.
+type EncryptedExportKDF struct {
+	Algorithm string `json:"algorithm"`
+	LogN      int    `json:"logN"`
+	R         int    `json:"r"`
+	P         int    `json:"p"`
+	SaltBytes int    `json:"saltBytes"`
+	SaltLabel string `json:"saltLabel"`
+	KeyBytes  int    `json:"keyBytes"`
+}
@@ -5,9 +5,31 @@ type ExportFormatType string
 const (
 	ExportFormatCSV  ExportFormatType = "CSV"
 	ExportFormatJSON ExportFormatType = "JSON"
+	ExportFormatPDF  ExportFormatType = "PDF"
+	ExportFormatXLSX ExportFormatType = "XLSX"
+
+	ExportFormatLedger    ExportFormatType = "LEDGER"
+	ExportFormatHledger   ExportFormatType = "HLEDGER"
+	ExportFormatBeancount ExportFormatType = "BEANCOUNT"
+
+	ExportFormatOFX ExportFormatType = "OFX"
+	ExportFormatQIF ExportFormatType = "QIF"
+
+	ExportFormatBackup ExportFormatType = "BACKUP"
 )
 
 var ValidExportFormats = map[ExportFormatType]bool{
 	ExportFormatCSV:  true,
 	ExportFormatJSON: true,
+	ExportFormatPDF:  true,
+	ExportFormatXLSX: true,
+
+	ExportFormatLedger:    true,
+	ExportFormatHledger:   true,
+	ExportFormatBeancount: true,
+
+	ExportFormatOFX: true,
+	ExportFormatQIF: true,
+
+	ExportFormatBackup: true,
 }
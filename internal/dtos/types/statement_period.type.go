@@ -0,0 +1,17 @@
+package types
+
+type StatementPeriodType string
+
+const (
+	StatementPeriodMonthly StatementPeriodType = "MONTHLY"
+	StatementPeriodAnnual  StatementPeriodType = "ANNUAL"
+)
+
+func IsValidStatementPeriod(period StatementPeriodType) bool {
+	switch period {
+	case StatementPeriodMonthly, StatementPeriodAnnual:
+		return true
+	default:
+		return false
+	}
+}
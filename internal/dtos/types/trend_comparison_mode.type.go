@@ -0,0 +1,17 @@
+package types
+
+type TrendComparisonModeType string
+
+const (
+	TrendComparisonYearOverYear   TrendComparisonModeType = "YEAR_OVER_YEAR"
+	TrendComparisonPreviousPeriod TrendComparisonModeType = "PREVIOUS_PERIOD"
+)
+
+func IsValidTrendComparisonMode(mode TrendComparisonModeType) bool {
+	switch mode {
+	case TrendComparisonYearOverYear, TrendComparisonPreviousPeriod:
+		return true
+	default:
+		return false
+	}
+}
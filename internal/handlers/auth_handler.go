@@ -3,6 +3,7 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
 	"github.com/emilijan-koteski/monexa/internal/middlewares"
@@ -21,6 +22,10 @@ type authHandler struct {
 	tokenMaker           *token.JWTMaker
 	sessionService       *services.SessionService
 	legalDocumentService *services.LegalDocumentService
+	mfaService           *services.MFAService
+	passkeyService       *services.PasskeyService
+	oidcService          *services.OIDCService
+	throttleService      *services.AuthThrottleService
 }
 
 func RegisterAuthHandler(
@@ -29,24 +34,41 @@ func RegisterAuthHandler(
 	tokenMaker *token.JWTMaker,
 	sessionService *services.SessionService,
 	legalDocumentService *services.LegalDocumentService,
+	mfaService *services.MFAService,
+	passkeyService *services.PasskeyService,
+	oidcService *services.OIDCService,
+	throttleService *services.AuthThrottleService,
 ) {
 	handler := &authHandler{
 		userService:          userService,
 		tokenMaker:           tokenMaker,
 		sessionService:       sessionService,
 		legalDocumentService: legalDocumentService,
+		mfaService:           mfaService,
+		passkeyService:       passkeyService,
+		oidcService:          oidcService,
+		throttleService:      throttleService,
 	}
 
 	// Unauthenticated group
 	v1 := e.Group("/api/v1/auth")
 
 	v1.POST("/login", handler.Login)
+	v1.POST("/login/mfa", handler.LoginMFA)
+	v1.POST("/passkeys/login/begin", handler.BeginPasskeyLogin)
+	v1.POST("/passkeys/login/finish", handler.FinishPasskeyLogin)
+	v1.GET("/oidc/providers", handler.ReadOIDCProviders)
+	v1.POST("/oidc/:provider/begin", handler.BeginOIDCLogin)
+	v1.POST("/oidc/callback", handler.FinishOIDCLogin)
 	v1.POST("/register", handler.Register)
 	v1.POST("/logout", handler.Logout)
 	v1.POST("/tokens/renew", handler.RenewAccessToken)
 	v1.POST("/sessions/revoke", handler.RevokeSession)
 	v1.POST("/forgot-password", handler.ForgotPassword)
 	v1.POST("/reset-password", handler.ResetPassword)
+	v1.POST("/verify-email", handler.VerifyEmail)
+	v1.POST("/change-email/confirm", handler.ConfirmEmailChange)
+	v1.POST("/change-email/cancel", handler.CancelEmailChange)
 
 	// Restricted group
 	r1 := v1.Group("")
@@ -54,6 +76,12 @@ func RegisterAuthHandler(
 
 	r1.DELETE("/accounts", handler.DeleteAccount)
 	r1.POST("/change-password", handler.ChangePassword)
+	r1.POST("/change-email", handler.ChangeEmail)
+	r1.GET("/sessions", handler.ReadSessions)
+	r1.DELETE("/sessions/:id", handler.DeleteSession)
+	r1.DELETE("/sessions/families/:family", handler.DeleteSessionFamily)
+	r1.POST("/sessions/revoke-others", handler.RevokeOtherSessions)
+	r1.POST("/verify-email/resend", handler.ResendEmailVerification)
 }
 
 func (h *authHandler) Login(c echo.Context) error {
@@ -64,24 +92,211 @@ func (h *authHandler) Login(c echo.Context) error {
 
 	req.Email = utils.NormalizeEmail(req.Email)
 
+	attempt, wait, err := h.throttleService.CheckLogin(c.Request().Context(), c.RealIP(), req.Email)
+	if err != nil || wait > 0 {
+		return tooManyAttempts(c, wait, err)
+	}
+
 	user, err := h.userService.GetUserByEmailUnscoped(c.Request().Context(), req.Email)
 	if err != nil {
-		return responses.UnauthorizedWithMessage(c, "invalid credentials")
+		return h.loginFailed(c, attempt, "invalid credentials")
 	}
 
 	if err = utils.CheckPassword(req.Password, user.Password); err != nil {
+		return h.loginFailed(c, attempt, "invalid credentials")
+	}
+	if err := h.throttleService.Release(c.Request().Context(), attempt); err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error releasing login attempt: %w", err))
+	}
+
+	mfaEnabled, err := h.mfaService.IsEnabled(c.Request().Context(), user.ID)
+	if err != nil {
+		return responses.FailureWithMessage(c, "error checking two-factor authentication")
+	}
+	if mfaEnabled {
+		return h.mfaChallenge(c, user)
+	}
+
+	return h.completeLogin(c, user)
+}
+
+// LoginMFA is the second step of a login with two-factor authentication.
+func (h *authHandler) LoginMFA(c echo.Context) error {
+	req := requests.MFALoginRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	claims, err := h.tokenMaker.VerifyMFAToken(req.MFAToken)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "invalid or expired mfa token")
+	}
+
+	user, err := h.userService.GetUserByIDUnscoped(c.Request().Context(), claims.UserID)
+	if err != nil {
 		return responses.UnauthorizedWithMessage(c, "invalid credentials")
 	}
 
+	// Wrong codes count as failed logins of the account, so the second factor
+	// cannot be guessed with a known password either.
+	attempt, wait, err := h.throttleService.CheckLogin(c.Request().Context(), c.RealIP(), user.Email)
+	if err != nil || wait > 0 {
+		return tooManyAttempts(c, wait, err)
+	}
+
+	if err := h.mfaService.VerifyChallenge(c.Request().Context(), user.ID, claims.ID, req.Code); err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrMFANotEnabled) {
+			return h.loginFailed(c, attempt, services.ErrInvalidMFACode.Error())
+		}
+		if errors.Is(err, services.ErrMFAChallengeNotFound) {
+			_ = h.throttleService.Release(c.Request().Context(), attempt)
+			return responses.UnauthorizedWithMessage(c, "invalid or expired mfa token")
+		}
+		_ = h.throttleService.Release(c.Request().Context(), attempt)
+		return responses.FailureWithError(c, fmt.Errorf("error verifying authentication code: %w", err))
+	}
+	if err := h.throttleService.Release(c.Request().Context(), attempt); err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error releasing login attempt: %w", err))
+	}
+
+	return h.completeLogin(c, user)
+}
+
+func (h *authHandler) BeginPasskeyLogin(c echo.Context) error {
+	ceremony, err := h.passkeyService.BeginLogin(c.Request().Context())
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error starting passkey login: %w", err))
+	}
+
+	return responses.SuccessWithData(c, ceremony)
+}
+
+// FinishPasskeyLogin starts a session without a TOTP challenge, a passkey
+// already proves possession of a device and user verification.
+func (h *authHandler) FinishPasskeyLogin(c echo.Context) error {
+	req := requests.PasskeyFinishRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.passkeyService.FinishLogin(c.Request().Context(), req.CeremonyID, req.Credential)
+	if err != nil {
+		if errors.Is(err, services.ErrPasskeyCeremonyNotFound) || errors.Is(err, services.ErrPasskeyVerification) {
+			return responses.UnauthorizedWithMessage(c, "invalid passkey")
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error verifying passkey: %w", err))
+	}
+
+	return h.completeLogin(c, user)
+}
+
+func (h *authHandler) ReadOIDCProviders(c echo.Context) error {
+	return responses.SuccessWithData(c, h.oidcService.GetProviders())
+}
+
+func (h *authHandler) BeginOIDCLogin(c echo.Context) error {
+	authorization, err := h.oidcService.BeginAuthorization(c.Request().Context(), c.Param("provider"), nil)
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCProviderNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error starting sign in with provider: %w", err))
+	}
+
+	return responses.SuccessWithData(c, authorization)
+}
+
+// FinishOIDCLogin exchanges the code the provider sent to the frontend for a
+// session. Users with 2FA still get a TOTP challenge, since the provider may
+// not require a second factor.
+func (h *authHandler) FinishOIDCLogin(c echo.Context) error {
+	req := requests.OIDCCallbackRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.oidcService.Login(c.Request().Context(), req.State, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOIDCStateNotFound), errors.Is(err, services.ErrOIDCVerification):
+			return responses.UnauthorizedWithMessage(c, err.Error())
+		case errors.Is(err, services.ErrOIDCEmailNotVerified), errors.Is(err, services.ErrOIDCProviderLinked),
+			errors.Is(err, services.ErrOIDCAccountUnverified):
+			return responses.ForbiddenWithMessage(c, err.Error())
+		case errors.Is(err, services.ErrOIDCAccountNotFound):
+			return responses.NotFoundWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error signing in with provider: %w", err))
+	}
+
+	mfaEnabled, err := h.mfaService.IsEnabled(c.Request().Context(), user.ID)
+	if err != nil {
+		return responses.FailureWithMessage(c, "error checking two-factor authentication")
+	}
+	if mfaEnabled {
+		return h.mfaChallenge(c, user)
+	}
+
+	return h.completeLogin(c, user)
+}
+
+// mfaChallenge answers a correct password of a user with 2FA. The returned
+// token is exchanged for a session at /login/mfa, once.
+func (h *authHandler) mfaChallenge(c echo.Context, user *models.User) error {
+	mfaToken, claims, err := h.tokenMaker.CreateMFAToken(user.ID, user.PPID)
+	if err != nil {
+		return responses.FailureWithMessage(c, "error creating mfa token")
+	}
+	if err := h.mfaService.SaveChallenge(c.Request().Context(), user.ID, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error creating mfa token: %w", err))
+	}
+
+	response := map[string]interface{}{}
+	response["mfaRequired"] = true
+	response["mfaToken"] = mfaToken
+
+	return responses.SuccessWithData(c, response)
+}
+
+// loginFailed counts a failed login of the account and answers it.
+func (h *authHandler) loginFailed(c echo.Context, attempt *services.Attempt, message string) error {
+	if err := h.throttleService.RecordLoginFailure(c.Request().Context(), attempt); err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error recording login attempt: %w", err))
+	}
+	return responses.UnauthorizedWithMessage(c, message)
+}
+
+// tooManyAttempts answers a request the throttle turned down, or the error
+// of checking it.
+func tooManyAttempts(c echo.Context, wait time.Duration, err error) error {
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error checking attempts: %w", err))
+	}
+	return responses.TooManyRequestsWithMessage(c, wait, "too many attempts, please try again later")
+}
+
+// completeLogin reactivates an account scheduled for deletion and starts a new
+// session for it.
+func (h *authHandler) completeLogin(c echo.Context, user *models.User) error {
+	if err := h.throttleService.ClearAccount(c.Request().Context(), user.Email); err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error clearing login attempts: %w", err))
+	}
+
 	if user.DeletedAt.Valid {
-		if err = h.userService.ReactivateUser(c.Request().Context(), user.ID); err != nil {
+		if err := h.userService.ReactivateUser(c.Request().Context(), user.ID); err != nil {
 			return responses.FailureWithMessage(c, "error reactivating account")
 		}
 		user.DeletedAt.Valid = false
 	}
 
+	return h.createSession(c, user)
+}
+
+func (h *authHandler) createSession(c echo.Context, user *models.User) error {
+	tokenFamily := uuid.New().String()
+
 	legalAcceptedAt := h.legalDocumentService.GetLegalAcceptedAt(c.Request().Context(), user.ID)
-	accessToken, _, err := h.tokenMaker.CreateAccessToken(user.ID, user.PPID, legalAcceptedAt)
+	accessToken, _, err := h.tokenMaker.CreateAccessToken(user.ID, user.PPID, tokenFamily, legalAcceptedAt)
 	if err != nil {
 		return responses.FailureWithMessage(c, "error creating access token")
 	}
@@ -91,15 +306,22 @@ func (h *authHandler) Login(c echo.Context) error {
 		return responses.FailureWithMessage(c, "error creating refresh token")
 	}
 
+	issuedAt := refreshClaims.RegisteredClaims.IssuedAt.Time
+	userAgent := c.Request().UserAgent()
 	session, err := h.sessionService.CreateSessionFromExample(c.Request().Context(), models.Session{
 		ID:           refreshClaims.RegisteredClaims.ID,
 		UserID:       user.ID,
-		TokenFamily:  uuid.New().String(),
+		TokenFamily:  tokenFamily,
 		RefreshToken: refreshToken,
 		IsRevoked:    false,
 		ExpiresAt:    refreshClaims.RegisteredClaims.ExpiresAt.Time,
-		CreatedAt:    refreshClaims.RegisteredClaims.IssuedAt.Time,
-		UpdatedAt:    &refreshClaims.RegisteredClaims.IssuedAt.Time,
+		CreatedAt:    issuedAt,
+		UpdatedAt:    &issuedAt,
+		SignedInAt:   issuedAt,
+		LastUsedAt:   &issuedAt,
+		UserAgent:    userAgent,
+		IPAddress:    c.RealIP(),
+		DeviceName:   utils.DeviceNameFromUserAgent(userAgent),
 	})
 	if err != nil {
 		return responses.FailureWithMessage(c, "error creating session")
@@ -143,37 +365,7 @@ func (h *authHandler) Register(c echo.Context) error {
 		return responses.FailureWithError(c, fmt.Errorf("error creating user: %w", err))
 	}
 
-	legalAcceptedAt := h.legalDocumentService.GetLegalAcceptedAt(c.Request().Context(), user.ID)
-	accessToken, _, err := h.tokenMaker.CreateAccessToken(user.ID, user.PPID, legalAcceptedAt)
-	if err != nil {
-		return responses.FailureWithMessage(c, "error creating access token")
-	}
-
-	refreshToken, refreshClaims, err := h.tokenMaker.CreateRefreshToken(user.ID, user.PPID)
-	if err != nil {
-		return responses.FailureWithMessage(c, "error creating refresh token")
-	}
-
-	session, err := h.sessionService.CreateSessionFromExample(c.Request().Context(), models.Session{
-		ID:           refreshClaims.RegisteredClaims.ID,
-		UserID:       user.ID,
-		TokenFamily:  uuid.New().String(),
-		RefreshToken: refreshToken,
-		IsRevoked:    false,
-		ExpiresAt:    refreshClaims.RegisteredClaims.ExpiresAt.Time,
-		CreatedAt:    refreshClaims.RegisteredClaims.IssuedAt.Time,
-		UpdatedAt:    &refreshClaims.RegisteredClaims.IssuedAt.Time,
-	})
-	if err != nil {
-		return responses.FailureWithMessage(c, "error creating session")
-	}
-
-	response := map[string]interface{}{}
-	response["sessionId"] = session.ID
-	response["accessToken"] = accessToken
-	response["refreshToken"] = refreshToken
-
-	return responses.SuccessWithData(c, response)
+	return h.createSession(c, user)
 }
 
 func (h *authHandler) Logout(c echo.Context) error {
@@ -228,6 +420,10 @@ func (h *authHandler) RenewAccessToken(c echo.Context) error {
 		return responses.FailureWithMessage(c, "error creating refresh token")
 	}
 
+	// The device may have moved networks or updated its browser since the
+	// last refresh, the sign in time stays with the family.
+	issuedAt := newRefreshClaims.RegisteredClaims.IssuedAt.Time
+	userAgent := c.Request().UserAgent()
 	newSession, err := h.sessionService.RotateSession(c.Request().Context(), session, models.Session{
 		ID:           newRefreshClaims.RegisteredClaims.ID,
 		UserID:       user.ID,
@@ -235,15 +431,20 @@ func (h *authHandler) RenewAccessToken(c echo.Context) error {
 		RefreshToken: newRefreshToken,
 		IsRevoked:    false,
 		ExpiresAt:    newRefreshClaims.RegisteredClaims.ExpiresAt.Time,
-		CreatedAt:    newRefreshClaims.RegisteredClaims.IssuedAt.Time,
-		UpdatedAt:    &newRefreshClaims.RegisteredClaims.IssuedAt.Time,
+		CreatedAt:    issuedAt,
+		UpdatedAt:    &issuedAt,
+		SignedInAt:   session.SignedInAt,
+		LastUsedAt:   &issuedAt,
+		UserAgent:    userAgent,
+		IPAddress:    c.RealIP(),
+		DeviceName:   utils.DeviceNameFromUserAgent(userAgent),
 	})
 	if err != nil {
 		return responses.FailureWithMessage(c, "error rotating session")
 	}
 
 	legalAcceptedAt := h.legalDocumentService.GetLegalAcceptedAt(c.Request().Context(), user.ID)
-	accessToken, _, err := h.tokenMaker.CreateAccessToken(user.ID, user.PPID, legalAcceptedAt)
+	accessToken, _, err := h.tokenMaker.CreateAccessToken(user.ID, user.PPID, session.TokenFamily, legalAcceptedAt)
 	if err != nil {
 		return responses.FailureWithMessage(c, "error creating access token")
 	}
@@ -270,6 +471,75 @@ func (h *authHandler) RevokeSession(c echo.Context) error {
 	return responses.Success(c)
 }
 
+// ReadSessions lists the devices the user is signed in on.
+func (h *authHandler) ReadSessions(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	sessions, err := h.sessionService.GetActiveSessions(c.Request().Context(), claims.UserID, claims.TokenFamily)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching sessions: %w", err))
+	}
+
+	return responses.SuccessWithData(c, sessions)
+}
+
+func (h *authHandler) DeleteSession(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	if err := h.sessionService.RevokeUserSession(c.Request().Context(), claims.UserID, c.Param("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error revoking session: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "session revoked")
+}
+
+func (h *authHandler) DeleteSessionFamily(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	if err := h.sessionService.RevokeUserSessionFamily(c.Request().Context(), claims.UserID, c.Param("family")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error revoking session family: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "session family revoked")
+}
+
+// RevokeOtherSessions signs out every other device. Access tokens issued
+// before sessions carried a family cannot tell which session is current.
+func (h *authHandler) RevokeOtherSessions(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+	if claims.TokenFamily == "" {
+		return responses.BadRequestWithMessage(c, "current session is unknown, sign in again")
+	}
+
+	revoked, err := h.sessionService.RevokeOtherUserSessions(c.Request().Context(), claims.UserID, claims.TokenFamily)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error revoking sessions: %w", err))
+	}
+
+	response := map[string]interface{}{}
+	response["revoked"] = revoked
+
+	return responses.SuccessWithData(c, response)
+}
+
 func (h *authHandler) DeleteAccount(c echo.Context) error {
 	claims, err := middlewares.GetUserClaims(c)
 	if err != nil {
@@ -298,7 +568,18 @@ func (h *authHandler) ChangePassword(c echo.Context) error {
 		return responses.BadRequestWithMessage(c, "invalid input")
 	}
 
-	if err := h.userService.ChangePassword(c.Request().Context(), claims.UserID, req); err != nil {
+	user, err := h.userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "user not found")
+	}
+
+	wait, err := h.throttleService.CheckCredentials(c.Request().Context(), c.RealIP(), user.Email, services.ErrIncorrectPassword, func() error {
+		return h.userService.ChangePassword(c.Request().Context(), user.ID, req)
+	})
+	if wait > 0 {
+		return tooManyAttempts(c, wait, nil)
+	}
+	if err != nil {
 		return responses.BadRequestWithMessage(c, err.Error())
 	}
 
@@ -307,6 +588,96 @@ func (h *authHandler) ChangePassword(c echo.Context) error {
 	return responses.SuccessWithMessage(c, "password changed successfully")
 }
 
+// ChangeEmail emails a confirmation link to the new address and a cancel
+// link to the current one. The email changes once the link is confirmed.
+func (h *authHandler) ChangeEmail(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req := requests.ChangeEmailRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "user not found")
+	}
+
+	wait, err := h.throttleService.CheckCredentials(c.Request().Context(), c.RealIP(), user.Email, services.ErrIncorrectPassword, func() error {
+		return h.userService.RequestEmailChange(c.Request().Context(), user.ID, req)
+	})
+	if wait > 0 {
+		return tooManyAttempts(c, wait, nil)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmailTaken):
+			return responses.ConflictWithMessage(c, err.Error())
+		case errors.Is(err, services.ErrEmailNotVerified):
+			return responses.ForbiddenWithMessage(c, err.Error())
+		case errors.Is(err, services.ErrEmailChangeCancellable):
+			return responses.ConflictWithMessage(c, err.Error())
+		}
+		return responses.BadRequestWithMessage(c, err.Error())
+	}
+
+	return responses.SuccessWithMessage(c, "confirmation link sent to the new email address")
+}
+
+// ConfirmEmailChange applies the change of the emailed link and signs out
+// every session, like a password change.
+func (h *authHandler) ConfirmEmailChange(c echo.Context) error {
+	req := requests.EmailChangeTokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.userService.ConfirmEmailChange(c.Request().Context(), req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidEmailChangeToken):
+			return responses.BadRequestWithMessage(c, err.Error())
+		case errors.Is(err, services.ErrEmailTaken):
+			return responses.ConflictWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error changing email: %w", err))
+	}
+
+	_ = h.sessionService.RevokeAllUserSessions(c.Request().Context(), user.ID)
+
+	return responses.SuccessWithMessage(c, "email address changed")
+}
+
+// CancelEmailChange drops the change of the emailed link, or reverts it when
+// it was already confirmed.
+func (h *authHandler) CancelEmailChange(c echo.Context) error {
+	req := requests.EmailChangeTokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, reverted, err := h.userService.CancelEmailChange(c.Request().Context(), req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidEmailChangeToken):
+			return responses.BadRequestWithMessage(c, err.Error())
+		case errors.Is(err, services.ErrEmailTaken):
+			return responses.ConflictWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error cancelling email change: %w", err))
+	}
+
+	if reverted {
+		_ = h.sessionService.RevokeAllUserSessions(c.Request().Context(), user.ID)
+		return responses.SuccessWithMessage(c, "email change reverted, a link to set a new password was sent to your email")
+	}
+
+	return responses.SuccessWithMessage(c, "email change cancelled")
+}
+
 func (h *authHandler) ForgotPassword(c echo.Context) error {
 	req := requests.ForgotPasswordRequest{}
 	if err := c.Bind(&req); err != nil {
@@ -315,6 +686,10 @@ func (h *authHandler) ForgotPassword(c echo.Context) error {
 
 	req.Email = utils.NormalizeEmail(req.Email)
 
+	if wait, err := h.throttleService.CheckPasswordResetRequest(c.Request().Context(), c.RealIP(), req.Email); err != nil || wait > 0 {
+		return tooManyAttempts(c, wait, err)
+	}
+
 	_ = h.userService.RequestPasswordReset(c.Request().Context(), req.Email)
 
 	return responses.SuccessWithMessage(c, "if that email is registered, a reset link has been sent")
@@ -330,42 +705,68 @@ func (h *authHandler) ResetPassword(c echo.Context) error {
 		return responses.BadRequestWithMessage(c, "passwords do not match")
 	}
 
+	attempt, wait, err := h.throttleService.CheckPasswordReset(c.Request().Context(), c.RealIP())
+	if err != nil || wait > 0 {
+		return tooManyAttempts(c, wait, err)
+	}
+
 	user, err := h.userService.ResetPassword(c.Request().Context(), req.Token, req.NewPassword)
 	if err != nil {
 		return responses.BadRequestWithMessage(c, "invalid or expired reset token")
 	}
+	_ = h.throttleService.Release(c.Request().Context(), attempt)
 
 	_ = h.sessionService.RevokeAllUserSessions(c.Request().Context(), user.ID)
+	// A new password unlocks an account that failed logins locked.
+	_ = h.throttleService.ClearAccount(c.Request().Context(), user.Email)
 
-	legalAcceptedAt := h.legalDocumentService.GetLegalAcceptedAt(c.Request().Context(), user.ID)
-	accessToken, _, err := h.tokenMaker.CreateAccessToken(user.ID, user.PPID, legalAcceptedAt)
+	// A reset link proves access to the mailbox only, so the second factor is
+	// still required.
+	mfaEnabled, err := h.mfaService.IsEnabled(c.Request().Context(), user.ID)
 	if err != nil {
-		return responses.FailureWithMessage(c, "error creating access token")
+		return responses.FailureWithMessage(c, "error checking two-factor authentication")
+	}
+	if mfaEnabled {
+		return h.mfaChallenge(c, user)
 	}
 
-	refreshToken, refreshClaims, err := h.tokenMaker.CreateRefreshToken(user.ID, user.PPID)
-	if err != nil {
-		return responses.FailureWithMessage(c, "error creating refresh token")
+	return h.createSession(c, user)
+}
+
+// VerifyEmail confirms the address of the emailed link. It works without a
+// session, since the link may be opened on another device.
+func (h *authHandler) VerifyEmail(c echo.Context) error {
+	req := requests.VerifyEmailRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
 	}
 
-	session, err := h.sessionService.CreateSessionFromExample(c.Request().Context(), models.Session{
-		ID:           refreshClaims.RegisteredClaims.ID,
-		UserID:       user.ID,
-		TokenFamily:  uuid.New().String(),
-		RefreshToken: refreshToken,
-		IsRevoked:    false,
-		ExpiresAt:    refreshClaims.RegisteredClaims.ExpiresAt.Time,
-		CreatedAt:    refreshClaims.RegisteredClaims.IssuedAt.Time,
-		UpdatedAt:    &refreshClaims.RegisteredClaims.IssuedAt.Time,
-	})
+	if _, err := h.userService.VerifyEmail(c.Request().Context(), req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error verifying email: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "email address verified")
+}
+
+func (h *authHandler) ResendEmailVerification(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
 	if err != nil {
-		return responses.FailureWithMessage(c, "error creating session")
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
 	}
 
-	response := map[string]interface{}{}
-	response["sessionId"] = session.ID
-	response["accessToken"] = accessToken
-	response["refreshToken"] = refreshToken
+	if wait, err := h.throttleService.CheckVerificationResend(c.Request().Context(), claims.UserID); err != nil || wait > 0 {
+		return tooManyAttempts(c, wait, err)
+	}
 
-	return responses.SuccessWithData(c, response)
+	if err := h.userService.ResendEmailVerification(c.Request().Context(), claims.UserID); err != nil {
+		if errors.Is(err, services.ErrEmailAlreadyVerified) {
+			return responses.ConflictWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error sending verification email: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "verification email sent")
 }
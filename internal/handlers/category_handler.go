@@ -6,6 +6,7 @@ import (
 	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
 	"github.com/emilijan-koteski/monexa/internal/middlewares"
 	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
 	"github.com/emilijan-koteski/monexa/internal/requests"
 	"github.com/emilijan-koteski/monexa/internal/services"
 	"github.com/emilijan-koteski/monexa/internal/utils"
@@ -26,6 +27,7 @@ func RegisterCategoryHandler(e *echo.Echo, categoryService *services.CategorySer
 	// Restricted group
 	r1 := v1.Group("")
 	r1.Use(middlewares.AuthMiddleware())
+	r1.Use(middlewares.ReadWriteScopeMiddleware(types.TokenScopeRecordsRead, types.TokenScopeRecordsWrite))
 	for _, m := range restrictedMiddlewares {
 		r1.Use(m)
 	}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type digestHandler struct {
+	digestService *services.DigestService
+}
+
+func RegisterDigestHandler(e *echo.Echo, digestService *services.DigestService, restrictedMiddlewares ...echo.MiddlewareFunc) {
+	handler := &digestHandler{digestService: digestService}
+
+	// Unauthenticated group
+	v1 := e.Group("/api/v1/digest")
+
+	// Unsubscribe links are signed and sent by email, so they work without a session.
+	v1.POST("/unsubscribe", handler.UnsubscribeWithToken)
+
+	// Restricted group
+	r1 := v1.Group("")
+	r1.Use(middlewares.AuthMiddleware())
+	for _, m := range restrictedMiddlewares {
+		r1.Use(m)
+	}
+
+	r1.GET("", handler.Read)
+	r1.PUT("", handler.Subscribe)
+	r1.DELETE("", handler.Unsubscribe)
+}
+
+func (h *digestHandler) Read(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	subscription, err := h.digestService.GetByUserID(c.Request().Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error fetching digest subscription: %w", err))
+	}
+
+	return responses.SuccessWithData(c, subscription)
+}
+
+func (h *digestHandler) Subscribe(c echo.Context) error {
+	req := requests.DigestSubscriptionRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+	req.UserID = claims.UserID
+
+	subscription, err := h.digestService.Subscribe(c.Request().Context(), req)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, err.Error())
+	}
+
+	return responses.SuccessWithData(c, subscription)
+}
+
+func (h *digestHandler) Unsubscribe(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	if err := h.digestService.Unsubscribe(c.Request().Context(), claims.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error deleting digest subscription: %w", err))
+	}
+
+	return responses.Success(c)
+}
+
+func (h *digestHandler) UnsubscribeWithToken(c echo.Context) error {
+	req := requests.DigestUnsubscribeRequest{}
+	if err := c.Bind(&req); err != nil || req.Token == "" {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	if err := h.digestService.UnsubscribeWithToken(c.Request().Context(), req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidUnsubscribeToken) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error unsubscribing from digest: %w", err))
+	}
+
+	return responses.Success(c)
+}
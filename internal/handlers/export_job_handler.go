@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type exportJobHandler struct {
+	exportJobService *services.ExportJobService
+}
+
+func RegisterExportJobHandler(e *echo.Echo, exportJobService *services.ExportJobService, restrictedMiddlewares ...echo.MiddlewareFunc) {
+	handler := &exportJobHandler{exportJobService: exportJobService}
+
+	// Unauthenticated group
+	v1 := e.Group("/api/v1/exports")
+
+	// Download links are signed and sent by email, so they work without a session.
+	v1.GET("/:id/download", handler.Download)
+
+	// Restricted group
+	r1 := v1.Group("")
+	r1.Use(middlewares.AuthMiddleware())
+	r1.Use(middlewares.ScopeMiddleware(types.TokenScopeExport))
+	for _, m := range restrictedMiddlewares {
+		r1.Use(m)
+	}
+
+	r1.GET("", handler.ReadAll)
+	r1.GET("/:id", handler.Read)
+	r1.POST("", handler.Create)
+}
+
+func (h *exportJobHandler) ReadAll(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	jobs, err := h.exportJobService.GetHistory(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching export jobs: %w", err))
+	}
+
+	return responses.SuccessWithData(c, jobs)
+}
+
+func (h *exportJobHandler) Read(c echo.Context) error {
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	job, err := h.exportJobService.GetJob(c.Request().Context(), claims.UserID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error fetching export job: %w", err))
+	}
+
+	return responses.SuccessWithData(c, job)
+}
+
+// Create queues an export with the same options as GET /users/data/export.
+func (h *exportJobHandler) Create(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req, err := parseExportRequest(c, claims.UserID)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, err.Error())
+	}
+
+	job, err := h.exportJobService.CreateJob(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrTooManyExportJobs) {
+			return responses.ConflictWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error creating export job: %w", err))
+	}
+
+	return responses.CreatedWithData(c, job)
+}
+
+func (h *exportJobHandler) Download(c echo.Context) error {
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	expires, err := strconv.ParseInt(c.QueryParam("expires"), 10, 64)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, "invalid expires parameter")
+	}
+
+	job, file, err := h.exportJobService.OpenDownload(c.Request().Context(), id, expires, c.QueryParam("signature"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDownloadLink) {
+			return responses.ForbiddenWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error downloading export: %w", err))
+	}
+	defer file.Close()
+
+	_, contentType := services.ExportFileName(dtotypes.ExportFormatType(job.Format), job.CreatedAt)
+
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, job.FileName))
+	c.Response().Header().Set("Content-Length", strconv.FormatInt(job.Size, 10))
+
+	return c.Stream(http.StatusOK, contentType, file)
+}
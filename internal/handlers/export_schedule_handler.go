@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type exportScheduleHandler struct {
+	exportScheduleService *services.ExportScheduleService
+}
+
+func RegisterExportScheduleHandler(e *echo.Echo, exportScheduleService *services.ExportScheduleService, restrictedMiddlewares ...echo.MiddlewareFunc) {
+	handler := &exportScheduleHandler{exportScheduleService: exportScheduleService}
+
+	// Unauthenticated group
+	v1 := e.Group("/api/v1/export-schedule")
+
+	// Restricted group
+	r1 := v1.Group("")
+	r1.Use(middlewares.AuthMiddleware())
+	for _, m := range restrictedMiddlewares {
+		r1.Use(m)
+	}
+
+	r1.GET("", handler.Read)
+	r1.PUT("", handler.Save)
+	r1.DELETE("", handler.Delete)
+}
+
+func (h *exportScheduleHandler) Read(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	schedule, err := h.exportScheduleService.GetByUserID(c.Request().Context(), claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error fetching export schedule: %w", err))
+	}
+
+	return responses.SuccessWithData(c, schedule)
+}
+
+func (h *exportScheduleHandler) Save(c echo.Context) error {
+	req := requests.ExportScheduleRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+	req.UserID = claims.UserID
+
+	schedule, err := h.exportScheduleService.Save(c.Request().Context(), req)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, err.Error())
+	}
+
+	return responses.SuccessWithData(c, schedule)
+}
+
+func (h *exportScheduleHandler) Delete(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	if err := h.exportScheduleService.Delete(c.Request().Context(), claims.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error deleting export schedule: %w", err))
+	}
+
+	return responses.Success(c)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	dtoResponses "github.com/emilijan-koteski/monexa/internal/responses"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+type jwksHandler struct {
+	signingKeyService *services.SigningKeyService
+}
+
+func RegisterJWKSHandler(e *echo.Echo, signingKeyService *services.SigningKeyService) {
+	handler := &jwksHandler{signingKeyService: signingKeyService}
+
+	e.GET("/.well-known/jwks.json", handler.GetJWKS)
+}
+
+// GetJWKS publishes the public keys so other services can verify access
+// tokens. The body is a plain JWK Set, not the usual response envelope.
+func (h *jwksHandler) GetJWKS(c echo.Context) error {
+	keys, err := h.signingKeyService.GetPublicKeys()
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching signing keys: %w", err))
+	}
+
+	c.Response().Header().Set(echo.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", int(services.SigningKeyReloadInterval.Seconds())))
+	return c.JSON(http.StatusOK, dtoResponses.JWKSResponse{Keys: keys})
+}
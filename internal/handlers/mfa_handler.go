@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	dtoResponses "github.com/emilijan-koteski/monexa/internal/responses"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+type mfaHandler struct {
+	userService     *services.UserService
+	mfaService      *services.MFAService
+	throttleService *services.AuthThrottleService
+}
+
+func RegisterMFAHandler(e *echo.Echo, userService *services.UserService, mfaService *services.MFAService, throttleService *services.AuthThrottleService) {
+	handler := &mfaHandler{
+		userService:     userService,
+		mfaService:      mfaService,
+		throttleService: throttleService,
+	}
+
+	// Restricted group
+	r1 := e.Group("/api/v1/auth/mfa")
+	r1.Use(middlewares.AuthMiddleware())
+
+	r1.GET("", handler.Read)
+	r1.POST("/totp/setup", handler.Setup)
+	r1.POST("/totp/confirm", handler.Confirm)
+	r1.POST("/disable", handler.Disable)
+	r1.POST("/recovery-codes", handler.RegenerateRecoveryCodes)
+}
+
+func (h *mfaHandler) Read(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	status, err := h.mfaService.GetStatus(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching two-factor status: %w", err))
+	}
+
+	return responses.SuccessWithData(c, status)
+}
+
+// Setup starts enrolling an authenticator. Replacing one that is already
+// enabled needs the password and a current code in the body.
+func (h *mfaHandler) Setup(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req := requests.MFAReauthRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "user not found")
+	}
+
+	enabled, err := h.mfaService.IsEnabled(c.Request().Context(), user.ID)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching two-factor status: %w", err))
+	}
+	if enabled {
+		if wait, err := h.reauthenticate(c, *user, req); wait > 0 || err != nil {
+			return reauthenticationFailed(c, wait, err)
+		}
+	}
+
+	setup, err := h.mfaService.BeginSetup(c.Request().Context(), *user, enabled)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error starting two-factor setup: %w", err))
+	}
+
+	return responses.SuccessWithData(c, setup)
+}
+
+// Confirm enables the authenticator from Setup and returns the recovery codes,
+// which are shown only this once.
+func (h *mfaHandler) Confirm(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req := requests.MFAConfirmRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	codes, err := h.mfaService.ConfirmSetup(c.Request().Context(), claims.UserID, req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrMFASetupNotStarted) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error confirming two-factor setup: %w", err))
+	}
+
+	return responses.SuccessWithData(c, dtoResponses.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *mfaHandler) Disable(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req := requests.MFAReauthRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "user not found")
+	}
+
+	if wait, err := h.reauthenticate(c, *user, req); wait > 0 || err != nil {
+		return reauthenticationFailed(c, wait, err)
+	}
+
+	if err := h.mfaService.Disable(c.Request().Context(), user.ID); err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error disabling two-factor authentication: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "two-factor authentication disabled")
+}
+
+func (h *mfaHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req := requests.MFAReauthRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "user not found")
+	}
+
+	if wait, err := h.reauthenticate(c, *user, req); wait > 0 || err != nil {
+		return reauthenticationFailed(c, wait, err)
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request().Context(), user.ID)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error generating recovery codes: %w", err))
+	}
+
+	return responses.SuccessWithData(c, dtoResponses.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// reauthenticate checks the password and code of the body as a login attempt
+// of the user, so they cannot be guessed here past the login limits.
+func (h *mfaHandler) reauthenticate(c echo.Context, user models.User, req requests.MFAReauthRequest) (time.Duration, error) {
+	return h.throttleService.CheckCredentials(c.Request().Context(), c.RealIP(), user.Email, services.ErrMFAReauthentication, func() error {
+		return h.mfaService.Reauthenticate(c.Request().Context(), user, req.Password, req.Code)
+	})
+}
+
+func reauthenticationFailed(c echo.Context, wait time.Duration, err error) error {
+	if wait > 0 {
+		return tooManyAttempts(c, wait, nil)
+	}
+	if errors.Is(err, services.ErrMFAReauthentication) {
+		return responses.UnauthorizedWithMessage(c, err.Error())
+	}
+	if errors.Is(err, services.ErrMFANotEnabled) {
+		return responses.BadRequestWithMessage(c, err.Error())
+	}
+	return responses.FailureWithError(c, fmt.Errorf("error verifying credentials: %w", err))
+}
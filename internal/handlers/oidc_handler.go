@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type oidcHandler struct {
+	oidcService *services.OIDCService
+}
+
+func RegisterOIDCHandler(e *echo.Echo, oidcService *services.OIDCService) {
+	handler := &oidcHandler{oidcService: oidcService}
+
+	// Restricted group
+	r1 := e.Group("/api/v1/auth/oidc")
+	r1.Use(middlewares.AuthMiddleware())
+
+	r1.GET("/identities", handler.ReadAll)
+	r1.POST("/:provider/link", handler.BeginLink)
+	r1.POST("/link/callback", handler.FinishLink)
+	r1.DELETE("/identities/:id", handler.Delete)
+}
+
+func (h *oidcHandler) ReadAll(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	identities, err := h.oidcService.GetIdentitiesByUserID(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching linked providers: %w", err))
+	}
+
+	return responses.SuccessWithData(c, identities)
+}
+
+func (h *oidcHandler) BeginLink(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	authorization, err := h.oidcService.BeginAuthorization(c.Request().Context(), c.Param("provider"), &claims.UserID)
+	if err != nil {
+		if errors.Is(err, services.ErrOIDCProviderNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error starting provider link: %w", err))
+	}
+
+	return responses.SuccessWithData(c, authorization)
+}
+
+// FinishLink is the authenticated counterpart of /auth/oidc/callback. Only the
+// user who started the link can finish it, so a code from someone else's
+// browser cannot attach their identity to this account.
+func (h *oidcHandler) FinishLink(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req := requests.OIDCCallbackRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	identity, err := h.oidcService.Link(c.Request().Context(), claims.UserID, req.State, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrOIDCStateNotFound), errors.Is(err, services.ErrOIDCVerification):
+			return responses.BadRequestWithMessage(c, err.Error())
+		case errors.Is(err, services.ErrOIDCIdentityLinked), errors.Is(err, services.ErrOIDCProviderLinked):
+			return responses.ConflictWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error linking provider: %w", err))
+	}
+
+	return responses.SuccessWithData(c, identity)
+}
+
+func (h *oidcHandler) Delete(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	if err := h.oidcService.Unlink(c.Request().Context(), claims.UserID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error unlinking provider: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "provider unlinked")
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type outboxHandler struct {
+	outboxService *services.OutboxService
+}
+
+func RegisterOutboxHandler(e *echo.Echo, outboxService *services.OutboxService, userService *services.UserService) {
+	handler := &outboxHandler{outboxService: outboxService}
+
+	// Admin group
+	a1 := e.Group("/api/v1/admin/outbox")
+	a1.Use(middlewares.AuthMiddleware())
+	a1.Use(middlewares.AdminMiddleware(userService))
+
+	a1.GET("", handler.ReadAll)
+	a1.POST("/:id/retry", handler.Retry)
+}
+
+// ReadAll lists dead emails unless another status is given with ?status=.
+func (h *outboxHandler) ReadAll(c echo.Context) error {
+	status := types.OutboxEmailDead
+	if param := c.QueryParam("status"); param != "" {
+		status = types.OutboxEmailStatus(param)
+	}
+	if !types.IsValidOutboxEmailStatus(status) {
+		return responses.BadRequestWithMessage(c, "invalid status, expected PENDING, SENT or DEAD")
+	}
+
+	emails, err := h.outboxService.GetByStatus(c.Request().Context(), status)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching outbox emails: %w", err))
+	}
+
+	return responses.SuccessWithData(c, emails)
+}
+
+func (h *outboxHandler) Retry(c echo.Context) error {
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	email, err := h.outboxService.Retry(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		if errors.Is(err, services.ErrOutboxEmailNotDead) {
+			return responses.ConflictWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error retrying outbox email: %w", err))
+	}
+
+	return responses.SuccessWithData(c, email)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type passkeyHandler struct {
+	userService    *services.UserService
+	passkeyService *services.PasskeyService
+}
+
+func RegisterPasskeyHandler(e *echo.Echo, userService *services.UserService, passkeyService *services.PasskeyService) {
+	handler := &passkeyHandler{
+		userService:    userService,
+		passkeyService: passkeyService,
+	}
+
+	// Restricted group
+	r1 := e.Group("/api/v1/auth/passkeys")
+	r1.Use(middlewares.AuthMiddleware())
+
+	r1.GET("", handler.ReadAll)
+	r1.POST("/register/begin", handler.BeginRegistration)
+	r1.POST("/register/finish", handler.FinishRegistration)
+	r1.DELETE("/:id", handler.Delete)
+}
+
+func (h *passkeyHandler) ReadAll(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	passkeys, err := h.passkeyService.GetAllByUserID(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching passkeys: %w", err))
+	}
+
+	return responses.SuccessWithData(c, passkeys)
+}
+
+func (h *passkeyHandler) BeginRegistration(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	user, err := h.userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "user not found")
+	}
+
+	ceremony, err := h.passkeyService.BeginRegistration(c.Request().Context(), *user)
+	if err != nil {
+		if errors.Is(err, services.ErrTooManyPasskeys) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error starting passkey registration: %w", err))
+	}
+
+	return responses.SuccessWithData(c, ceremony)
+}
+
+func (h *passkeyHandler) FinishRegistration(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	req := requests.PasskeyFinishRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	user, err := h.userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "user not found")
+	}
+
+	passkey, err := h.passkeyService.FinishRegistration(c.Request().Context(), *user, req.CeremonyID, req.Name, req.Credential)
+	if err != nil {
+		if errors.Is(err, services.ErrPasskeyCeremonyNotFound) || errors.Is(err, services.ErrPasskeyVerification) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error registering passkey: %w", err))
+	}
+
+	return responses.CreatedWithData(c, passkey)
+}
+
+func (h *passkeyHandler) Delete(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	if err := h.passkeyService.Delete(c.Request().Context(), claims.UserID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error deleting passkey: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "passkey deleted")
+}
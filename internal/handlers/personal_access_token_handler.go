@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type personalAccessTokenHandler struct {
+	personalAccessTokenService *services.PersonalAccessTokenService
+}
+
+// RegisterPersonalAccessTokenHandler registers the token management routes.
+// They have no scope middleware, so a personal access token cannot create or
+// revoke tokens itself.
+func RegisterPersonalAccessTokenHandler(e *echo.Echo, personalAccessTokenService *services.PersonalAccessTokenService, restrictedMiddlewares ...echo.MiddlewareFunc) {
+	handler := &personalAccessTokenHandler{personalAccessTokenService: personalAccessTokenService}
+
+	// Restricted group
+	r1 := e.Group("/api/v1/personal-access-tokens")
+	r1.Use(middlewares.AuthMiddleware())
+	for _, m := range restrictedMiddlewares {
+		r1.Use(m)
+	}
+
+	r1.GET("", handler.ReadAll)
+	r1.POST("", handler.Create)
+	r1.DELETE("/:id", handler.Delete)
+}
+
+func (h *personalAccessTokenHandler) ReadAll(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	tokens, err := h.personalAccessTokenService.GetAllByUserID(c.Request().Context(), claims.UserID)
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching personal access tokens: %w", err))
+	}
+
+	return responses.SuccessWithData(c, tokens)
+}
+
+func (h *personalAccessTokenHandler) Create(c echo.Context) error {
+	req := requests.PersonalAccessTokenRequest{}
+	if err := c.Bind(&req); err != nil {
+		return responses.BadRequestWithMessage(c, "invalid input")
+	}
+
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+	req.UserID = claims.UserID
+
+	created, err := h.personalAccessTokenService.Create(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrPersonalAccessTokenName) ||
+			errors.Is(err, services.ErrPersonalAccessTokenScopes) ||
+			errors.Is(err, services.ErrPersonalAccessTokenExpiresIn) ||
+			errors.Is(err, services.ErrTooManyPersonalAccessTokens) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error creating personal access token: %w", err))
+	}
+
+	return responses.CreatedWithData(c, created)
+}
+
+func (h *personalAccessTokenHandler) Delete(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	if err := h.personalAccessTokenService.Revoke(c.Request().Context(), claims.UserID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error revoking personal access token: %w", err))
+	}
+
+	return responses.SuccessWithMessage(c, "personal access token revoked")
+}
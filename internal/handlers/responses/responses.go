@@ -2,7 +2,10 @@ package responses
 
 import (
 	"github.com/labstack/echo/v4"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func createMessageBody(status int, message string) map[string]interface{} {
@@ -115,6 +118,18 @@ func ConflictWithMessage(c echo.Context, message string) error {
 	return c.JSON(http.StatusConflict, createMessageBody(http.StatusConflict, message))
 }
 
+// StatusTooManyRequests: 429
+
+func TooManyRequests(c echo.Context, retryAfter time.Duration) error {
+	return TooManyRequestsWithMessage(c, retryAfter, "too many requests")
+}
+
+func TooManyRequestsWithMessage(c echo.Context, retryAfter time.Duration, message string) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+	return c.JSON(http.StatusTooManyRequests, createMessageBody(http.StatusTooManyRequests, message))
+}
+
 // StatusUnavailableForLegalReasons: 451
 
 func LegalAcceptanceRequired(c echo.Context) error {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
 	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
 	"github.com/emilijan-koteski/monexa/internal/requests"
 	"github.com/emilijan-koteski/monexa/internal/services"
 	"github.com/labstack/echo/v4"
@@ -24,6 +25,7 @@ func RegisterSettingHandler(e *echo.Echo, settingService *services.SettingServic
 	// Restricted group
 	r1 := v1.Group("")
 	r1.Use(middlewares.AuthMiddleware())
+	r1.Use(middlewares.ReadWriteScopeMiddleware(types.TokenScopeRecordsRead, types.TokenScopeRecordsWrite))
 	for _, m := range restrictedMiddlewares {
 		r1.Use(m)
 	}
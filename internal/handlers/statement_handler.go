@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/middlewares"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+type statementHandler struct {
+	statementService *services.StatementService
+}
+
+func RegisterStatementHandler(e *echo.Echo, statementService *services.StatementService, restrictedMiddlewares ...echo.MiddlewareFunc) {
+	handler := &statementHandler{statementService: statementService}
+
+	// Unauthenticated group
+	v1 := e.Group("/api/v1/statements")
+
+	// Restricted group
+	r1 := v1.Group("")
+	r1.Use(middlewares.AuthMiddleware())
+	r1.Use(middlewares.ScopeMiddleware(types.TokenScopeExport))
+	for _, m := range restrictedMiddlewares {
+		r1.Use(m)
+	}
+
+	r1.GET("/pdf", handler.GetPDF)
+}
+
+func (h *statementHandler) GetPDF(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, "no logged in user")
+	}
+
+	now := time.Now()
+
+	period := dtotypes.StatementPeriodMonthly
+	if periodStr := c.QueryParam("period"); periodStr != "" {
+		period = dtotypes.StatementPeriodType(periodStr)
+		if !dtotypes.IsValidStatementPeriod(period) {
+			return responses.BadRequestWithMessage(c, "invalid period parameter: must be MONTHLY or ANNUAL")
+		}
+	}
+
+	year := now.Year()
+	if yearStr := c.QueryParam("year"); yearStr != "" {
+		year, err = strconv.Atoi(yearStr)
+		if err != nil || year <= 0 {
+			return responses.BadRequestWithMessage(c, "invalid year parameter")
+		}
+	}
+
+	month := int(now.Month())
+	if monthStr := c.QueryParam("month"); monthStr != "" {
+		month, err = strconv.Atoi(monthStr)
+		if err != nil || month < 1 || month > 12 {
+			return responses.BadRequestWithMessage(c, "invalid month parameter: must be between 1 and 12")
+		}
+	}
+
+	pdfBytes, err := h.statementService.GenerateStatement(c.Request().Context(), requests.StatementRequest{
+		UserID: claims.UserID,
+		Period: period,
+		Year:   year,
+		Month:  month,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrStatementTooLarge) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error generating statement: %w", err))
+	}
+
+	filename := fmt.Sprintf("monexa-statement-%d-%02d.pdf", year, month)
+	if period == dtotypes.StatementPeriodAnnual {
+		filename = fmt.Sprintf("monexa-statement-%d.pdf", year)
+	}
+
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Response().Header().Set("Content-Length", strconv.Itoa(len(pdfBytes)))
+
+	return c.Blob(http.StatusOK, "application/pdf", pdfBytes)
+}
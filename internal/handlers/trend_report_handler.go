@@ -3,8 +3,12 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/emilijan-koteski/monexa/internal/charts"
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
 	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
 	"github.com/emilijan-koteski/monexa/internal/middlewares"
 	"github.com/emilijan-koteski/monexa/internal/models/types"
@@ -28,6 +32,7 @@ func RegisterTrendReportHandler(e *echo.Echo, trendReportService *services.Trend
 	// Restricted group
 	r1 := v1.Group("")
 	r1.Use(middlewares.AuthMiddleware())
+	r1.Use(middlewares.ReadWriteScopeMiddleware(types.TokenScopeRecordsRead, types.TokenScopeRecordsWrite))
 	for _, m := range restrictedMiddlewares {
 		r1.Use(m)
 	}
@@ -39,6 +44,9 @@ func RegisterTrendReportHandler(e *echo.Echo, trendReportService *services.Trend
 	r1.DELETE("/:id", handler.Delete)
 	r1.GET("/:id/monthly-data", handler.GetMonthlyData)
 	r1.GET("/:id/monthly-details", handler.GetMonthlyDetails)
+	r1.GET("/:id/comparison", handler.GetComparison)
+	r1.GET("/:id/chart.svg", handler.GetChartSVG)
+	r1.GET("/:id/chart.png", handler.GetChartPNG)
 }
 
 func (h *trendReportHandler) ReadAll(c echo.Context) error {
@@ -276,3 +284,190 @@ func (h *trendReportHandler) GetMonthlyDetails(c echo.Context) error {
 
 	return responses.SuccessWithData(c, data)
 }
+
+func (h *trendReportHandler) GetComparison(c echo.Context) error {
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, "no logged in user")
+	}
+
+	isOwner, err := h.trendReportService.IsOwner(c.Request().Context(), claims.UserID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, err)
+	}
+	if !isOwner {
+		return responses.Unauthorized(c)
+	}
+
+	yearStr := c.QueryParam("year")
+	if yearStr == "" {
+		return responses.BadRequestWithMessage(c, "year query parameter is required")
+	}
+	year, err := strconv.Atoi(yearStr)
+	if err != nil || year <= 0 {
+		return responses.BadRequestWithMessage(c, "invalid year parameter")
+	}
+
+	startMonth, endMonth := 1, 12
+	if startStr := c.QueryParam("startMonth"); startStr != "" {
+		startMonth, err = strconv.Atoi(startStr)
+		if err != nil || startMonth < 1 || startMonth > 12 {
+			return responses.BadRequestWithMessage(c, "invalid startMonth parameter")
+		}
+	}
+	if endStr := c.QueryParam("endMonth"); endStr != "" {
+		endMonth, err = strconv.Atoi(endStr)
+		if err != nil || endMonth < 1 || endMonth > 12 {
+			return responses.BadRequestWithMessage(c, "invalid endMonth parameter")
+		}
+	}
+	if startMonth > endMonth {
+		return responses.BadRequestWithMessage(c, "startMonth must not be after endMonth")
+	}
+
+	mode := dtotypes.TrendComparisonYearOverYear
+	if modeStr := c.QueryParam("mode"); modeStr != "" {
+		mode = dtotypes.TrendComparisonModeType(modeStr)
+		if !dtotypes.IsValidTrendComparisonMode(mode) {
+			return responses.BadRequestWithMessage(c, "invalid mode parameter: must be YEAR_OVER_YEAR or PREVIOUS_PERIOD")
+		}
+	}
+
+	periods := 1
+	if periodsStr := c.QueryParam("periods"); periodsStr != "" {
+		periods, err = strconv.Atoi(periodsStr)
+		if err != nil || periods < 1 || periods > services.MaxComparisonPeriods {
+			return responses.BadRequestWithMessage(c, fmt.Sprintf("invalid periods parameter: must be between 1 and %d", services.MaxComparisonPeriods))
+		}
+	}
+
+	var categoryType *types.CategoryType
+	if typeStr := c.QueryParam("type"); typeStr != "" {
+		ct := types.CategoryType(typeStr)
+		if !types.IsValidCategoryType(ct) {
+			return responses.BadRequestWithMessage(c, "invalid type parameter: must be INCOME or EXPENSE")
+		}
+		categoryType = &ct
+	}
+
+	data, err := h.trendReportService.GetComparison(c.Request().Context(), requests.TrendReportComparisonRequest{
+		ReportID:   id,
+		UserID:     claims.UserID,
+		Year:       year,
+		StartMonth: startMonth,
+		EndMonth:   endMonth,
+		Mode:       mode,
+		Periods:    periods,
+		Type:       categoryType,
+	})
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error fetching comparison: %w", err))
+	}
+
+	return responses.SuccessWithData(c, data)
+}
+
+func (h *trendReportHandler) GetChartSVG(c echo.Context) error {
+	return h.renderChart(c, charts.FormatSVG, "image/svg+xml")
+}
+
+func (h *trendReportHandler) GetChartPNG(c echo.Context) error {
+	return h.renderChart(c, charts.FormatPNG, "image/png")
+}
+
+func (h *trendReportHandler) renderChart(c echo.Context, format charts.Format, contentType string) error {
+	id, err := utils.ParseIDParam(c)
+	if err != nil {
+		return responses.BadRequestWithError(c, err)
+	}
+
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, "no logged in user")
+	}
+
+	isOwner, err := h.trendReportService.IsOwner(c.Request().Context(), claims.UserID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return responses.NotFound(c)
+		}
+		return responses.FailureWithError(c, err)
+	}
+	if !isOwner {
+		return responses.Unauthorized(c)
+	}
+
+	year := time.Now().Year()
+	if yearStr := c.QueryParam("year"); yearStr != "" {
+		year, err = strconv.Atoi(yearStr)
+		if err != nil || year <= 0 {
+			return responses.BadRequestWithMessage(c, "invalid year parameter")
+		}
+	}
+
+	var categoryType *types.CategoryType
+	if typeStr := c.QueryParam("type"); typeStr != "" {
+		ct := types.CategoryType(typeStr)
+		if !types.IsValidCategoryType(ct) {
+			return responses.BadRequestWithMessage(c, "invalid type parameter: must be INCOME or EXPENSE")
+		}
+		categoryType = &ct
+	}
+
+	kind := charts.KindBar
+	if kindStr := c.QueryParam("kind"); kindStr != "" {
+		kind = charts.Kind(kindStr)
+		if !charts.IsValidKind(kind) {
+			return responses.BadRequestWithMessage(c, "invalid kind parameter: must be bar or line")
+		}
+	}
+
+	theme := charts.ThemeLight
+	if themeStr := c.QueryParam("theme"); themeStr != "" {
+		theme = charts.Theme(themeStr)
+		if !charts.IsValidTheme(theme) {
+			return responses.BadRequestWithMessage(c, "invalid theme parameter: must be light or dark")
+		}
+	}
+
+	width, height := charts.DefaultWidth, charts.DefaultHeight
+	if widthStr := c.QueryParam("width"); widthStr != "" {
+		width, err = strconv.Atoi(widthStr)
+		if err != nil || width < charts.MinWidth || width > charts.MaxWidth {
+			return responses.BadRequestWithMessage(c, fmt.Sprintf("invalid width parameter: must be between %d and %d", charts.MinWidth, charts.MaxWidth))
+		}
+	}
+	if heightStr := c.QueryParam("height"); heightStr != "" {
+		height, err = strconv.Atoi(heightStr)
+		if err != nil || height < charts.MinHeight || height > charts.MaxHeight {
+			return responses.BadRequestWithMessage(c, fmt.Sprintf("invalid height parameter: must be between %d and %d", charts.MinHeight, charts.MaxHeight))
+		}
+	}
+
+	image, err := h.trendReportService.RenderChart(c.Request().Context(), requests.TrendReportChartRequest{
+		ReportID: id,
+		UserID:   claims.UserID,
+		Year:     year,
+		Type:     categoryType,
+		Format:   format,
+		Kind:     kind,
+		Width:    width,
+		Height:   height,
+		Theme:    theme,
+	})
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error rendering chart: %w", err))
+	}
+
+	c.Response().Header().Set("Cache-Control", "private, max-age=300")
+
+	return c.Blob(http.StatusOK, contentType, image)
+}
@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,6 +14,7 @@ import (
 	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
 	"github.com/emilijan-koteski/monexa/internal/middlewares"
 	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
 	"github.com/emilijan-koteski/monexa/internal/requests"
 	"github.com/emilijan-koteski/monexa/internal/services"
 	"github.com/labstack/echo/v4"
@@ -19,10 +23,14 @@ import (
 type userHandler struct {
 	userService   *services.UserService
 	exportService *services.ExportService
+	backupService *services.BackupService
 }
 
-func RegisterUserHandler(e *echo.Echo, userService *services.UserService, exportService *services.ExportService, restrictedMiddlewares ...echo.MiddlewareFunc) {
-	handler := &userHandler{userService: userService, exportService: exportService}
+// RegisterUserHandler registers the profile routes behind restrictedMiddlewares
+// and the data export and restore behind verifiedMiddlewares, since they hand
+// out and replace all data of the account.
+func RegisterUserHandler(e *echo.Echo, userService *services.UserService, exportService *services.ExportService, backupService *services.BackupService, restrictedMiddlewares, verifiedMiddlewares []echo.MiddlewareFunc) {
+	handler := &userHandler{userService: userService, exportService: exportService, backupService: backupService}
 
 	v1 := e.Group("/api/v1/users")
 
@@ -32,9 +40,22 @@ func RegisterUserHandler(e *echo.Echo, userService *services.UserService, export
 		r1.Use(m)
 	}
 
-	r1.GET("/me", handler.GetMe)
-	r1.PATCH("", handler.Update)
-	r1.GET("/data/export", handler.ExportData)
+	r2 := v1.Group("/data")
+	r2.Use(middlewares.AuthMiddleware())
+	for _, m := range verifiedMiddlewares {
+		r2.Use(m)
+	}
+
+	profileScopes := middlewares.ReadWriteScopeMiddleware(types.TokenScopeRecordsRead, types.TokenScopeRecordsWrite)
+	exportScope := middlewares.ScopeMiddleware(types.TokenScopeExport)
+
+	r1.GET("/me", handler.GetMe, profileScopes)
+	r1.PATCH("", handler.Update, profileScopes)
+	r2.GET("/export", handler.ExportData, exportScope)
+	// POST takes the same query parameters plus a passphrase in the body, which
+	// must not end up in URLs or access logs.
+	r2.POST("/export", handler.ExportData, exportScope)
+	r2.POST("/restore", handler.RestoreData)
 }
 
 func (h *userHandler) GetMe(c echo.Context) error {
@@ -76,12 +97,104 @@ func (h *userHandler) ExportData(c echo.Context) error {
 		return responses.UnauthorizedWithMessage(c, "not authenticated")
 	}
 
+	req, err := parseExportRequest(c, claims.UserID)
+	if err != nil {
+		return responses.BadRequestWithMessage(c, err.Error())
+	}
+
+	if c.Request().Method == http.MethodPost {
+		body := requests.ExportPassphraseRequest{}
+		if err := c.Bind(&body); err != nil {
+			return responses.BadRequestWithMessage(c, "invalid input")
+		}
+		if len([]rune(body.Passphrase)) < services.MinExportPassphraseLength {
+			return responses.BadRequestWithMessage(c, fmt.Sprintf("passphrase must be at least %d characters", services.MinExportPassphraseLength))
+		}
+		req.Passphrase = body.Passphrase
+	}
+
+	filename, contentType := services.ExportFileName(req.Format, time.Now())
+	if req.Passphrase != "" {
+		filename, contentType = services.EncryptedExportFileName(req.Format, time.Now()), "application/zip"
+	}
+
+	c.Response().Header().Set("Content-Type", contentType)
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	// The export is written to the response as it is produced, so its length is
+	// not known up front and a failure halfway can no longer change the status.
+	if err := h.exportService.ExportData(c.Request().Context(), req, c.Response()); err != nil {
+		if c.Response().Committed {
+			log.Printf("🛑 Error!!! export for user %d failed after the response started: %v", claims.UserID, err)
+			// Abort the connection so the client sees a failed download instead
+			// of a complete looking, truncated archive.
+			panic(http.ErrAbortHandler)
+		}
+		c.Response().Header().Del("Content-Type")
+		c.Response().Header().Del("Content-Disposition")
+		if errors.Is(err, services.ErrStatementTooLarge) {
+			return responses.BadRequestWithMessage(c, err.Error())
+		}
+		return responses.FailureWithError(c, fmt.Errorf("error exporting data: %w", err))
+	}
+
+	return nil
+}
+
+func (h *userHandler) RestoreData(c echo.Context) error {
+	claims, err := middlewares.GetUserClaims(c)
+	if err != nil {
+		return responses.UnauthorizedWithMessage(c, "not authenticated")
+	}
+
+	dryRun := false
+	if dryRunStr := c.QueryParam("dryRun"); dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			return responses.BadRequestWithMessage(c, "invalid dryRun parameter")
+		}
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return responses.BadRequestWithMessage(c, "backup file is required")
+	}
+	if fileHeader.Size > services.MaxBackupSize {
+		return responses.BadRequestWithMessage(c, "backup file is too large")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return responses.BadRequestWithMessage(c, "failed to read backup file")
+	}
+	defer file.Close()
+
+	archive, err := io.ReadAll(io.LimitReader(file, services.MaxBackupSize+1))
+	if err != nil {
+		return responses.BadRequestWithMessage(c, "failed to read backup file")
+	}
+
+	report, err := h.backupService.RestoreBackup(c.Request().Context(), requests.BackupRestoreRequest{
+		UserID:  claims.UserID,
+		Archive: archive,
+		DryRun:  dryRun,
+	})
+	if err != nil {
+		return responses.FailureWithError(c, fmt.Errorf("error restoring backup: %w", err))
+	}
+
+	return responses.SuccessWithData(c, report)
+}
+
+// parseExportRequest reads the export options shared by direct and queued exports
+// from the query string.
+func parseExportRequest(c echo.Context, userID uint) (requests.ExportRequest, error) {
 	format := dtotypes.ExportFormatType(c.QueryParam("format"))
 	if format == "" {
 		format = dtotypes.ExportFormatCSV
 	}
 	if !dtotypes.ValidExportFormats[format] {
-		return responses.BadRequestWithMessage(c, "invalid format, expected csv or json")
+		return requests.ExportRequest{}, errors.New("invalid format, expected csv, json, pdf, xlsx, ledger, hledger, beancount, ofx, qif or backup")
 	}
 
 	var categories []dtotypes.ExportCategoryType
@@ -89,7 +202,7 @@ func (h *userHandler) ExportData(c echo.Context) error {
 		for _, cat := range strings.Split(cats, ",") {
 			ec := dtotypes.ExportCategoryType(cat)
 			if !dtotypes.ValidExportCategories[ec] {
-				return responses.BadRequestWithMessage(c, fmt.Sprintf("invalid category: %s", cat))
+				return requests.ExportRequest{}, fmt.Errorf("invalid category: %s", cat)
 			}
 			categories = append(categories, ec)
 		}
@@ -100,7 +213,7 @@ func (h *userHandler) ExportData(c echo.Context) error {
 	if sd := c.QueryParam("startDate"); sd != "" {
 		parsed, err := time.Parse("2006-01-02", sd)
 		if err != nil {
-			return responses.BadRequestWithMessage(c, "invalid startDate format, expected YYYY-MM-DD")
+			return requests.ExportRequest{}, errors.New("invalid startDate format, expected YYYY-MM-DD")
 		}
 		startDate = &parsed
 	}
@@ -108,29 +221,16 @@ func (h *userHandler) ExportData(c echo.Context) error {
 	if ed := c.QueryParam("endDate"); ed != "" {
 		parsed, err := time.Parse("2006-01-02", ed)
 		if err != nil {
-			return responses.BadRequestWithMessage(c, "invalid endDate format, expected YYYY-MM-DD")
+			return requests.ExportRequest{}, errors.New("invalid endDate format, expected YYYY-MM-DD")
 		}
 		endDate = &parsed
 	}
 
-	req := requests.ExportRequest{
-		UserID:     claims.UserID,
+	return requests.ExportRequest{
+		UserID:     userID,
 		Format:     format,
 		Categories: categories,
 		StartDate:  startDate,
 		EndDate:    endDate,
-	}
-
-	zipBytes, err := h.exportService.ExportData(c.Request().Context(), req)
-	if err != nil {
-		return responses.FailureWithError(c, fmt.Errorf("error exporting data: %w", err))
-	}
-
-	filename := fmt.Sprintf("monexa-data-export-%s.zip", time.Now().Format("2006-01-02"))
-
-	c.Response().Header().Set("Content-Type", "application/zip")
-	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
-	c.Response().Header().Set("Content-Length", strconv.Itoa(len(zipBytes)))
-
-	return c.Blob(http.StatusOK, "application/zip", zipBytes)
+	}, nil
 }
@@ -55,11 +55,16 @@ func (j *AccountDeletionJob) cleanup() {
 	if err != nil {
 		log.Printf("Error fetching users for deletion reminder: %v", err)
 	} else {
+		queued := 0
 		for _, user := range reminderUsers {
-			j.userService.SendDeletionReminderEmail(ctx, user)
+			if err := j.userService.SendDeletionReminderEmail(ctx, user); err != nil {
+				log.Printf("Error queueing deletion reminder for user %d: %v", user.ID, err)
+				continue
+			}
+			queued++
 		}
-		if len(reminderUsers) > 0 {
-			log.Printf("Sent %d account deletion reminder email(s)", len(reminderUsers))
+		if queued > 0 {
+			log.Printf("Queued %d account deletion reminder email(s)", queued)
 		}
 	}
 
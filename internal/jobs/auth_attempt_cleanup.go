@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+)
+
+type AuthAttemptCleanupJob struct {
+	throttleService *services.AuthThrottleService
+	interval        time.Duration
+	stopCh          chan struct{}
+}
+
+func NewAuthAttemptCleanupJob(throttleService *services.AuthThrottleService, interval time.Duration) *AuthAttemptCleanupJob {
+	return &AuthAttemptCleanupJob{
+		throttleService: throttleService,
+		interval:        interval,
+		stopCh:          make(chan struct{}),
+	}
+}
+
+func (j *AuthAttemptCleanupJob) Start() {
+	go j.run()
+}
+
+func (j *AuthAttemptCleanupJob) Stop() {
+	close(j.stopCh)
+}
+
+func (j *AuthAttemptCleanupJob) run() {
+	j.cleanup()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.cleanup()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func (j *AuthAttemptCleanupJob) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := j.throttleService.CleanupAttempts(ctx)
+	if err != nil {
+		log.Printf("🛑 Error cleaning up auth attempts: %v", err)
+		return
+	}
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+)
+
+type DigestJob struct {
+	digestService *services.DigestService
+	interval      time.Duration
+	stopCh        chan struct{}
+}
+
+func NewDigestJob(digestService *services.DigestService, interval time.Duration) *DigestJob {
+	return &DigestJob{
+		digestService: digestService,
+		interval:      interval,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+func (j *DigestJob) Start() {
+	go j.run()
+}
+
+func (j *DigestJob) Stop() {
+	close(j.stopCh)
+}
+
+func (j *DigestJob) run() {
+	j.send()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.send()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func (j *DigestJob) send() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	sent, err := j.digestService.RunDueDigests(ctx, time.Now())
+	if err != nil {
+		log.Printf("🛑 Error sending digests: %v", err)
+		return
+	}
+	if sent > 0 {
+		log.Printf("Sent %d digest(s)", sent)
+	}
+}
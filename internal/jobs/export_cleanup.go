@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+)
+
+type ExportCleanupJob struct {
+	exportJobService *services.ExportJobService
+	interval         time.Duration
+	stopCh           chan struct{}
+}
+
+func NewExportCleanupJob(exportJobService *services.ExportJobService, interval time.Duration) *ExportCleanupJob {
+	return &ExportCleanupJob{
+		exportJobService: exportJobService,
+		interval:         interval,
+		stopCh:           make(chan struct{}),
+	}
+}
+
+func (j *ExportCleanupJob) Start() {
+	go j.run()
+}
+
+func (j *ExportCleanupJob) Stop() {
+	close(j.stopCh)
+}
+
+func (j *ExportCleanupJob) run() {
+	j.cleanup()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.cleanup()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func (j *ExportCleanupJob) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	_, err := j.exportJobService.CleanupExpiredExports(ctx)
+	if err != nil {
+		log.Printf("🛑 Error cleaning up expired exports: %v", err)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+)
+
+// ExportJobWorker generates queued exports. Every tick it drains the queue, one
+// job at a time.
+type ExportJobWorker struct {
+	exportJobService *services.ExportJobService
+	interval         time.Duration
+	stopCh           chan struct{}
+}
+
+func NewExportJobWorker(exportJobService *services.ExportJobService, interval time.Duration) *ExportJobWorker {
+	return &ExportJobWorker{
+		exportJobService: exportJobService,
+		interval:         interval,
+		stopCh:           make(chan struct{}),
+	}
+}
+
+func (j *ExportJobWorker) Start() {
+	go j.run()
+}
+
+func (j *ExportJobWorker) Stop() {
+	close(j.stopCh)
+}
+
+func (j *ExportJobWorker) run() {
+	j.process()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.process()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func (j *ExportJobWorker) process() {
+	for {
+		select {
+		case <-j.stopCh:
+			return
+		default:
+		}
+
+		if !j.processNext() {
+			return
+		}
+	}
+}
+
+func (j *ExportJobWorker) processNext() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), services.ExportJobTimeout)
+	defer cancel()
+
+	processed, err := j.exportJobService.ProcessNextJob(ctx)
+	if err != nil {
+		log.Printf("🛑 Error processing export job: %v", err)
+		return false
+	}
+	return processed
+}
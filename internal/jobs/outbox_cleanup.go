@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+)
+
+type OutboxCleanupJob struct {
+	outboxService *services.OutboxService
+	interval      time.Duration
+	stopCh        chan struct{}
+}
+
+func NewOutboxCleanupJob(outboxService *services.OutboxService, interval time.Duration) *OutboxCleanupJob {
+	return &OutboxCleanupJob{
+		outboxService: outboxService,
+		interval:      interval,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+func (j *OutboxCleanupJob) Start() {
+	go j.run()
+}
+
+func (j *OutboxCleanupJob) Stop() {
+	close(j.stopCh)
+}
+
+func (j *OutboxCleanupJob) run() {
+	j.cleanup()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.cleanup()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func (j *OutboxCleanupJob) cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := j.outboxService.CleanupSentEmails(ctx)
+	if err != nil {
+		log.Printf("🛑 Error cleaning up sent outbox emails: %v", err)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+)
+
+// OutboxWorker delivers queued emails. Every tick it sends all due emails, one
+// at a time.
+type OutboxWorker struct {
+	outboxService *services.OutboxService
+	interval      time.Duration
+	stopCh        chan struct{}
+}
+
+func NewOutboxWorker(outboxService *services.OutboxService, interval time.Duration) *OutboxWorker {
+	return &OutboxWorker{
+		outboxService: outboxService,
+		interval:      interval,
+		stopCh:        make(chan struct{}),
+	}
+}
+
+func (j *OutboxWorker) Start() {
+	go j.run()
+}
+
+func (j *OutboxWorker) Stop() {
+	close(j.stopCh)
+}
+
+func (j *OutboxWorker) run() {
+	j.process()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.process()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func (j *OutboxWorker) process() {
+	for {
+		select {
+		case <-j.stopCh:
+			return
+		default:
+		}
+
+		if !j.processNext() {
+			return
+		}
+	}
+}
+
+func (j *OutboxWorker) processNext() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	processed, err := j.outboxService.DeliverNext(ctx)
+	if err != nil {
+		log.Printf("🛑 Error delivering outbox email: %v", err)
+		return false
+	}
+	return processed
+}
@@ -55,4 +55,16 @@ func (j *ResetTokenCleanupJob) cleanup() {
 		log.Printf("🛑 Error cleaning up expired reset tokens: %v", err)
 		return
 	}
+
+	_, err = j.userService.CleanupExpiredEmailVerificationTokens(ctx)
+	if err != nil {
+		log.Printf("🛑 Error cleaning up expired email verification tokens: %v", err)
+		return
+	}
+
+	_, err = j.userService.CleanupExpiredEmailChangeRequests(ctx)
+	if err != nil {
+		log.Printf("🛑 Error cleaning up expired email change requests: %v", err)
+		return
+	}
 }
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+)
+
+type ScheduledExportJob struct {
+	exportScheduleService *services.ExportScheduleService
+	interval              time.Duration
+	stopCh                chan struct{}
+}
+
+func NewScheduledExportJob(exportScheduleService *services.ExportScheduleService, interval time.Duration) *ScheduledExportJob {
+	return &ScheduledExportJob{
+		exportScheduleService: exportScheduleService,
+		interval:              interval,
+		stopCh:                make(chan struct{}),
+	}
+}
+
+func (j *ScheduledExportJob) Start() {
+	go j.run()
+}
+
+func (j *ScheduledExportJob) Stop() {
+	close(j.stopCh)
+}
+
+func (j *ScheduledExportJob) run() {
+	j.deliver()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.deliver()
+		case <-j.stopCh:
+			return
+		}
+	}
+}
+
+func (j *ScheduledExportJob) deliver() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	delivered, err := j.exportScheduleService.RunDueSchedules(ctx, time.Now())
+	if err != nil {
+		log.Printf("🛑 Error running scheduled exports: %v", err)
+		return
+	}
+	if delivered > 0 {
+		log.Printf("Delivered %d scheduled export(s)", delivered)
+	}
+}
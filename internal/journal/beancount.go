@@ -0,0 +1,95 @@
+package journal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// WriteBeancount writes the journal in beancount syntax, including the open
+// and commodity directives beancount requires before first use.
+func WriteBeancount(w io.Writer, j Journal) error {
+	bw := bufio.NewWriter(w)
+
+	if j.Title != "" {
+		fmt.Fprintf(bw, "option \"title\" %s\n", beancountString(j.Title))
+	}
+	if j.OperatingCommodity != "" {
+		fmt.Fprintf(bw, "option \"operating_currency\" %s\n", beancountString(j.OperatingCommodity))
+	}
+	bw.WriteString("\n")
+
+	opened := j.firstDate().Format("2006-01-02")
+
+	for _, commodity := range j.commodities() {
+		fmt.Fprintf(bw, "%s commodity %s\n", opened, commodity)
+	}
+	bw.WriteString("\n")
+
+	for _, account := range j.accounts(beancountAccount) {
+		fmt.Fprintf(bw, "%s open %s\n", opened, account)
+	}
+	bw.WriteString("\n")
+
+	for _, p := range j.Prices {
+		fmt.Fprintf(bw, "%s price %s %s %s\n", p.Date.Format("2006-01-02"), p.Commodity, formatRate(p.Amount), p.Quote)
+	}
+	if len(j.Prices) > 0 {
+		bw.WriteString("\n")
+	}
+
+	err := j.eachTransaction(func(t Transaction) error {
+		fmt.Fprintf(bw, "%s * %s\n", t.Date.Format("2006-01-02"), beancountString(t.Payee))
+		if t.ID != "" {
+			fmt.Fprintf(bw, "  monexa-id: %s\n", beancountString(t.ID))
+		}
+		for _, p := range t.Postings {
+			fmt.Fprintf(bw, "  %-40s  %s %s\n", beancountAccount(p.Account), formatAmount(p.Amount), p.Commodity)
+		}
+		_, err := bw.WriteString("\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func beancountString(value string) string {
+	value = strings.ReplaceAll(singleLine(value), `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// beancountAccount joins account segments with colons. Beancount requires every
+// segment to start with an uppercase letter or digit and to contain only
+// letters, digits and dashes.
+func beancountAccount(segments []string) string {
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		var b strings.Builder
+		dash := false
+		for _, r := range segment {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune(r)
+				dash = false
+			} else if !dash && b.Len() > 0 {
+				b.WriteRune('-')
+				dash = true
+			}
+		}
+
+		runes := []rune(strings.TrimRight(b.String(), "-"))
+		if len(runes) == 0 {
+			runes = []rune("Unknown")
+		}
+		runes[0] = unicode.ToUpper(runes[0])
+		if !unicode.IsUpper(runes[0]) && !unicode.IsDigit(runes[0]) {
+			runes = append([]rune("X"), runes...)
+		}
+		parts = append(parts, string(runes))
+	}
+	return strings.Join(parts, ":")
+}
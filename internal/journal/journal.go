@@ -0,0 +1,123 @@
+package journal
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Account roots shared by all plain-text accounting formats. Beancount only
+// accepts these five top level names.
+const (
+	RootAssets   = "Assets"
+	RootIncome   = "Income"
+	RootExpenses = "Expenses"
+)
+
+type Posting struct {
+	// Account is the account path, e.g. {"Expenses", "Food"}. Writers join and
+	// sanitize the segments for their own syntax.
+	Account   []string
+	Amount    float64
+	Commodity string
+}
+
+type Transaction struct {
+	Date     time.Time
+	Payee    string
+	ID       string
+	Postings []Posting
+}
+
+type Price struct {
+	Date      time.Time
+	Commodity string
+	Amount    float64
+	Quote     string
+}
+
+// Journal is written header first, so the commodities, accounts and first date
+// its transactions use are given up front. The transactions themselves are
+// streamed and never held in memory as a whole.
+type Journal struct {
+	Title              string
+	OperatingCommodity string
+	Commodities        []string
+	Accounts           [][]string
+	// FirstDate is the date of the earliest transaction, zero without any.
+	FirstDate time.Time
+	Prices    []Price
+	// EachTransaction calls fn for every transaction in date order.
+	EachTransaction func(fn func(t Transaction) error) error
+}
+
+// commodities returns every commodity used in the journal, sorted.
+func (j Journal) commodities() []string {
+	set := make(map[string]bool)
+	if j.OperatingCommodity != "" {
+		set[j.OperatingCommodity] = true
+	}
+	for _, commodity := range j.Commodities {
+		set[commodity] = true
+	}
+	for _, p := range j.Prices {
+		set[p.Commodity] = true
+		set[p.Quote] = true
+	}
+	return sortedKeys(set)
+}
+
+// accounts returns every account used in the journal, formatted with the given
+// account function and sorted.
+func (j Journal) accounts(format func([]string) string) []string {
+	set := make(map[string]bool)
+	for _, account := range j.Accounts {
+		set[format(account)] = true
+	}
+	return sortedKeys(set)
+}
+
+// eachTransaction is EachTransaction for journals that may have none.
+func (j Journal) eachTransaction(fn func(t Transaction) error) error {
+	if j.EachTransaction == nil {
+		return nil
+	}
+	return j.EachTransaction(fn)
+}
+
+// firstDate returns the earliest date in the journal, or today for an empty one.
+func (j Journal) firstDate() time.Time {
+	first := j.FirstDate
+	for _, p := range j.Prices {
+		if first.IsZero() || p.Date.Before(first) {
+			first = p.Date
+		}
+	}
+	if first.IsZero() {
+		return time.Now().UTC()
+	}
+	return first
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// singleLine collapses all whitespace, including newlines, into single spaces.
+func singleLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
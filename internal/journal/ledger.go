@@ -0,0 +1,76 @@
+package journal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteLedger writes the journal in ledger-cli syntax.
+func WriteLedger(w io.Writer, j Journal) error {
+	return writeLedgerLike(w, j, "2006/01/02", "2006/01/02 15:04:05")
+}
+
+// WriteHledger writes the journal in hledger syntax, which differs from ledger
+// mainly in its ISO dates and date-only price directives.
+func WriteHledger(w io.Writer, j Journal) error {
+	return writeLedgerLike(w, j, "2006-01-02", "2006-01-02")
+}
+
+func writeLedgerLike(w io.Writer, j Journal, dateLayout, priceLayout string) error {
+	bw := bufio.NewWriter(w)
+
+	if j.Title != "" {
+		fmt.Fprintf(bw, "; %s\n\n", singleLine(j.Title))
+	}
+
+	for _, commodity := range j.commodities() {
+		fmt.Fprintf(bw, "commodity %s\n", commodity)
+	}
+	bw.WriteString("\n")
+
+	for _, account := range j.accounts(ledgerAccount) {
+		fmt.Fprintf(bw, "account %s\n", account)
+	}
+	bw.WriteString("\n")
+
+	for _, p := range j.Prices {
+		fmt.Fprintf(bw, "P %s %s %s %s\n", p.Date.Format(priceLayout), p.Commodity, formatRate(p.Amount), p.Quote)
+	}
+	if len(j.Prices) > 0 {
+		bw.WriteString("\n")
+	}
+
+	err := j.eachTransaction(func(t Transaction) error {
+		fmt.Fprintf(bw, "%s * %s\n", t.Date.Format(dateLayout), singleLine(t.Payee))
+		if t.ID != "" {
+			fmt.Fprintf(bw, "    ; monexa-id: %s\n", t.ID)
+		}
+		for _, p := range t.Postings {
+			fmt.Fprintf(bw, "    %-40s  %s %s\n", ledgerAccount(p.Account), formatAmount(p.Amount), p.Commodity)
+		}
+		_, err := bw.WriteString("\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// ledgerAccount joins account segments with colons. Colons inside a segment
+// would create extra levels and two spaces would end the account name, so both
+// are replaced.
+func ledgerAccount(segments []string) string {
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		segment = singleLine(strings.ReplaceAll(segment, ":", "-"))
+		if segment == "" {
+			segment = "Unknown"
+		}
+		parts = append(parts, segment)
+	}
+	return strings.Join(parts, ":")
+}
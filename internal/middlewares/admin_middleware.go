@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"os"
+	"strings"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminMiddleware lets through users whose verified email is listed in the
+// comma-separated ADMIN_EMAILS. Without verification anyone could register a
+// listed address that has no account yet. It has to run after AuthMiddleware.
+func AdminMiddleware(userService *services.UserService) echo.MiddlewareFunc {
+	admins := map[string]bool{}
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = utils.NormalizeEmail(email); email != "" {
+			admins[email] = true
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := GetUserClaims(c)
+			if err != nil {
+				return responses.UnauthorizedWithMessage(c, "not authenticated")
+			}
+
+			user, err := userService.GetUserByExample(c.Request().Context(), models.User{ID: claims.UserID})
+			if err != nil || user.EmailVerifiedAt == nil || !admins[utils.NormalizeEmail(user.Email)] {
+				return responses.ForbiddenWithMessage(c, "admin access required")
+			}
+
+			return next(c)
+		}
+	}
+}
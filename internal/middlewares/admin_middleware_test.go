@@ -0,0 +1,92 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/token"
+	"github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a throwaway SQLite database with the tables of the given
+// models.
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "monexa.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// serveAs runs the handler behind the middleware for a request authenticated
+// with an access token of the user and returns the status code.
+func serveAs(t *testing.T, middleware echo.MiddlewareFunc, userID uint) int {
+	t.Helper()
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	c.Set("user", &jwt.Token{Claims: &token.UserClaims{UserID: userID, TokenType: token.TokenTypeAccess}})
+
+	handler := middleware(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	if err := handler(c); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code
+}
+
+func TestAdminMiddlewareRequiresAVerifiedListedEmail(t *testing.T) {
+	t.Setenv("ADMIN_EMAILS", "admin@example.com, Boss@Example.com")
+	db := newTestDB(t, &models.User{})
+	middleware := AdminMiddleware(services.NewUserService(db, nil, nil, nil))
+
+	now := time.Now()
+	users := []models.User{
+		{Email: "admin@example.com", Password: "hash", Name: "Admin", PPID: "ppid-admin", EmailVerifiedAt: &now},
+		{Email: "boss@example.com", Password: "hash", Name: "Boss", PPID: "ppid-boss"},
+		{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana", EmailVerifiedAt: &now},
+	}
+	if err := db.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		user models.User
+		want int
+	}{
+		{"verified listed email", users[0], http.StatusOK},
+		{"unverified listed email", users[1], http.StatusForbidden},
+		{"verified unlisted email", users[2], http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveAs(t, middleware, tt.user.ID); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
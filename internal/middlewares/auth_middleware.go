@@ -1,27 +1,169 @@
 package middlewares
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/services"
 	"github.com/emilijan-koteski/monexa/internal/token"
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
-	"os"
 )
 
+const scopeGrantedKey = "tokenScopeGranted"
+
+var (
+	tokenMaker                 *token.JWTMaker
+	personalAccessTokenService *services.PersonalAccessTokenService
+	sessionService             *services.SessionService
+)
+
+// SetTokenMaker sets the JWTMaker whose keys AuthMiddleware verifies tokens
+// with. It has to be called before the handlers are registered.
+func SetTokenMaker(maker *token.JWTMaker) {
+	tokenMaker = maker
+}
+
+// EnablePersonalAccessTokens lets AuthMiddleware accept personal access tokens
+// next to JWTs. It has to be called before the handlers are registered.
+func EnablePersonalAccessTokens(service *services.PersonalAccessTokenService) {
+	personalAccessTokenService = service
+}
+
+// EnableAccessTokenRevocation makes AuthMiddleware reject access tokens whose
+// session ended or whose user had all tokens revoked. It has to be called
+// before the handlers are registered.
+func EnableAccessTokenRevocation(service *services.SessionService) {
+	sessionService = service
+}
+
 func AuthMiddleware() echo.MiddlewareFunc {
-	secretKey := os.Getenv("JWT_SECRET")
+	if tokenMaker == nil {
+		panic("middlewares: SetTokenMaker has to be called before AuthMiddleware")
+	}
 
 	config := echojwt.Config{
-		SigningKey: []byte(secretKey),
+		KeyFunc: tokenMaker.Keyfunc,
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return &token.UserClaims{}
 		},
 	}
 
-	return echojwt.WithConfig(config)
+	jwtMiddleware := echojwt.WithConfig(config)
+	service := personalAccessTokenService
+	sessions := sessionService
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		jwtNext := jwtMiddleware(rejectRevokedTokens(sessions, next))
+
+		return func(c echo.Context) error {
+			plain, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || service == nil || !strings.HasPrefix(plain, services.PersonalAccessTokenPrefix) {
+				return jwtNext(c)
+			}
+
+			record, user, err := service.Authenticate(c.Request().Context(), plain)
+			if err != nil {
+				if errors.Is(err, services.ErrInvalidPersonalAccessToken) {
+					return responses.UnauthorizedWithMessage(c, err.Error())
+				}
+				return responses.FailureWithError(c, fmt.Errorf("error verifying personal access token: %w", err))
+			}
+
+			scopes := make([]string, 0, len(record.Scopes))
+			for _, scope := range record.Scopes {
+				scopes = append(scopes, string(scope))
+			}
+
+			c.Set("user", &jwt.Token{
+				Valid: true,
+				Claims: &token.UserClaims{
+					UserID:    user.ID,
+					TokenType: token.TokenTypePersonalAccess,
+					Scopes:    scopes,
+					RegisteredClaims: jwt.RegisteredClaims{
+						ID:        fmt.Sprintf("pat-%d", record.ID),
+						Subject:   user.PPID,
+						ExpiresAt: jwt.NewNumericDate(record.ExpiresAt),
+					},
+				},
+			})
+
+			return next(c)
+		}
+	}
 }
 
+// rejectRevokedTokens runs after the signature check, so only well formed
+// access tokens cost a revocation lookup.
+func rejectRevokedTokens(sessions *services.SessionService, next echo.HandlerFunc) echo.HandlerFunc {
+	if sessions == nil {
+		return next
+	}
+
+	return func(c echo.Context) error {
+		claims := extractClaims(c)
+		if claims == nil || claims.TokenType != token.TokenTypeAccess {
+			return next(c)
+		}
+
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+
+		revoked, err := sessions.IsAccessTokenRevoked(c.Request().Context(), claims.UserID, claims.TokenFamily, issuedAt)
+		if err != nil {
+			return responses.FailureWithError(c, fmt.Errorf("error checking token revocation: %w", err))
+		}
+		if revoked {
+			return responses.UnauthorizedWithMessage(c, "token has been revoked")
+		}
+
+		return next(c)
+	}
+}
+
+// ScopeMiddleware lets personal access tokens with the given scope through.
+// Sessions are not limited by scopes.
+func ScopeMiddleware(scope types.TokenScope) echo.MiddlewareFunc {
+	return ReadWriteScopeMiddleware(scope, scope)
+}
+
+// ReadWriteScopeMiddleware requires readScope for GET and HEAD requests and
+// writeScope for everything else.
+func ReadWriteScopeMiddleware(readScope, writeScope types.TokenScope) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := extractClaims(c)
+			if claims == nil || claims.TokenType != token.TokenTypePersonalAccess {
+				return next(c)
+			}
+
+			required := writeScope
+			if method := c.Request().Method; method == http.MethodGet || method == http.MethodHead {
+				required = readScope
+			}
+			if !slices.Contains(claims.Scopes, string(required)) {
+				return responses.ForbiddenWithMessage(c, fmt.Sprintf("token is missing the %s scope", required))
+			}
+
+			c.Set(scopeGrantedKey, true)
+			return next(c)
+		}
+	}
+}
+
+// GetUserClaims returns the claims of a session access token, or of a personal
+// access token once a scope middleware allowed it for the route. Routes
+// without a scope middleware are closed to personal access tokens.
 func GetUserClaims(c echo.Context) (*token.UserClaims, error) {
 	user := c.Get("user").(*jwt.Token)
 	if user == nil {
@@ -33,6 +175,13 @@ func GetUserClaims(c echo.Context) (*token.UserClaims, error) {
 		return nil, fmt.Errorf("invalid token claims")
 	}
 
+	if claims.TokenType == token.TokenTypePersonalAccess {
+		if granted, _ := c.Get(scopeGrantedKey).(bool); granted {
+			return claims, nil
+		}
+		return nil, fmt.Errorf("personal access tokens are not allowed here")
+	}
+
 	if claims.TokenType != token.TokenTypeAccess {
 		return nil, fmt.Errorf("invalid token type")
 	}
@@ -0,0 +1,196 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/emilijan-koteski/monexa/internal/token"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// testSession is models.Session without the Postgres column default, which
+// SQLite cannot parse.
+type testSession struct {
+	ID           string `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    *time.Time
+	UserID       uint   `gorm:"not null;index"`
+	TokenFamily  string `gorm:"not null;index"`
+	RefreshToken string `gorm:"not null"`
+	IsRevoked    bool   `gorm:"not null"`
+	ExpiresAt    time.Time
+	SignedInAt   time.Time
+	LastUsedAt   *time.Time
+	UserAgent    string
+	IPAddress    string
+	DeviceName   string
+}
+
+func (testSession) TableName() string {
+	return "sessions"
+}
+
+// useTestAuth configures AuthMiddleware the way main does, against a fresh
+// database, and returns the database, the token maker and the session service.
+func useTestAuth(t *testing.T) (*gorm.DB, *token.JWTMaker, *services.SessionService) {
+	t.Helper()
+	t.Setenv("JWT_KEY_ENCRYPTION_KEY", "test-encryption-key-that-is-long-enough")
+
+	db := newTestDB(t, &models.User{}, &models.PersonalAccessToken{}, &models.SigningKey{}, &testSession{})
+	signingKeyService := services.NewSigningKeyService(db)
+	if err := signingKeyService.EnsureSigningKey(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	maker := token.NewJWTMaker(signingKeyService)
+	sessions := services.NewSessionService(db)
+
+	SetTokenMaker(maker)
+	EnablePersonalAccessTokens(services.NewPersonalAccessTokenService(db))
+	EnableAccessTokenRevocation(sessions)
+	t.Cleanup(func() {
+		SetTokenMaker(nil)
+		EnablePersonalAccessTokens(nil)
+		EnableAccessTokenRevocation(nil)
+	})
+	return db, maker, sessions
+}
+
+// serveWithToken sends a request with the bearer token through the
+// middlewares to a handler that answers with the user of GetUserClaims.
+func serveWithToken(t *testing.T, method, bearer string, chain ...echo.MiddlewareFunc) int {
+	t.Helper()
+
+	handler := func(c echo.Context) error {
+		claims, err := GetUserClaims(c)
+		if err != nil {
+			return responses.UnauthorizedWithMessage(c, err.Error())
+		}
+		return c.JSON(http.StatusOK, claims.UserID)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+bearer)
+	rec := httptest.NewRecorder()
+	if err := handler(e.NewContext(req, rec)); err != nil {
+		e.HTTPErrorHandler(err, e.NewContext(req, rec))
+	}
+	return rec.Code
+}
+
+func TestPersonalAccessTokensNeedTheScopeOfTheRoute(t *testing.T) {
+	db, _, _ := useTestAuth(t)
+	user := models.User{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	patService := services.NewPersonalAccessTokenService(db)
+	created, err := patService.Create(context.Background(), requests.PersonalAccessTokenRequest{
+		UserID: user.ID,
+		Name:   "MCP",
+		Scopes: []types.TokenScope{types.TokenScopeRecordsRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := ReadWriteScopeMiddleware(types.TokenScopeRecordsRead, types.TokenScopeRecordsWrite)
+	tests := []struct {
+		name   string
+		method string
+		bearer string
+		chain  []echo.MiddlewareFunc
+		want   int
+	}{
+		{"granted scope", http.MethodGet, created.Token, []echo.MiddlewareFunc{AuthMiddleware(), records}, http.StatusOK},
+		{"missing scope", http.MethodPost, created.Token, []echo.MiddlewareFunc{AuthMiddleware(), records}, http.StatusForbidden},
+		{"other scope", http.MethodGet, created.Token, []echo.MiddlewareFunc{AuthMiddleware(), ScopeMiddleware(types.TokenScopeExport)}, http.StatusForbidden},
+		{"route without scopes", http.MethodGet, created.Token, []echo.MiddlewareFunc{AuthMiddleware()}, http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, created.Token + "x", []echo.MiddlewareFunc{AuthMiddleware(), records}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serveWithToken(t, tt.method, tt.bearer, tt.chain...); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if err := patService.Revoke(context.Background(), user.ID, created.PersonalAccessToken.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := serveWithToken(t, http.MethodGet, created.Token, AuthMiddleware(), records); got != http.StatusUnauthorized {
+		t.Errorf("status with a revoked token = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthMiddlewareRejectsRevokedAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	db, maker, sessions := useTestAuth(t)
+	user := models.User{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	signIn := func(family string) (string, *models.Session) {
+		t.Helper()
+		accessToken, _, err := maker.CreateAccessToken(user.ID, user.PPID, family, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := sessions.CreateSessionFromExample(ctx, models.Session{
+			ID:           family + "-session",
+			UserID:       user.ID,
+			TokenFamily:  family,
+			RefreshToken: family + "-refresh-token",
+			ExpiresAt:    time.Now().Add(time.Hour),
+			SignedInAt:   time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return accessToken, session
+	}
+
+	laptop, laptopSession := signIn("laptop")
+	phone, _ := signIn("phone")
+	for _, accessToken := range []string{laptop, phone} {
+		if got := serveWithToken(t, http.MethodGet, accessToken, AuthMiddleware()); got != http.StatusOK {
+			t.Fatalf("status before revocation = %d, want %d", got, http.StatusOK)
+		}
+	}
+
+	// Logging out of one session ends the access tokens of its family only.
+	if err := sessions.DeleteSession(ctx, requests.RefreshTokenRequest{RefreshToken: laptopSession.RefreshToken}); err != nil {
+		t.Fatal(err)
+	}
+	if got := serveWithToken(t, http.MethodGet, laptop, AuthMiddleware()); got != http.StatusUnauthorized {
+		t.Errorf("status after logging out = %d, want %d", got, http.StatusUnauthorized)
+	}
+	if got := serveWithToken(t, http.MethodGet, phone, AuthMiddleware()); got != http.StatusOK {
+		t.Errorf("status of another session after logging out = %d, want %d", got, http.StatusOK)
+	}
+
+	// Tokens issued in the second of the revocation stay valid, so wait for
+	// the next one.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	if err := sessions.RevokeAllUserSessions(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := serveWithToken(t, http.MethodGet, phone, AuthMiddleware()); got != http.StatusUnauthorized {
+		t.Errorf("status after revoking all tokens = %d, want %d", got, http.StatusUnauthorized)
+	}
+}
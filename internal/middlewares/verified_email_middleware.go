@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"fmt"
+
+	"github.com/emilijan-koteski/monexa/internal/handlers/responses"
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+// VerifiedEmailMiddleware keeps accounts with an unverified email address away
+// from features that send mail to that address, and from exporting and
+// restoring all data of the account.
+func VerifiedEmailMiddleware(userService *services.UserService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := extractClaims(c)
+			if claims == nil {
+				return next(c)
+			}
+
+			verified, err := userService.IsEmailVerified(c.Request().Context(), claims.UserID)
+			if err != nil {
+				return responses.FailureWithError(c, fmt.Errorf("error checking email verification: %w", err))
+			}
+			if !verified {
+				return responses.ForbiddenWithMessage(c, "verify your email address to use this feature")
+			}
+
+			return next(c)
+		}
+	}
+}
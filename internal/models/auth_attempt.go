@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// AuthAttempt is one counted attempt of a throttled auth action. Key names the
+// action and who made it, account emails are only stored hashed.
+type AuthAttempt struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Key         string    `gorm:"not null;index:idx_auth_attempts_key_attempted_at,priority:1" json:"key"`
+	AttemptedAt time.Time `gorm:"not null;index:idx_auth_attempts_key_attempted_at,priority:2" json:"attemptedAt"`
+}
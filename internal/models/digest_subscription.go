@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+// DigestSubscription opts a user into digest emails. LastPeriod holds the last
+// period that was sent, "2006-W01" for weekly and "2006-01" for monthly digests.
+type DigestSubscription struct {
+	ID         uint                      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time                 `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time                 `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID     uint                      `gorm:"not null;uniqueIndex" json:"userId"`
+	Frequency  types.DigestFrequencyType `gorm:"not null" json:"frequency"`
+	LastPeriod *string                   `json:"lastPeriod"`
+	LastSentAt *time.Time                `json:"lastSentAt"`
+}
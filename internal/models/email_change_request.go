@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// EmailChangeRequest is a pending change of the account email. The new address
+// confirms it, the old one can cancel it until CancelExpiresAt, which reverts
+// a change that was already confirmed.
+type EmailChangeRequest struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID           uint       `gorm:"not null;index" json:"userId"`
+	OldEmail         string     `gorm:"not null" json:"oldEmail"`
+	NewEmail         string     `gorm:"not null" json:"newEmail"`
+	ConfirmTokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	CancelTokenHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	ConfirmExpiresAt time.Time  `gorm:"not null" json:"confirmExpiresAt"`
+	CancelExpiresAt  time.Time  `gorm:"not null" json:"cancelExpiresAt"`
+	ConfirmedAt      *time.Time `json:"confirmedAt"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// EmailVerificationToken proves that Email belongs to the user. The token only
+// verifies the address it was sent to.
+type EmailVerificationToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	Email     string     `gorm:"not null" json:"email"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+}
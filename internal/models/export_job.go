@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+type ExportJob struct {
+	ID          uint                  `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time             `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time             `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID      uint                  `gorm:"not null;index" json:"userId"`
+	Status      types.ExportJobStatus `gorm:"not null;index" json:"status"`
+	Format      string                `gorm:"not null" json:"format"`
+	Categories  []string              `gorm:"serializer:json" json:"categories"`
+	StartDate   *time.Time            `json:"startDate"`
+	EndDate     *time.Time            `json:"endDate"`
+	StorageKey  string                `json:"-"`
+	FileName    string                `json:"fileName"`
+	Size        int64                 `gorm:"not null;default:0" json:"size"`
+	Error       *string               `json:"error"`
+	StartedAt   *time.Time            `json:"startedAt"`
+	CompletedAt *time.Time            `json:"completedAt"`
+	ExpiresAt   *time.Time            `gorm:"index" json:"expiresAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+// ExportSchedule is a user's monthly export of the previous month's records.
+// LastPeriod holds the last month ("2006-01") that was delivered.
+type ExportSchedule struct {
+	ID         uint                     `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time                `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time                `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID     uint                     `gorm:"not null;uniqueIndex" json:"userId"`
+	Format     string                   `gorm:"not null" json:"format"`
+	Delivery   types.ExportDeliveryType `gorm:"not null" json:"delivery"`
+	Enabled    bool                     `gorm:"not null;default:true" json:"enabled"`
+	LastPeriod *string                  `json:"lastPeriod"`
+	LastRunAt  *time.Time               `json:"lastRunAt"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// MFAChallenge is the pending second step of a login with two-factor
+// authentication. ID is the ID of the challenge token, which can be answered
+// once.
+type MFAChallenge struct {
+	ID        string    `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UserID    uint      `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
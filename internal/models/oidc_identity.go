@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// OIDCIdentity links an account at an OpenID Connect provider, identified by
+// the issuer subject, to a user.
+type OIDCIdentity struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID     uint       `gorm:"not null;index" json:"userId"`
+	Provider   string     `gorm:"not null;uniqueIndex:idx_oidc_identities_provider_subject" json:"provider"`
+	Subject    string     `gorm:"not null;uniqueIndex:idx_oidc_identities_provider_subject" json:"-"`
+	Email      string     `gorm:"not null" json:"email"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OIDCState keeps the state, nonce and PKCE verifier of an authorization
+// request until the provider redirects back. Each state can be used once.
+type OIDCState struct {
+	ID           string    `gorm:"primaryKey"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	Provider     string    `gorm:"not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"`
+	UserID       *uint     `gorm:"index"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
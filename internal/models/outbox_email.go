@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+type OutboxEmail struct {
+	ID            uint                    `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time               `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt     time.Time               `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID        *uint                   `gorm:"index" json:"userId"`
+	Template      string                  `gorm:"not null" json:"template"`
+	Recipient     string                  `gorm:"not null" json:"recipient"`
+	Subject       string                  `gorm:"not null" json:"subject"`
+	Body          string                  `gorm:"type:text;not null" json:"-"`
+	Status        types.OutboxEmailStatus `gorm:"not null;index" json:"status"`
+	Attempts      int                     `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time               `gorm:"not null;index" json:"nextAttemptAt"`
+	LastError     *string                 `json:"lastError"`
+	SentAt        *time.Time              `json:"sentAt"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// PasskeyCeremony keeps the challenge of a WebAuthn registration or login
+// between its begin and finish requests. Each ceremony can be finished once.
+type PasskeyCeremony struct {
+	ID        string               `gorm:"primaryKey"`
+	CreatedAt time.Time            `gorm:"autoCreateTime"`
+	UserID    *uint                `gorm:"index"`
+	Session   webauthn.SessionData `gorm:"serializer:json;not null"`
+	ExpiresAt time.Time            `gorm:"not null;index"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+type PasskeyCredential struct {
+	ID           uint                `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time           `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt    time.Time           `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID       uint                `gorm:"not null;index" json:"userId"`
+	CredentialID []byte              `gorm:"not null;uniqueIndex" json:"-"`
+	Name         string              `gorm:"not null" json:"name"`
+	Credential   webauthn.Credential `gorm:"serializer:json;not null" json:"-"`
+	SignCount    uint32              `gorm:"not null;default:0" json:"signCount"`
+	LastUsedAt   *time.Time          `json:"lastUsedAt"`
+}
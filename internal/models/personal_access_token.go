@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+// PersonalAccessToken lets API clients such as the MCP server act for a user
+// without their password. Only the hash of the token is stored.
+type PersonalAccessToken struct {
+	ID         uint               `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time          `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time          `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID     uint               `gorm:"not null;index" json:"userId"`
+	Name       string             `gorm:"not null" json:"name"`
+	Prefix     string             `gorm:"not null" json:"prefix"`
+	TokenHash  string             `gorm:"not null;uniqueIndex" json:"-"`
+	Scopes     []types.TokenScope `gorm:"serializer:json;not null" json:"scopes"`
+	ExpiresAt  time.Time          `gorm:"not null" json:"expiresAt"`
+	LastUsedAt *time.Time         `json:"lastUsedAt"`
+}
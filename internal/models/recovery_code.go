@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UserID    uint       `gorm:"not null;index" json:"userId"`
+	CodeHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	UsedAt    *time.Time `json:"usedAt"`
+}
@@ -11,4 +11,9 @@ type Session struct {
 	RefreshToken string     `gorm:"not null" json:"refreshToken"`
 	IsRevoked    bool       `gorm:"not null" json:"isRevoked"`
 	ExpiresAt    time.Time  `gorm:"not null" json:"expiresAt"`
+	SignedInAt   time.Time  `gorm:"not null;default:now()" json:"signedInAt"`
+	LastUsedAt   *time.Time `json:"lastUsedAt"`
+	UserAgent    string     `gorm:"not null;default:''" json:"userAgent"`
+	IPAddress    string     `gorm:"not null;default:''" json:"ipAddress"`
+	DeviceName   string     `gorm:"not null;default:''" json:"deviceName"`
 }
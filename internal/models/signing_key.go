@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SigningKey is a key pair that signs JWTs. The private key is encrypted at
+// rest and cleared once the key is retired. Retired rows are kept because the
+// oldest key marks the end of tokens signed with JWT_SECRET.
+type SigningKey struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+	Kid         string     `gorm:"not null;uniqueIndex" json:"kid"`
+	Algorithm   string     `gorm:"not null" json:"algorithm"`
+	PrivateKey  string     `gorm:"not null" json:"-"`
+	ActivatesAt time.Time  `gorm:"not null;index" json:"activatesAt"`
+	RetiredAt   *time.Time `json:"retiredAt"`
+}
@@ -15,6 +15,10 @@ type TrendReport struct {
 	Title       *string        `json:"title"`
 	Description *string        `json:"description"`
 	Color       *string        `json:"color"`
+	Search      *string        `json:"search"`
+	MinAmount   *float64       `json:"minAmount"`
+	MaxAmount   *float64       `json:"maxAmount"`
 
-	Categories []Category `gorm:"many2many:trend_report_categories;" json:"categories"`
+	Categories     []Category      `gorm:"many2many:trend_report_categories;" json:"categories"`
+	PaymentMethods []PaymentMethod `gorm:"many2many:trend_report_payment_methods;" json:"paymentMethods"`
 }
@@ -0,0 +1,6 @@
+package models
+
+type TrendReportPaymentMethod struct {
+	TrendReportID   uint `gorm:"primaryKey"`
+	PaymentMethodID uint `gorm:"primaryKey"`
+}
@@ -0,0 +1,17 @@
+package types
+
+type DigestFrequencyType string
+
+const (
+	DigestWeekly  DigestFrequencyType = "WEEKLY"
+	DigestMonthly DigestFrequencyType = "MONTHLY"
+)
+
+func IsValidDigestFrequencyType(frequency DigestFrequencyType) bool {
+	switch frequency {
+	case DigestWeekly, DigestMonthly:
+		return true
+	default:
+		return false
+	}
+}
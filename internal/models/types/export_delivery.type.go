@@ -0,0 +1,17 @@
+package types
+
+type ExportDeliveryType string
+
+const (
+	ExportDeliveryAttachment ExportDeliveryType = "ATTACHMENT"
+	ExportDeliveryLink       ExportDeliveryType = "LINK"
+)
+
+func IsValidExportDeliveryType(delivery ExportDeliveryType) bool {
+	switch delivery {
+	case ExportDeliveryAttachment, ExportDeliveryLink:
+		return true
+	default:
+		return false
+	}
+}
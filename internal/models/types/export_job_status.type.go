@@ -0,0 +1,20 @@
+package types
+
+type ExportJobStatus string
+
+const (
+	ExportJobPending    ExportJobStatus = "PENDING"
+	ExportJobProcessing ExportJobStatus = "PROCESSING"
+	ExportJobCompleted  ExportJobStatus = "COMPLETED"
+	ExportJobFailed     ExportJobStatus = "FAILED"
+	ExportJobExpired    ExportJobStatus = "EXPIRED"
+)
+
+func IsValidExportJobStatus(status ExportJobStatus) bool {
+	switch status {
+	case ExportJobPending, ExportJobProcessing, ExportJobCompleted, ExportJobFailed, ExportJobExpired:
+		return true
+	default:
+		return false
+	}
+}
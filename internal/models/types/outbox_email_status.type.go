@@ -0,0 +1,18 @@
+package types
+
+type OutboxEmailStatus string
+
+const (
+	OutboxEmailPending OutboxEmailStatus = "PENDING"
+	OutboxEmailSent    OutboxEmailStatus = "SENT"
+	OutboxEmailDead    OutboxEmailStatus = "DEAD"
+)
+
+func IsValidOutboxEmailStatus(status OutboxEmailStatus) bool {
+	switch status {
+	case OutboxEmailPending, OutboxEmailSent, OutboxEmailDead:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,21 @@
+package types
+
+// TokenScope limits what a personal access token can do. The records scopes
+// also cover the categories, payment methods, settings and profile that
+// records are read and written with.
+type TokenScope string
+
+const (
+	TokenScopeRecordsRead  TokenScope = "records:read"
+	TokenScopeRecordsWrite TokenScope = "records:write"
+	TokenScopeExport       TokenScope = "export"
+)
+
+func IsValidTokenScope(scope TokenScope) bool {
+	switch scope {
+	case TokenScopeRecordsRead, TokenScopeRecordsWrite, TokenScopeExport:
+		return true
+	default:
+		return false
+	}
+}
@@ -15,4 +15,8 @@ type User struct {
 	Email     string         `gorm:"not null" json:"email"`
 	Password  string         `gorm:"not null" json:"-"`
 	Name      string         `gorm:"not null" json:"name"`
+	// EmailVerifiedAt is set once the owner of Email confirmed it.
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	// TokensRevokedAt rejects access tokens issued before it.
+	TokensRevokedAt *time.Time `json:"-"`
 }
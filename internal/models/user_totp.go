@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// UserTOTP holds the authenticator secret of a user, encrypted at rest. A
+// secret in PendingSecret waits for its first code before it replaces Secret.
+type UserTOTP struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+	UserID        uint       `gorm:"not null;uniqueIndex" json:"userId"`
+	Secret        *string    `json:"-"`
+	PendingSecret *string    `json:"-"`
+	LastUsedStep  int64      `gorm:"not null;default:0" json:"-"`
+	EnabledAt     *time.Time `json:"enabledAt"`
+}
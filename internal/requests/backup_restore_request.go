@@ -0,0 +1,7 @@
+package requests
+
+type BackupRestoreRequest struct {
+	UserID  uint
+	Archive []byte
+	DryRun  bool
+}
@@ -0,0 +1,10 @@
+package requests
+
+type ChangeEmailRequest struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewEmail        string `json:"newEmail" validate:"required,email"`
+}
+
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,12 @@
+package requests
+
+import "github.com/emilijan-koteski/monexa/internal/models/types"
+
+type DigestSubscriptionRequest struct {
+	UserID    uint                      `json:"-"`
+	Frequency types.DigestFrequencyType `json:"frequency" validate:"required"`
+}
+
+type DigestUnsubscribeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
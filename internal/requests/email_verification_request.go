@@ -0,0 +1,5 @@
+package requests
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,5 @@
+package requests
+
+type ExportPassphraseRequest struct {
+	Passphrase string `json:"passphrase" validate:"required"`
+}
@@ -12,4 +12,6 @@ type ExportRequest struct {
 	Categories []dtotypes.ExportCategoryType
 	StartDate  *time.Time
 	EndDate    *time.Time
+	// Passphrase, when set, encrypts the export. It is never stored.
+	Passphrase string
 }
@@ -0,0 +1,10 @@
+package requests
+
+import "github.com/emilijan-koteski/monexa/internal/models/types"
+
+type ExportScheduleRequest struct {
+	UserID   uint                     `json:"-"`
+	Format   string                   `json:"format" validate:"required"`
+	Delivery types.ExportDeliveryType `json:"delivery" validate:"required"`
+	Enabled  *bool                    `json:"enabled"`
+}
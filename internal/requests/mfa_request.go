@@ -0,0 +1,19 @@
+package requests
+
+// MFALoginRequest completes a login with the challenge token from the first
+// step and either an authenticator code or a recovery code.
+type MFALoginRequest struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type MFAConfirmRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFAReauthRequest proves that the caller still holds the password and the
+// second factor before 2FA is changed.
+type MFAReauthRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
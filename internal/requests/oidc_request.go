@@ -0,0 +1,8 @@
+package requests
+
+// OIDCCallbackRequest carries the query parameters the provider appended to
+// the redirect URI.
+type OIDCCallbackRequest struct {
+	State string `json:"state" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
@@ -0,0 +1,11 @@
+package requests
+
+import "encoding/json"
+
+// PasskeyFinishRequest carries the PublicKeyCredential returned by
+// navigator.credentials.create or .get, serialized as JSON.
+type PasskeyFinishRequest struct {
+	CeremonyID string          `json:"ceremonyId" validate:"required"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
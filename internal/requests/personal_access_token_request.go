@@ -0,0 +1,10 @@
+package requests
+
+import "github.com/emilijan-koteski/monexa/internal/models/types"
+
+type PersonalAccessTokenRequest struct {
+	UserID        uint               `json:"-"`
+	Name          string             `json:"name" validate:"required"`
+	Scopes        []types.TokenScope `json:"scopes" validate:"required"`
+	ExpiresInDays int                `json:"expiresInDays"`
+}
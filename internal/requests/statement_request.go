@@ -0,0 +1,10 @@
+package requests
+
+import dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+
+type StatementRequest struct {
+	UserID uint
+	Period dtotypes.StatementPeriodType
+	Year   int
+	Month  int
+}
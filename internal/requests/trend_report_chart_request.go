@@ -0,0 +1,18 @@
+package requests
+
+import (
+	"github.com/emilijan-koteski/monexa/internal/charts"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+type TrendReportChartRequest struct {
+	ReportID uint
+	UserID   uint
+	Year     int
+	Type     *types.CategoryType
+	Format   charts.Format
+	Kind     charts.Kind
+	Width    int
+	Height   int
+	Theme    charts.Theme
+}
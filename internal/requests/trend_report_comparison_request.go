@@ -0,0 +1,17 @@
+package requests
+
+import (
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+type TrendReportComparisonRequest struct {
+	ReportID   uint
+	UserID     uint
+	Year       int
+	StartMonth int
+	EndMonth   int
+	Mode       dtotypes.TrendComparisonModeType
+	Periods    int
+	Type       *types.CategoryType
+}
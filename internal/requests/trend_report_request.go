@@ -1,10 +1,14 @@
 package requests
 
 type TrendReportRequest struct {
-	ID          *uint
-	UserID      *uint
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Color       *string `json:"color"`
-	CategoryIDs []uint  `json:"categoryIds"`
+	ID               *uint
+	UserID           *uint
+	Title            *string  `json:"title"`
+	Description      *string  `json:"description"`
+	Color            *string  `json:"color"`
+	CategoryIDs      []uint   `json:"categoryIds"`
+	PaymentMethodIDs []uint   `json:"paymentMethodIds"`
+	Search           *string  `json:"search"`
+	MinAmount        *float64 `json:"minAmount"`
+	MaxAmount        *float64 `json:"maxAmount"`
 }
@@ -0,0 +1,20 @@
+package responses
+
+type BackupRestoreCounts struct {
+	Categories     int  `json:"categories"`
+	PaymentMethods int  `json:"paymentMethods"`
+	Records        int  `json:"records"`
+	TrendReports   int  `json:"trendReports"`
+	Settings       bool `json:"settings"`
+}
+
+type BackupRestoreReport struct {
+	Valid         bool                `json:"valid"`
+	DryRun        bool                `json:"dryRun"`
+	Restored      bool                `json:"restored"`
+	SchemaVersion int                 `json:"schemaVersion"`
+	Errors        []string            `json:"errors"`
+	Warnings      []string            `json:"warnings"`
+	Backup        BackupRestoreCounts `json:"backup"`
+	Replaced      BackupRestoreCounts `json:"replaced"`
+}
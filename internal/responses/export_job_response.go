@@ -0,0 +1,24 @@
+package responses
+
+import (
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+type ExportJobResponse struct {
+	ID          uint                  `json:"id"`
+	Status      types.ExportJobStatus `json:"status"`
+	Format      string                `json:"format"`
+	Categories  []string              `json:"categories"`
+	StartDate   *time.Time            `json:"startDate"`
+	EndDate     *time.Time            `json:"endDate"`
+	FileName    string                `json:"fileName"`
+	Size        int64                 `json:"size"`
+	Error       *string               `json:"error"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	StartedAt   *time.Time            `json:"startedAt"`
+	CompletedAt *time.Time            `json:"completedAt"`
+	ExpiresAt   *time.Time            `json:"expiresAt"`
+	DownloadURL *string               `json:"downloadUrl"`
+}
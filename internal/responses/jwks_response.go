@@ -0,0 +1,7 @@
+package responses
+
+import "github.com/emilijan-koteski/monexa/internal/token"
+
+type JWKSResponse struct {
+	Keys []token.JWK `json:"keys"`
+}
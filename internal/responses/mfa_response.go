@@ -0,0 +1,19 @@
+package responses
+
+import "time"
+
+type MFAStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabledAt"`
+	PendingSetup           bool       `json:"pendingSetup"`
+	RecoveryCodesRemaining int64      `json:"recoveryCodesRemaining"`
+}
+
+type TOTPSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
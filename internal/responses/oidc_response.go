@@ -0,0 +1,11 @@
+package responses
+
+type OIDCProviderResponse struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// OIDCAuthorizationResponse holds the provider URL the browser is sent to.
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorizationUrl"`
+}
@@ -0,0 +1,8 @@
+package responses
+
+// PasskeyCeremonyResponse holds the options for navigator.credentials and the
+// ceremony id to send back with the result.
+type PasskeyCeremonyResponse struct {
+	CeremonyID string `json:"ceremonyId"`
+	Options    any    `json:"options"`
+}
@@ -0,0 +1,10 @@
+package responses
+
+import "github.com/emilijan-koteski/monexa/internal/models"
+
+// PersonalAccessTokenCreatedResponse carries the plain token, which is shown
+// only in this response.
+type PersonalAccessTokenCreatedResponse struct {
+	Token               string                     `json:"token"`
+	PersonalAccessToken models.PersonalAccessToken `json:"personalAccessToken"`
+}
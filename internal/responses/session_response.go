@@ -0,0 +1,15 @@
+package responses
+
+import "time"
+
+type SessionResponse struct {
+	ID          string     `json:"id"`
+	TokenFamily string     `json:"tokenFamily"`
+	DeviceName  string     `json:"deviceName"`
+	UserAgent   string     `json:"userAgent"`
+	IPAddress   string     `json:"ipAddress"`
+	SignedInAt  time.Time  `json:"signedInAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	Current     bool       `json:"current"`
+}
@@ -0,0 +1,56 @@
+package responses
+
+import (
+	"time"
+
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+type ComparisonBucket struct {
+	Year   int     `json:"year"`
+	Month  int     `json:"month"`
+	Amount float64 `json:"amount"`
+}
+
+type ComparisonCategoryAmount struct {
+	CategoryID   uint               `json:"categoryId"`
+	CategoryName string             `json:"categoryName"`
+	CategoryType types.CategoryType `json:"categoryType"`
+	Amount       float64            `json:"amount"`
+}
+
+type ComparisonPeriod struct {
+	StartDate  time.Time                  `json:"startDate"`
+	EndDate    time.Time                  `json:"endDate"`
+	Total      float64                    `json:"total"`
+	Buckets    []ComparisonBucket         `json:"buckets"`
+	Categories []ComparisonCategoryAmount `json:"categories"`
+}
+
+type ComparisonDelta struct {
+	Current    float64  `json:"current"`
+	Previous   float64  `json:"previous"`
+	Absolute   float64  `json:"absolute"`
+	Percentage *float64 `json:"percentage"`
+}
+
+type ComparisonCategoryDelta struct {
+	CategoryID   uint            `json:"categoryId"`
+	CategoryName string          `json:"categoryName"`
+	Delta        ComparisonDelta `json:"delta"`
+}
+
+type ComparisonPreviousPeriod struct {
+	Period         ComparisonPeriod          `json:"period"`
+	TotalDelta     ComparisonDelta           `json:"totalDelta"`
+	BucketDeltas   []ComparisonDelta         `json:"bucketDeltas"`
+	CategoryDeltas []ComparisonCategoryDelta `json:"categoryDeltas"`
+}
+
+type TrendReportComparisonResponse struct {
+	Mode     dtotypes.TrendComparisonModeType `json:"mode"`
+	Currency types.CurrencyType               `json:"currency"`
+	Current  ComparisonPeriod                 `json:"current"`
+	Previous []ComparisonPreviousPeriod       `json:"previous"`
+}
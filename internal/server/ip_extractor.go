@@ -0,0 +1,49 @@
+package server
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IPExtractor returns how c.RealIP() finds the address of the client, which
+// the per-IP throttles count by. Without TRUSTED_PROXIES the address of the
+// connection is used and X-Forwarded-For and X-Real-IP are ignored, since any
+// client can send them. TRUSTED_PROXIES lists the IPs or CIDR ranges of the
+// reverse proxies whose X-Forwarded-For is believed.
+func IPExtractor() echo.IPExtractor {
+	proxies := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES"))
+	if proxies == "" {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			proxy = ip.String() + "/" + strconv.Itoa(bits)
+		}
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Fatalf("⛔ Exit!!! Invalid TRUSTED_PROXIES entry %q", proxy)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...)
+}
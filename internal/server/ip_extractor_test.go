@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emilijan-koteski/monexa/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+// realIP returns what c.RealIP() gives a request from remoteAddr with the
+// forwarding headers.
+func realIP(e *echo.Echo, remoteAddr, forwardedFor string) string {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		req.Header.Set(echo.HeaderXRealIP, forwardedFor)
+	}
+	return e.NewContext(req, httptest.NewRecorder()).RealIP()
+}
+
+func TestSpoofedForwardingHeadersDoNotResetTheIPThrottle(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+	ctx := context.Background()
+	e := echo.New()
+	e.IPExtractor = IPExtractor()
+	throttle := services.NewAuthThrottleService(services.NewMemoryAttemptStore(), nil)
+
+	// Every attempt claims another client and tries another account.
+	for i := range services.MaxLoginFailuresPerIP + 1 {
+		ip := realIP(e, "203.0.113.7:40000", fmt.Sprintf("198.51.100.%d", i%250))
+		_, wait, err := throttle.CheckLogin(ctx, ip, fmt.Sprintf("user%d@example.com", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < services.MaxLoginFailuresPerIP && wait > 0 {
+			t.Fatalf("attempt %d had to wait %v, want it let through", i+1, wait)
+		}
+		if i == services.MaxLoginFailuresPerIP && wait == 0 {
+			t.Errorf("attempt %d was let through, want it throttled by the address of the connection", i+1)
+		}
+	}
+}
+
+func TestIPExtractorBelievesOnlyTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1")
+	e := echo.New()
+	e.IPExtractor = IPExtractor()
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{"trusted range", "10.1.2.3:40000", "198.51.100.9", "198.51.100.9"},
+		{"trusted address", "192.0.2.1:40000", "198.51.100.9", "198.51.100.9"},
+		{"client behind a client supplied hop", "10.1.2.3:40000", "198.51.100.66, 198.51.100.9", "198.51.100.9"},
+		{"untrusted client", "203.0.113.7:40000", "198.51.100.9", "203.0.113.7"},
+		{"untrusted private network", "172.16.0.5:40000", "198.51.100.9", "172.16.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := realIP(e, tt.remoteAddr, tt.forwardedFor); got != tt.want {
+				t.Errorf("RealIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	AttemptStoreMemory   = "memory"
+	AttemptStorePostgres = "postgres"
+)
+
+// AttemptStore keeps the timestamps of throttled auth attempts per key.
+type AttemptStore interface {
+	// Reserve adds an attempt of key made at at and returns the attempts of
+	// key made after since before it, oldest first. Reservations of the same
+	// key are serialized, so each sees the ones before it and a limit cannot
+	// be passed by sending attempts concurrently.
+	Reserve(ctx context.Context, key string, at, since time.Time) ([]time.Time, error)
+	// Remove takes back one attempt of key made at at.
+	Remove(ctx context.Context, key string, at time.Time) error
+	// Since returns the attempts of key made after since, oldest first.
+	Since(ctx context.Context, key string, since time.Time) ([]time.Time, error)
+	Clear(ctx context.Context, key string) error
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// NewAttemptStore returns the store selected by THROTTLE_STORE. The memory
+// store is the default and only limits a single instance, setups with more
+// instances use postgres so they share the counts.
+func NewAttemptStore(db *gorm.DB) AttemptStore {
+	switch strings.ToLower(os.Getenv("THROTTLE_STORE")) {
+	case AttemptStorePostgres:
+		return NewPostgresAttemptStore(db)
+	case "", AttemptStoreMemory:
+		return NewMemoryAttemptStore()
+	default:
+		log.Printf("⚠️ Warning!!! Unknown THROTTLE_STORE %q, using memory", os.Getenv("THROTTLE_STORE"))
+		return NewMemoryAttemptStore()
+	}
+}
+
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string][]time.Time
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: map[string][]time.Time{}}
+}
+
+func (s *MemoryAttemptStore) Reserve(_ context.Context, key string, at, since time.Time) ([]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.since(key, since)
+	s.attempts[key] = append(s.attempts[key], at)
+	return result, nil
+}
+
+func (s *MemoryAttemptStore) Remove(_ context.Context, key string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.attempts[key]
+	for i := range attempts {
+		if attempts[i].Equal(at) {
+			attempts = append(attempts[:i], attempts[i+1:]...)
+			break
+		}
+	}
+	if len(attempts) == 0 {
+		delete(s.attempts, key)
+	} else {
+		s.attempts[key] = attempts
+	}
+	return nil
+}
+
+func (s *MemoryAttemptStore) Since(_ context.Context, key string, since time.Time) ([]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.since(key, since), nil
+}
+
+func (s *MemoryAttemptStore) since(key string, since time.Time) []time.Time {
+	var result []time.Time
+	for _, at := range s.attempts[key] {
+		if at.After(since) {
+			result = append(result, at)
+		}
+	}
+	return result
+}
+
+func (s *MemoryAttemptStore) Clear(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *MemoryAttemptStore) DeleteBefore(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, attempts := range s.attempts {
+		kept := attempts[:0]
+		for _, at := range attempts {
+			if at.Before(before) {
+				deleted++
+			} else {
+				kept = append(kept, at)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.attempts, key)
+		} else {
+			s.attempts[key] = kept
+		}
+	}
+	return deleted, nil
+}
+
+type PostgresAttemptStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresAttemptStore(db *gorm.DB) *PostgresAttemptStore {
+	return &PostgresAttemptStore{db: db}
+}
+
+// Reserve holds a transaction-scoped advisory lock on the key while it reads
+// and inserts, which serializes the reservations of the key across instances.
+func (s *PostgresAttemptStore) Reserve(ctx context.Context, key string, at, since time.Time) ([]time.Time, error) {
+	var result []time.Time
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", key).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AuthAttempt{}).
+			Where("key = ? AND attempted_at > ?", key, since).
+			Order("attempted_at ASC").
+			Pluck("attempted_at", &result).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AuthAttempt{Key: key, AttemptedAt: at}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *PostgresAttemptStore) Remove(ctx context.Context, key string, at time.Time) error {
+	attempt := s.db.Model(&models.AuthAttempt{}).
+		Select("id").
+		Where("key = ? AND attempted_at = ?", key, at).
+		Limit(1)
+	return s.db.WithContext(ctx).Where("id IN (?)", attempt).Delete(&models.AuthAttempt{}).Error
+}
+
+func (s *PostgresAttemptStore) Since(ctx context.Context, key string, since time.Time) ([]time.Time, error) {
+	var result []time.Time
+	if err := s.db.WithContext(ctx).
+		Model(&models.AuthAttempt{}).
+		Where("key = ? AND attempted_at > ?", key, since).
+		Order("attempted_at ASC").
+		Pluck("attempted_at", &result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *PostgresAttemptStore) Clear(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.AuthAttempt{}).Error
+}
+
+func (s *PostgresAttemptStore) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("attempted_at < ?", before).Delete(&models.AuthAttempt{})
+	return result.RowsAffected, result.Error
+}
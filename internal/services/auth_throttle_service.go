@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/utils"
+)
+
+const (
+	LoginThrottleWindow        = 15 * time.Minute
+	MaxLoginFailuresPerIP      = 50
+	MaxLoginFailuresPerAccount = 10
+	// LoginDelayFreeFailures is the number of failures before every further
+	// attempt on the account has to wait, twice as long after each failure.
+	LoginDelayFreeFailures = 3
+	MaxLoginDelay          = 30 * time.Second
+	AccountLockoutDuration = 15 * time.Minute
+
+	PasswordResetThrottleWindow = time.Hour
+	MaxResetRequestsPerIP       = 10
+	MaxResetRequestsPerAccount  = 3
+	MaxResetFailuresPerIP       = 20
+
+	VerificationResendWindow = time.Hour
+	MaxVerificationResends   = 3
+)
+
+// AuthThrottleService limits login and password reset attempts per IP address
+// and per account with sliding windows. Repeated login failures slow down
+// further attempts on the account and finally lock it for a while, which the
+// owner is told about by email.
+//
+// An attempt is counted before its credentials are checked and taken back
+// when they turn out right, so attempts sent concurrently count against each
+// other instead of all passing the check at once.
+type AuthThrottleService struct {
+	store       AttemptStore
+	userService *UserService
+}
+
+func NewAuthThrottleService(store AttemptStore, userService *UserService) *AuthThrottleService {
+	return &AuthThrottleService{store: store, userService: userService}
+}
+
+// Attempt is an attempt a check let through. It counts as a failure unless it
+// is released.
+type Attempt struct {
+	email string
+	keys  []string
+	at    time.Time
+}
+
+// CheckLogin counts a login attempt and returns it, or how long the caller has
+// to wait before the next attempt when there is none.
+func (s *AuthThrottleService) CheckLogin(ctx context.Context, ip, email string) (*Attempt, time.Duration, error) {
+	now := attemptTime()
+
+	locks, err := s.store.Since(ctx, lockKey(email), now.Add(-AccountLockoutDuration))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(locks) > 0 {
+		return nil, locks[len(locks)-1].Add(AccountLockoutDuration).Sub(now), nil
+	}
+
+	attempt := &Attempt{email: email, at: now}
+	wait, err := s.reserve(ctx, attempt, "login:ip:"+ip, MaxLoginFailuresPerIP, LoginThrottleWindow)
+	if err != nil || wait > 0 {
+		return nil, wait, err
+	}
+
+	key := accountKey("login", email)
+	failures, err := s.store.Reserve(ctx, key, now, now.Add(-LoginThrottleWindow))
+	if err != nil {
+		return nil, 0, errors.Join(err, s.Release(ctx, attempt))
+	}
+	attempt.keys = append(attempt.keys, key)
+
+	if len(failures) >= LoginDelayFreeFailures {
+		delay := min(time.Second<<(len(failures)-LoginDelayFreeFailures), MaxLoginDelay)
+		if wait := failures[len(failures)-1].Add(delay).Sub(now); wait > 0 {
+			return nil, wait, s.Release(ctx, attempt)
+		}
+	}
+	return attempt, 0, nil
+}
+
+// RecordLoginFailure keeps the attempt as a wrong password or authentication
+// code. The failure that reaches MaxLoginFailuresPerAccount locks the account
+// and tells the owner. Concurrent failures can all reach it, but only the one
+// that locks the account sends the email.
+func (s *AuthThrottleService) RecordLoginFailure(ctx context.Context, attempt *Attempt) error {
+	now := attemptTime()
+	key := accountKey("login", attempt.email)
+
+	failures, err := s.store.Since(ctx, key, now.Add(-LoginThrottleWindow))
+	if err != nil {
+		return err
+	}
+	if len(failures) < MaxLoginFailuresPerAccount {
+		return nil
+	}
+
+	locks, err := s.store.Reserve(ctx, lockKey(attempt.email), now, now.Add(-AccountLockoutDuration))
+	if err != nil {
+		return err
+	}
+	if len(locks) > 0 {
+		// Already locked, the lock keeps ending when the first one said.
+		return s.store.Remove(ctx, lockKey(attempt.email), now)
+	}
+	// The failures led to the lock, so they do not count once it ends.
+	if err := s.store.Clear(ctx, key); err != nil {
+		return err
+	}
+
+	if err := s.userService.SendAccountLockedEmail(ctx, attempt.email, len(failures), AccountLockoutDuration); err != nil {
+		log.Printf("failed to send account locked email: %v", err)
+	}
+	return nil
+}
+
+// CheckCredentials runs check, which verifies the password or authentication
+// code of a signed in user, as a login attempt of the account. An error of
+// check that is failure counts as a failed login, so endpoints that ask for
+// the password cannot be used to guess it past the login limits. It returns
+// how long to wait when the attempt is throttled, or the error of check.
+func (s *AuthThrottleService) CheckCredentials(ctx context.Context, ip, email string, failure error, check func() error) (time.Duration, error) {
+	attempt, wait, err := s.CheckLogin(ctx, ip, email)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+
+	if err := check(); err != nil {
+		if errors.Is(err, failure) {
+			return 0, errors.Join(err, s.RecordLoginFailure(ctx, attempt))
+		}
+		return 0, errors.Join(err, s.Release(ctx, attempt))
+	}
+	return 0, s.Release(ctx, attempt)
+}
+
+// Release takes back an attempt whose credentials were right.
+func (s *AuthThrottleService) Release(ctx context.Context, attempt *Attempt) error {
+	for _, key := range attempt.keys {
+		if err := s.store.Remove(ctx, key, attempt.at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearAccount forgets the failures and the lock of an account after a
+// successful login or password reset.
+func (s *AuthThrottleService) ClearAccount(ctx context.Context, email string) error {
+	if err := s.store.Clear(ctx, accountKey("login", email)); err != nil {
+		return err
+	}
+	return s.store.Clear(ctx, lockKey(email))
+}
+
+// CheckPasswordResetRequest counts a forgot password request and returns how
+// long the caller has to wait when it is over the limit. Requests are counted
+// whether the account exists or not, so the limit reveals nothing.
+func (s *AuthThrottleService) CheckPasswordResetRequest(ctx context.Context, ip, email string) (time.Duration, error) {
+	attempt := &Attempt{email: email, at: attemptTime()}
+
+	wait, err := s.reserve(ctx, attempt, "forgot:ip:"+ip, MaxResetRequestsPerIP, PasswordResetThrottleWindow)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	wait, err = s.reserve(ctx, attempt, accountKey("forgot", email), MaxResetRequestsPerAccount, PasswordResetThrottleWindow)
+	if err != nil || wait > 0 {
+		// A request turned down for the account does not count for the IP.
+		return wait, errors.Join(err, s.Release(ctx, attempt))
+	}
+	return 0, nil
+}
+
+// CheckPasswordReset limits the guessing of reset tokens per IP address. The
+// attempt is released once the token turned out valid.
+func (s *AuthThrottleService) CheckPasswordReset(ctx context.Context, ip string) (*Attempt, time.Duration, error) {
+	attempt := &Attempt{at: attemptTime()}
+	wait, err := s.reserve(ctx, attempt, "reset:ip:"+ip, MaxResetFailuresPerIP, PasswordResetThrottleWindow)
+	if err != nil || wait > 0 {
+		return nil, wait, err
+	}
+	return attempt, 0, nil
+}
+
+// CheckVerificationResend counts a request for a new verification email and
+// returns how long the user has to wait when it is over the limit.
+func (s *AuthThrottleService) CheckVerificationResend(ctx context.Context, userID uint) (time.Duration, error) {
+	attempt := &Attempt{at: attemptTime()}
+	return s.reserve(ctx, attempt, fmt.Sprintf("verify:user:%d", userID), MaxVerificationResends, VerificationResendWindow)
+}
+
+// CleanupAttempts drops the attempts that left every window.
+func (s *AuthThrottleService) CleanupAttempts(ctx context.Context) (int64, error) {
+	longest := max(LoginThrottleWindow, AccountLockoutDuration, PasswordResetThrottleWindow, VerificationResendWindow)
+	deleted, err := s.store.DeleteBefore(ctx, time.Now().Add(-longest))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete auth attempts: %w", err)
+	}
+	return deleted, nil
+}
+
+// reserve counts the attempt for key unless limit attempts of key are left in
+// the window, and returns how long until fewer are left then.
+func (s *AuthThrottleService) reserve(ctx context.Context, attempt *Attempt, key string, limit int, window time.Duration) (time.Duration, error) {
+	attempts, err := s.store.Reserve(ctx, key, attempt.at, attempt.at.Add(-window))
+	if err != nil {
+		return 0, err
+	}
+	if len(attempts) < limit {
+		attempt.keys = append(attempt.keys, key)
+		return 0, nil
+	}
+
+	if err := s.store.Remove(ctx, key, attempt.at); err != nil {
+		return 0, err
+	}
+	return attempts[len(attempts)-limit].Add(window).Sub(attempt.at), nil
+}
+
+// attemptTime is the current time at the precision Postgres stores, so an
+// attempt can be found again to remove it.
+func attemptTime() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func accountKey(action, email string) string {
+	h := sha256.Sum256([]byte(utils.NormalizeEmail(email)))
+	return action + ":account:" + hex.EncodeToString(h[:])
+}
+
+func lockKey(email string) string {
+	return accountKey("lock", email)
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+)
+
+// concurrently runs check n times at once and returns how many it let through.
+func concurrently(t *testing.T, n int, check func(i int) (bool, error)) int {
+	t.Helper()
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Go(func() {
+			ok, err := check(i)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				allowed.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	return int(allowed.Load())
+}
+
+func TestCheckLoginCountsConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore()
+	s := NewAuthThrottleService(store, nil)
+
+	// Up to LoginDelayFreeFailures attempts run at once, every further one
+	// waits for those to fail, no matter how many are sent together.
+	allowed := concurrently(t, 20, func(i int) (bool, error) {
+		attempt, wait, err := s.CheckLogin(ctx, fmt.Sprintf("10.0.0.%d", i), "ana@example.com")
+		return attempt != nil && wait == 0, err
+	})
+	if allowed != LoginDelayFreeFailures {
+		t.Errorf("%d concurrent attempts let through, want %d", allowed, LoginDelayFreeFailures)
+	}
+
+	failures, err := store.Since(ctx, accountKey("login", "ana@example.com"), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != LoginDelayFreeFailures {
+		t.Errorf("%d attempts counted, want only the %d let through", len(failures), LoginDelayFreeFailures)
+	}
+}
+
+func TestCheckLoginDelaysAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore()
+	s := NewAuthThrottleService(store, nil)
+
+	key := accountKey("login", "ana@example.com")
+	earlier := attemptTime().Add(-time.Minute)
+	for i := range LoginDelayFreeFailures {
+		if _, err := store.Reserve(ctx, key, earlier.Add(time.Duration(i)*time.Second), time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The delay after the last failure passed, so one attempt may run, and
+	// the others have to wait for its outcome.
+	allowed := concurrently(t, 20, func(int) (bool, error) {
+		attempt, wait, err := s.CheckLogin(ctx, "10.0.0.1", "ana@example.com")
+		return attempt != nil && wait == 0, err
+	})
+	if allowed != 1 {
+		t.Errorf("%d concurrent attempts let through after %d failures, want 1", allowed, LoginDelayFreeFailures)
+	}
+}
+
+func TestReleasedLoginAttemptsDoNotCount(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore()
+	s := NewAuthThrottleService(store, nil)
+
+	for range 2 * MaxLoginFailuresPerAccount {
+		attempt, wait, err := s.CheckLogin(ctx, "10.0.0.1", "ana@example.com")
+		if err != nil || wait > 0 {
+			t.Fatalf("CheckLogin() = %v, %v after released attempts, want no wait", wait, err)
+		}
+		if err := s.Release(ctx, attempt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, key := range []string{"login:ip:10.0.0.1", accountKey("login", "ana@example.com")} {
+		attempts, err := store.Since(ctx, key, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attempts) != 0 {
+			t.Errorf("%d attempts of %s left after releasing them, want 0", len(attempts), key)
+		}
+	}
+}
+
+func TestCheckPasswordResetRequestCountsConcurrentRequests(t *testing.T) {
+	ctx := context.Background()
+	s := NewAuthThrottleService(NewMemoryAttemptStore(), nil)
+
+	allowed := concurrently(t, 20, func(i int) (bool, error) {
+		wait, err := s.CheckPasswordResetRequest(ctx, fmt.Sprintf("10.0.0.%d", i), "ana@example.com")
+		return wait == 0, err
+	})
+	if allowed != MaxResetRequestsPerAccount {
+		t.Errorf("%d concurrent requests let through, want %d", allowed, MaxResetRequestsPerAccount)
+	}
+
+	// The requests turned down for the account do not use up the limit of
+	// the IP addresses.
+	if wait, err := s.CheckPasswordResetRequest(ctx, "10.0.0.19", "marko@example.com"); err != nil || wait > 0 {
+		t.Errorf("CheckPasswordResetRequest() of another account = %v, %v, want no wait", wait, err)
+	}
+}
+
+// slowClearStore takes a while to clear a key, like a busy database, so
+// concurrent failures all see the failures that reached the limit.
+type slowClearStore struct {
+	*MemoryAttemptStore
+}
+
+func (s slowClearStore) Clear(ctx context.Context, key string) error {
+	time.Sleep(50 * time.Millisecond)
+	return s.MemoryAttemptStore.Clear(ctx, key)
+}
+
+func TestLockEmailIsSentOnceWhenConcurrentFailuresLockTheAccount(t *testing.T) {
+	ctx := context.Background()
+	userService, _, _ := newTestUserService(t)
+	store := slowClearStore{NewMemoryAttemptStore()}
+	s := NewAuthThrottleService(store, userService)
+
+	user := models.User{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana"}
+	if err := userService.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Attempts that passed the check before the earlier ones failed, and all
+	// fail together past the limit.
+	key := accountKey("login", user.Email)
+	start := attemptTime()
+	attempts := make([]*Attempt, MaxLoginFailuresPerAccount+5)
+	for i := range attempts {
+		attempts[i] = &Attempt{email: user.Email, keys: []string{key}, at: start.Add(time.Duration(i) * time.Microsecond)}
+		if _, err := store.Reserve(ctx, key, attempts[i].at, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	concurrently(t, len(attempts), func(i int) (bool, error) {
+		return true, s.RecordLoginFailure(ctx, attempts[i])
+	})
+
+	var emails int64
+	if err := userService.db.Model(&models.OutboxEmail{}).
+		Where("template = ? AND recipient = ?", AccountLockedTemplate, user.Email).
+		Count(&emails).Error; err != nil {
+		t.Fatal(err)
+	}
+	if emails != 1 {
+		t.Errorf("%d account locked emails, want 1", emails)
+	}
+
+	locks, err := store.Since(ctx, lockKey(user.Email), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 {
+		t.Errorf("%d locks, want 1", len(locks))
+	}
+	if _, wait, err := s.CheckLogin(ctx, "10.0.0.1", user.Email); err != nil || wait <= 0 {
+		t.Errorf("CheckLogin() of the locked account = %v, %v, want a wait", wait, err)
+	}
+}
+
+func TestCheckCredentialsCountsWrongPasswordsAsLoginFailures(t *testing.T) {
+	ctx := context.Background()
+	userService, _, _ := newTestUserService(t)
+	store := NewMemoryAttemptStore()
+	s := NewAuthThrottleService(store, userService)
+
+	password, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: "ana@example.com", Password: password, Name: "Ana", PPID: "ppid-ana"}
+	if err := userService.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	key := accountKey("login", user.Email)
+	earlier := attemptTime().Add(-time.Minute)
+	for i := range MaxLoginFailuresPerAccount - 1 {
+		if _, err := store.Reserve(ctx, key, earlier.Add(time.Duration(i)*time.Microsecond), time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changeEmail := func(password, newEmail string) (time.Duration, error) {
+		return s.CheckCredentials(ctx, "10.0.0.1", user.Email, ErrIncorrectPassword, func() error {
+			return userService.RequestEmailChange(ctx, user.ID, requests.ChangeEmailRequest{CurrentPassword: password, NewEmail: newEmail})
+		})
+	}
+
+	// Errors other than a wrong password do not count.
+	if wait, err := changeEmail("correct horse", "not an email"); wait > 0 || !errors.Is(err, ErrInvalidEmail) {
+		t.Fatalf("RequestEmailChange() with an invalid email = %v, %v, want %v", wait, err, ErrInvalidEmail)
+	}
+	if wait, err := changeEmail("wrong horse", "ana@work.com"); wait > 0 || !errors.Is(err, ErrIncorrectPassword) {
+		t.Fatalf("RequestEmailChange() with a wrong password = %v, %v, want %v", wait, err, ErrIncorrectPassword)
+	}
+
+	// That was the failure that reached the limit, so the account is locked
+	// for logins and for everything else that asks for the password.
+	if _, wait, err := s.CheckLogin(ctx, "10.0.0.2", user.Email); err != nil || wait <= 0 {
+		t.Errorf("CheckLogin() after the wrong password = %v, %v, want a wait", wait, err)
+	}
+	if wait, err := changeEmail("correct horse", "ana@work.com"); err != nil || wait <= 0 {
+		t.Errorf("RequestEmailChange() of the locked account = %v, %v, want a wait", wait, err)
+	}
+}
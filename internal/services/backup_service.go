@@ -0,0 +1,645 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/dtos"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/responses"
+	"gorm.io/gorm"
+)
+
+const (
+	BackupFormat        = "monexa-backup"
+	BackupSchemaVersion = 1
+
+	// MaxBackupSize limits both the uploaded archive and the files read from
+	// it put together.
+	MaxBackupSize = 50 << 20
+	// MaxBackupFiles limits the number of files in the archive.
+	MaxBackupFiles = 16
+)
+
+const (
+	backupManifestPath       = "manifest.json"
+	backupCategoriesPath     = "data/categories.json"
+	backupPaymentMethodsPath = "data/payment-methods.json"
+	backupRecordsPath        = "data/records.json"
+	backupTrendReportsPath   = "data/trend-reports.json"
+	backupSettingsPath       = "data/settings.json"
+)
+
+// backupDataPaths are the data files a restore reads, the manifest lists them.
+var backupDataPaths = []string{
+	backupCategoriesPath,
+	backupPaymentMethodsPath,
+	backupRecordsPath,
+	backupTrendReportsPath,
+	backupSettingsPath,
+}
+
+var errBackupTooLarge = errors.New("file is too large")
+
+type BackupService struct {
+	db *gorm.DB
+}
+
+func NewBackupService(db *gorm.DB) *BackupService {
+	return &BackupService{db: db}
+}
+
+type backupContents struct {
+	categories     []dtos.BackupCategory
+	paymentMethods []dtos.BackupPaymentMethod
+	records        []dtos.BackupRecord
+	trendReports   []dtos.BackupTrendReport
+	settings       *dtos.BackupSettings
+}
+
+// WriteBackup writes all restorable data of the user into a ZIP with a manifest
+// holding the schema version and a checksum of every data file. Rows are
+// streamed from the database into the archive and hashed on the way, so the
+// manifest is the last entry.
+func (s *BackupService) WriteBackup(ctx context.Context, userID uint, w io.Writer) error {
+	// Trend reports need their categories and payment methods preloaded, so they
+	// are read at once. A user has a handful of them rather than a growing list.
+	var trendReports []models.TrendReport
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Preload("Categories").
+		Preload("PaymentMethods").
+		Order("id").
+		Find(&trendReports).Error; err != nil {
+		return fmt.Errorf("failed to get trend reports: %w", err)
+	}
+
+	var setting models.Setting
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&setting).Error; err != nil {
+		return fmt.Errorf("failed to get settings: %w", err)
+	}
+
+	contents := []struct {
+		path  string
+		write func(w io.Writer) error
+	}{
+		{backupCategoriesPath, func(w io.Writer) error {
+			query := s.db.WithContext(ctx).Model(&models.Category{}).Where("user_id = ?", userID).Order("id")
+			return writeJSONArray(w, func(fn func(element any) error) error {
+				return streamRows(query, func(c models.Category) error {
+					return fn(dtos.BackupCategory{ID: c.ID, Name: c.Name, Type: c.Type, Description: c.Description, Color: c.Color})
+				})
+			})
+		}},
+		{backupPaymentMethodsPath, func(w io.Writer) error {
+			query := s.db.WithContext(ctx).Model(&models.PaymentMethod{}).Where("user_id = ?", userID).Order("id")
+			return writeJSONArray(w, func(fn func(element any) error) error {
+				return streamRows(query, func(pm models.PaymentMethod) error {
+					return fn(dtos.BackupPaymentMethod{ID: pm.ID, Name: pm.Name})
+				})
+			})
+		}},
+		{backupRecordsPath, func(w io.Writer) error {
+			query := s.db.WithContext(ctx).Model(&models.Record{}).Where("user_id = ?", userID).Order("id")
+			return writeJSONArray(w, func(fn func(element any) error) error {
+				return streamRows(query, func(r models.Record) error {
+					return fn(dtos.BackupRecord{
+						ID:              r.ID,
+						CategoryID:      r.CategoryID,
+						PaymentMethodID: r.PaymentMethodID,
+						Amount:          r.Amount,
+						Currency:        r.Currency,
+						Description:     r.Description,
+						Date:            r.Date,
+					})
+				})
+			})
+		}},
+		{backupTrendReportsPath, func(w io.Writer) error {
+			return writeJSONArray(w, func(fn func(element any) error) error {
+				for _, tr := range trendReports {
+					if err := fn(backupTrendReport(tr)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}},
+		{backupSettingsPath, func(w io.Writer) error {
+			data, err := buildJSON(dtos.BackupSettings{Language: setting.Language, Currency: setting.Currency})
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}},
+	}
+
+	manifest := dtos.BackupManifest{
+		Format:        BackupFormat,
+		SchemaVersion: BackupSchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	zw := zip.NewWriter(w)
+	for _, content := range contents {
+		f, err := zw.Create(content.path)
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry %s: %w", content.path, err)
+		}
+
+		hash := sha256.New()
+		counter := &countingWriter{w: io.MultiWriter(f, hash)}
+		if err := content.write(counter); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", content.path, err)
+		}
+
+		manifest.Files = append(manifest.Files, dtos.BackupManifestFile{
+			Path:   content.path,
+			Size:   counter.n,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+
+	manifestData, err := buildJSON(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	f, err := zw.Create(backupManifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry %s: %w", backupManifestPath, err)
+	}
+	if _, err := f.Write(manifestData); err != nil {
+		return fmt.Errorf("failed to write zip entry %s: %w", backupManifestPath, err)
+	}
+
+	return closeZIP(zw)
+}
+
+func backupTrendReport(tr models.TrendReport) dtos.BackupTrendReport {
+	report := dtos.BackupTrendReport{
+		ID:               tr.ID,
+		Title:            tr.Title,
+		Description:      tr.Description,
+		Color:            tr.Color,
+		Search:           tr.Search,
+		MinAmount:        tr.MinAmount,
+		MaxAmount:        tr.MaxAmount,
+		CategoryIDs:      make([]uint, 0, len(tr.Categories)),
+		PaymentMethodIDs: make([]uint, 0, len(tr.PaymentMethods)),
+	}
+	for _, c := range tr.Categories {
+		report.CategoryIDs = append(report.CategoryIDs, c.ID)
+	}
+	for _, pm := range tr.PaymentMethods {
+		report.PaymentMethodIDs = append(report.PaymentMethodIDs, pm.ID)
+	}
+	return report
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// writeJSONArray writes the elements each yields as an indented JSON array,
+// byte for byte what buildJSON writes for the whole slice.
+func writeJSONArray(w io.Writer, each func(fn func(element any) error) error) error {
+	count := 0
+	err := each(func(element any) error {
+		data, err := json.MarshalIndent(element, "  ", "  ")
+		if err != nil {
+			return err
+		}
+
+		separator := ",\n  "
+		if count == 0 {
+			separator = "[\n  "
+		}
+		count++
+
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		_, err = io.WriteString(w, "[]")
+	} else {
+		_, err = io.WriteString(w, "\n]")
+	}
+	return err
+}
+
+// RestoreBackup validates a backup archive and, unless it is a dry run, replaces
+// the user's categories, payment methods, records and trend reports with the
+// backup contents and applies its settings. IDs from the backup are only used to
+// link the entities together; everything is created with new IDs.
+func (s *BackupService) RestoreBackup(ctx context.Context, req requests.BackupRestoreRequest) (*responses.BackupRestoreReport, error) {
+	if req.UserID == 0 {
+		return nil, errors.New("invalid user id")
+	}
+
+	report := &responses.BackupRestoreReport{
+		DryRun:   req.DryRun,
+		Errors:   []string{},
+		Warnings: []string{},
+	}
+
+	contents := s.readBackup(req.Archive, report)
+	if contents != nil {
+		validateBackup(contents, report)
+	}
+
+	if err := s.countExisting(ctx, req.UserID, &report.Replaced); err != nil {
+		return nil, err
+	}
+
+	report.Valid = len(report.Errors) == 0
+	if !report.Valid || req.DryRun {
+		return report, nil
+	}
+
+	if err := s.restore(ctx, req.UserID, contents); err != nil {
+		return nil, err
+	}
+	report.Restored = true
+
+	return report, nil
+}
+
+// readBackup opens the archive, verifies the manifest and checksums and decodes
+// the data files. Problems are collected in the report instead of failing fast
+// so a dry run lists all of them at once.
+func (s *BackupService) readBackup(archive []byte, report *responses.BackupRestoreReport) *backupContents {
+	if len(archive) > MaxBackupSize {
+		report.Errors = append(report.Errors, fmt.Sprintf("backup is larger than %d bytes", MaxBackupSize))
+		return nil
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		report.Errors = append(report.Errors, "backup is not a valid zip archive")
+		return nil
+	}
+
+	if len(reader.File) > MaxBackupFiles {
+		report.Errors = append(report.Errors, fmt.Sprintf("backup has more than %d files", MaxBackupFiles))
+		return nil
+	}
+	entries := make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		if _, ok := entries[f.Name]; ok {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s is in the backup more than once", f.Name))
+			return nil
+		}
+		entries[f.Name] = f
+	}
+
+	// Only the manifest and the data files it lists are read, and together
+	// they may not expand past MaxBackupSize, so a small archive cannot
+	// unpack into much more memory than a large one.
+	remaining := MaxBackupSize
+	read := func(path string) ([]byte, error) {
+		data, err := readZipFile(entries[path], remaining)
+		if errors.Is(err, errBackupTooLarge) {
+			return nil, fmt.Errorf("backup expands to more than %d bytes", MaxBackupSize)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		remaining -= len(data)
+		return data, nil
+	}
+
+	if entries[backupManifestPath] == nil {
+		report.Errors = append(report.Errors, "backup has no manifest.json")
+		return nil
+	}
+	manifestData, err := read(backupManifestPath)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return nil
+	}
+
+	var manifest dtos.BackupManifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("manifest.json is invalid: %v", err))
+		return nil
+	}
+	report.SchemaVersion = manifest.SchemaVersion
+
+	if manifest.Format != BackupFormat {
+		report.Errors = append(report.Errors, fmt.Sprintf("unknown backup format %q", manifest.Format))
+		return nil
+	}
+	if manifest.SchemaVersion < 1 || manifest.SchemaVersion > BackupSchemaVersion {
+		report.Errors = append(report.Errors, fmt.Sprintf("unsupported schema version %d, this server supports up to %d", manifest.SchemaVersion, BackupSchemaVersion))
+		return nil
+	}
+
+	files := make(map[string][]byte, len(backupDataPaths))
+	listed := map[string]bool{backupManifestPath: true}
+	for _, file := range manifest.Files {
+		if listed[file.Path] {
+			continue
+		}
+		listed[file.Path] = true
+		if !slices.Contains(backupDataPaths, file.Path) {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s is not a backup data file and was ignored", file.Path))
+			continue
+		}
+		if entries[file.Path] == nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s is listed in the manifest but missing", file.Path))
+			continue
+		}
+		data, err := read(file.Path)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return nil
+		}
+		if len(data) != file.Size || sha256Hex(data) != file.SHA256 {
+			report.Errors = append(report.Errors, fmt.Sprintf("checksum mismatch for %s", file.Path))
+		}
+		files[file.Path] = data
+	}
+	for _, f := range reader.File {
+		if !listed[f.Name] {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s is not listed in the manifest and was ignored", f.Name))
+		}
+	}
+
+	contents := &backupContents{}
+	decode := func(path string, target any) {
+		if !listed[path] {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s is missing from the manifest", path))
+			return
+		}
+		data, ok := files[path]
+		if !ok {
+			return
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s is invalid: %v", path, err))
+		}
+	}
+	decode(backupCategoriesPath, &contents.categories)
+	decode(backupPaymentMethodsPath, &contents.paymentMethods)
+	decode(backupRecordsPath, &contents.records)
+	decode(backupTrendReportsPath, &contents.trendReports)
+	decode(backupSettingsPath, &contents.settings)
+
+	report.Backup = responses.BackupRestoreCounts{
+		Categories:     len(contents.categories),
+		PaymentMethods: len(contents.paymentMethods),
+		Records:        len(contents.records),
+		TrendReports:   len(contents.trendReports),
+		Settings:       contents.settings != nil,
+	}
+
+	return contents
+}
+
+// validateBackup checks every entity and every reference between entities.
+func validateBackup(contents *backupContents, report *responses.BackupRestoreReport) {
+	addError := func(format string, args ...any) {
+		report.Errors = append(report.Errors, fmt.Sprintf(format, args...))
+	}
+
+	categoryIDs := make(map[uint]bool, len(contents.categories))
+	for _, c := range contents.categories {
+		if categoryIDs[c.ID] {
+			addError("category #%d is duplicated", c.ID)
+		}
+		categoryIDs[c.ID] = true
+		if c.Name == "" {
+			addError("category #%d has no name", c.ID)
+		}
+		if !types.IsValidCategoryType(c.Type) {
+			addError("category #%d has an invalid type %q", c.ID, c.Type)
+		}
+	}
+
+	paymentMethodIDs := make(map[uint]bool, len(contents.paymentMethods))
+	for _, pm := range contents.paymentMethods {
+		if paymentMethodIDs[pm.ID] {
+			addError("payment method #%d is duplicated", pm.ID)
+		}
+		paymentMethodIDs[pm.ID] = true
+		if pm.Name == "" {
+			addError("payment method #%d has no name", pm.ID)
+		}
+	}
+
+	for _, r := range contents.records {
+		if !categoryIDs[r.CategoryID] {
+			addError("record #%d references unknown category #%d", r.ID, r.CategoryID)
+		}
+		if !paymentMethodIDs[r.PaymentMethodID] {
+			addError("record #%d references unknown payment method #%d", r.ID, r.PaymentMethodID)
+		}
+		if !types.IsValidCurrencyType(r.Currency) {
+			addError("record #%d has an invalid currency %q", r.ID, r.Currency)
+		}
+		if r.Date.IsZero() {
+			addError("record #%d has no date", r.ID)
+		}
+	}
+
+	for _, tr := range contents.trendReports {
+		if len(tr.CategoryIDs) == 0 {
+			addError("trend report #%d has no categories", tr.ID)
+		}
+		for _, id := range tr.CategoryIDs {
+			if !categoryIDs[id] {
+				addError("trend report #%d references unknown category #%d", tr.ID, id)
+			}
+		}
+		for _, id := range tr.PaymentMethodIDs {
+			if !paymentMethodIDs[id] {
+				addError("trend report #%d references unknown payment method #%d", tr.ID, id)
+			}
+		}
+		if err := validateAmountRange(tr.MinAmount, tr.MaxAmount); err != nil {
+			addError("trend report #%d: %v", tr.ID, err)
+		}
+	}
+
+	if contents.settings == nil {
+		report.Warnings = append(report.Warnings, "backup has no settings, current settings are kept")
+	} else {
+		if !types.IsValidLanguageType(contents.settings.Language) {
+			addError("settings have an invalid language %q", contents.settings.Language)
+		}
+		if !types.IsValidCurrencyType(contents.settings.Currency) {
+			addError("settings have an invalid currency %q", contents.settings.Currency)
+		}
+	}
+}
+
+func (s *BackupService) countExisting(ctx context.Context, userID uint, counts *responses.BackupRestoreCounts) error {
+	targets := []struct {
+		model any
+		count *int
+	}{
+		{&models.Category{}, &counts.Categories},
+		{&models.PaymentMethod{}, &counts.PaymentMethods},
+		{&models.Record{}, &counts.Records},
+		{&models.TrendReport{}, &counts.TrendReports},
+	}
+	for _, target := range targets {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(target.model).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count existing data: %w", err)
+		}
+		*target.count = int(count)
+	}
+	counts.Settings = true
+	return nil
+}
+
+func (s *BackupService) restore(ctx context.Context, userID uint, contents *backupContents) error {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, model := range []any{&models.TrendReport{}, &models.Record{}, &models.Category{}, &models.PaymentMethod{}} {
+		if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to remove existing data: %w", err)
+		}
+	}
+
+	categories := make(map[uint]models.Category, len(contents.categories))
+	for _, c := range contents.categories {
+		category := models.Category{UserID: userID, Name: c.Name, Type: c.Type, Description: c.Description, Color: c.Color}
+		if err := tx.Create(&category).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to restore category #%d: %w", c.ID, err)
+		}
+		categories[c.ID] = category
+	}
+
+	paymentMethods := make(map[uint]models.PaymentMethod, len(contents.paymentMethods))
+	for _, pm := range contents.paymentMethods {
+		paymentMethod := models.PaymentMethod{UserID: userID, Name: pm.Name}
+		if err := tx.Create(&paymentMethod).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to restore payment method #%d: %w", pm.ID, err)
+		}
+		paymentMethods[pm.ID] = paymentMethod
+	}
+
+	if len(contents.records) > 0 {
+		records := make([]models.Record, 0, len(contents.records))
+		for _, r := range contents.records {
+			records = append(records, models.Record{
+				UserID:          userID,
+				CategoryID:      categories[r.CategoryID].ID,
+				PaymentMethodID: paymentMethods[r.PaymentMethodID].ID,
+				Amount:          r.Amount,
+				Currency:        r.Currency,
+				Description:     r.Description,
+				Date:            r.Date,
+			})
+		}
+		if err := tx.CreateInBatches(&records, 500).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to restore records: %w", err)
+		}
+	}
+
+	for _, tr := range contents.trendReports {
+		report := models.TrendReport{
+			UserID:      userID,
+			Title:       tr.Title,
+			Description: tr.Description,
+			Color:       tr.Color,
+			Search:      tr.Search,
+			MinAmount:   tr.MinAmount,
+			MaxAmount:   tr.MaxAmount,
+		}
+		for _, id := range tr.CategoryIDs {
+			report.Categories = append(report.Categories, categories[id])
+		}
+		for _, id := range tr.PaymentMethodIDs {
+			report.PaymentMethods = append(report.PaymentMethods, paymentMethods[id])
+		}
+		if err := tx.Create(&report).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to restore trend report #%d: %w", tr.ID, err)
+		}
+	}
+
+	if contents.settings != nil {
+		if err := tx.Model(&models.Setting{}).Where("user_id = ?", userID).Updates(map[string]any{
+			"language": contents.settings.Language,
+			"currency": contents.settings.Currency,
+		}).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to restore settings: %w", err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit restore: %w", err)
+	}
+
+	return nil
+}
+
+// readZipFile reads a file of the archive, failing with errBackupTooLarge once
+// it is larger than limit.
+func readZipFile(f *zip.File, limit int) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, errBackupTooLarge
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// The declared size can be forged, so the read itself is limited as well.
+	data, err := io.ReadAll(io.LimitReader(rc, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, errBackupTooLarge
+	}
+	return data, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/emilijan-koteski/monexa/internal/dtos"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+)
+
+type backupEntry struct {
+	path string
+	data []byte
+	// listed adds the entry to the manifest.
+	listed bool
+}
+
+// buildBackup zips the entries together with a manifest listing the listed
+// ones.
+func buildBackup(t *testing.T, entries ...backupEntry) []byte {
+	t.Helper()
+
+	manifest := dtos.BackupManifest{Format: BackupFormat, SchemaVersion: BackupSchemaVersion}
+	for _, entry := range entries {
+		if entry.listed {
+			manifest.Files = append(manifest.Files, dtos.BackupManifestFile{Path: entry.path, Size: len(entry.data), SHA256: sha256Hex(entry.data)})
+		}
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range append(entries, backupEntry{path: backupManifestPath, data: manifestData}) {
+		f, err := zw.Create(entry.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(entry.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func emptyBackupEntries() []backupEntry {
+	return []backupEntry{
+		{path: backupCategoriesPath, data: []byte("[]"), listed: true},
+		{path: backupPaymentMethodsPath, data: []byte("[]"), listed: true},
+		{path: backupRecordsPath, data: []byte("[]"), listed: true},
+		{path: backupTrendReportsPath, data: []byte("[]"), listed: true},
+		{path: backupSettingsPath, data: []byte("null"), listed: true},
+	}
+}
+
+func dryRunRestore(t *testing.T, archive []byte) (valid bool, errs, warnings []string) {
+	t.Helper()
+
+	db := newTestDB(t, &models.Category{}, &models.PaymentMethod{}, &models.Record{}, &models.TrendReport{})
+	report, err := NewBackupService(db).RestoreBackup(context.Background(), requests.BackupRestoreRequest{UserID: 1, Archive: archive, DryRun: true})
+	if err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	return report.Valid, report.Errors, report.Warnings
+}
+
+func TestRestoreBackupAcceptsEmptyBackup(t *testing.T) {
+	valid, errs, _ := dryRunRestore(t, buildBackup(t, emptyBackupEntries()...))
+	if !valid {
+		t.Errorf("RestoreBackup() of an empty backup = valid %v, errors %v, want valid", valid, errs)
+	}
+}
+
+func TestRestoreBackupRejectsTooManyFiles(t *testing.T) {
+	entries := emptyBackupEntries()
+	for i := range MaxBackupFiles {
+		entries = append(entries, backupEntry{path: fmt.Sprintf("extra-%d.json", i), data: []byte("[]")})
+	}
+
+	valid, errs, _ := dryRunRestore(t, buildBackup(t, entries...))
+	if valid || !containsError(errs, fmt.Sprintf("more than %d files", MaxBackupFiles)) {
+		t.Errorf("RestoreBackup() of %d files = valid %v, errors %v, want too many files", len(entries)+1, valid, errs)
+	}
+}
+
+func TestRestoreBackupRejectsArchivesExpandingPastTheLimit(t *testing.T) {
+	// Every file stays below the limit and compresses to almost nothing, but
+	// together they expand past it.
+	large := bytes.Repeat([]byte(" "), MaxBackupSize/4+1)
+	entries := emptyBackupEntries()
+	for i := range entries {
+		entries[i].data = large
+	}
+
+	archive := buildBackup(t, entries...)
+	if len(archive) > MaxBackupSize/100 {
+		t.Fatalf("archive is %d bytes, want a small one", len(archive))
+	}
+	valid, errs, _ := dryRunRestore(t, archive)
+	if valid || !containsError(errs, fmt.Sprintf("expands to more than %d bytes", MaxBackupSize)) {
+		t.Errorf("RestoreBackup() of an archive expanding past the limit = valid %v, errors %v, want it rejected", valid, errs)
+	}
+}
+
+func TestRestoreBackupDoesNotReadUnlistedFiles(t *testing.T) {
+	// An unlisted file larger than the limit is ignored without being read.
+	entries := append(emptyBackupEntries(), backupEntry{path: "data/extra.json", data: bytes.Repeat([]byte(" "), MaxBackupSize+1)})
+
+	valid, errs, warnings := dryRunRestore(t, buildBackup(t, entries...))
+	if !valid || !containsError(warnings, "data/extra.json is not listed in the manifest") {
+		t.Errorf("RestoreBackup() with an unlisted file = valid %v, errors %v, warnings %v, want valid with a warning", valid, errs, warnings)
+	}
+}
+
+func containsError(errs []string, substr string) bool {
+	for _, err := range errs {
+		if strings.Contains(err, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a throwaway SQLite database with the tables of the given
+// models. It lives in a file rather than in memory so that every connection of
+// the pool sees the same database, which the services rely on when they query
+// outside of an open transaction.
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "monexa.db") + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// useRepoRoot changes into the repository root for the rest of the test, so
+// the email templates resolve like they do for the server.
+func useRepoRoot(t *testing.T) {
+	t.Helper()
+	t.Chdir(filepath.Join("..", ".."))
+}
@@ -0,0 +1,444 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	DigestTopCategories       = 5
+	DigestLargestTransactions = 5
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+type digestText struct {
+	WeeklyTitle  string
+	MonthlyTitle string
+	New          string
+	NoChange     string
+	Spent        string
+	Overspent    string
+}
+
+var digestTexts = map[types.LanguageType]digestText{
+	types.EnglishLanguage: {
+		WeeklyTitle:  "Your weekly digest",
+		MonthlyTitle: "Your monthly digest",
+		New:          "new",
+		NoChange:     "no change",
+		Spent:        "%s%% of income spent",
+		Overspent:    "spent more than earned",
+	},
+	types.MacedonianLanguage: {
+		WeeklyTitle:  "Вашиот неделен преглед",
+		MonthlyTitle: "Вашиот месечен преглед",
+		New:          "ново",
+		NoChange:     "без промена",
+		Spent:        "потрошени %s%% од приходите",
+		Overspent:    "потрошено повеќе од заработеното",
+	},
+}
+
+type digestCategory struct {
+	Name      string
+	Amount    string
+	Delta     string
+	Increased bool
+}
+
+type digestTransaction struct {
+	Date        string
+	Category    string
+	Description string
+	Amount      string
+}
+
+type DigestService struct {
+	db                *gorm.DB
+	categoryService   *CategoryService
+	settingService    *SettingService
+	currencyService   *CurrencyService
+	mailService       *MailService
+	unsubscribeSecret []byte
+}
+
+func NewDigestService(db *gorm.DB, categoryService *CategoryService, settingService *SettingService, currencyService *CurrencyService, mailService *MailService) *DigestService {
+	secret := os.Getenv("UNSUBSCRIBE_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+
+	return &DigestService{
+		db:                db,
+		categoryService:   categoryService,
+		settingService:    settingService,
+		currencyService:   currencyService,
+		mailService:       mailService,
+		unsubscribeSecret: []byte(secret),
+	}
+}
+
+func (s *DigestService) GetByUserID(ctx context.Context, userID uint) (*models.DigestSubscription, error) {
+	var subscription models.DigestSubscription
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Subscribe opts the user into digests or changes their frequency. The first
+// digest covers the period that is running now and arrives once it ends.
+func (s *DigestService) Subscribe(ctx context.Context, req requests.DigestSubscriptionRequest) (*models.DigestSubscription, error) {
+	if req.UserID == 0 {
+		return nil, errors.New("invalid user id")
+	}
+	if !types.IsValidDigestFrequencyType(req.Frequency) {
+		return nil, errors.New("invalid frequency, expected WEEKLY or MONTHLY")
+	}
+
+	subscription, err := s.GetByUserID(ctx, req.UserID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	lastPeriod, _, _ := digestPeriod(req.Frequency, time.Now(), 1)
+
+	if subscription == nil {
+		subscription = &models.DigestSubscription{
+			UserID:     req.UserID,
+			Frequency:  req.Frequency,
+			LastPeriod: &lastPeriod,
+		}
+		if err := s.db.WithContext(ctx).Create(subscription).Error; err != nil {
+			return nil, fmt.Errorf("failed to create digest subscription: %w", err)
+		}
+		return subscription, nil
+	}
+
+	if subscription.Frequency != req.Frequency {
+		subscription.Frequency = req.Frequency
+		subscription.LastPeriod = &lastPeriod
+	}
+	if err := s.db.WithContext(ctx).Save(subscription).Error; err != nil {
+		return nil, fmt.Errorf("failed to update digest subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+func (s *DigestService) Unsubscribe(ctx context.Context, userID uint) error {
+	result := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.DigestSubscription{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UnsubscribeWithToken handles the link in digest emails, which works without
+// signing in. Unsubscribing twice is not an error.
+func (s *DigestService) UnsubscribeWithToken(ctx context.Context, token string) error {
+	userID, ok := s.verifyUnsubscribeToken(token)
+	if !ok {
+		return ErrInvalidUnsubscribeToken
+	}
+
+	if err := s.Unsubscribe(ctx, userID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// RunDueDigests sends the digest of the last finished period to every
+// subscription that has not received it yet.
+func (s *DigestService) RunDueDigests(ctx context.Context, now time.Time) (int, error) {
+	var subscriptions []models.DigestSubscription
+	if err := s.db.WithContext(ctx).
+		Joins("JOIN users ON users.id = digest_subscriptions.user_id AND users.deleted_at IS NULL").
+		Find(&subscriptions).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch digest subscriptions: %w", err)
+	}
+
+	sent := 0
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+
+		period, startDate, endDate := digestPeriod(subscription.Frequency, now, 1)
+		if subscription.LastPeriod != nil && *subscription.LastPeriod == period {
+			continue
+		}
+
+		if err := s.sendDigest(ctx, subscription, startDate, endDate); err != nil {
+			log.Printf("🛑 Error!!! digest for user %d failed: %v", subscription.UserID, err)
+			continue
+		}
+
+		if err := s.db.WithContext(ctx).Model(subscription).Updates(map[string]any{
+			"last_period":  period,
+			"last_sent_at": time.Now(),
+		}).Error; err != nil {
+			return sent, fmt.Errorf("failed to update digest subscription %d: %w", subscription.ID, err)
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (s *DigestService) sendDigest(ctx context.Context, subscription *models.DigestSubscription, startDate, endDate time.Time) error {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", subscription.UserID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	lang := types.EnglishLanguage
+	if setting, err := s.settingService.GetByUserID(ctx, user.ID); err == nil {
+		lang = setting.Language
+	}
+	if !types.IsValidLanguageType(lang) {
+		lang = types.EnglishLanguage
+	}
+	text := digestTexts[lang]
+
+	// The previous period has the same length, so both sides compare equally.
+	length := endDate.Sub(startDate)
+	previousStart, previousEnd := startDate.Add(-length-time.Nanosecond), startDate.Add(-time.Nanosecond)
+	if subscription.Frequency == types.DigestMonthly {
+		previousStart = startDate.AddDate(0, -1, 0)
+	}
+
+	current, err := s.categoryService.GetStatistics(ctx, requests.CategoryStatisticsRequest{UserID: &user.ID, StartDate: &startDate, EndDate: &endDate})
+	if err != nil {
+		return fmt.Errorf("failed to get statistics: %w", err)
+	}
+	previous, err := s.categoryService.GetStatistics(ctx, requests.CategoryStatisticsRequest{UserID: &user.ID, StartDate: &previousStart, EndDate: &previousEnd})
+	if err != nil {
+		return fmt.Errorf("failed to get previous statistics: %w", err)
+	}
+
+	formatAmount := func(amount float64) string {
+		return utils.FormatNumber(amount, 2, lang) + " " + string(current.Currency)
+	}
+
+	previousAmounts := make(map[uint]float64, len(previous.Categories))
+	for _, category := range previous.Categories {
+		previousAmounts[category.CategoryID] = category.TotalAmount
+	}
+
+	var topCategories []digestCategory
+	for _, category := range current.Categories {
+		if category.CategoryType != types.Expense || len(topCategories) == DigestTopCategories {
+			continue
+		}
+		delta, increased := digestDelta(category.TotalAmount, previousAmounts[category.CategoryID], text, lang)
+		topCategories = append(topCategories, digestCategory{
+			Name:      category.CategoryName,
+			Amount:    formatAmount(category.TotalAmount),
+			Delta:     delta,
+			Increased: increased,
+		})
+	}
+
+	largest, err := s.largestTransactions(ctx, user.ID, startDate, endDate, current.Currency, lang, formatAmount)
+	if err != nil {
+		return err
+	}
+
+	spendingStatus := text.Overspent
+	if current.TotalExpense <= current.TotalIncome && current.TotalIncome > 0 {
+		spendingStatus = fmt.Sprintf(text.Spent, utils.FormatNumber(current.TotalExpense/current.TotalIncome*100, 0, lang))
+	} else if current.TotalExpense == 0 {
+		spendingStatus = ""
+	}
+
+	title, period := text.WeeklyTitle, formatDigestPeriod(startDate, endDate, lang)
+	if subscription.Frequency == types.DigestMonthly {
+		title = text.MonthlyTitle
+		period = fmt.Sprintf("%s %d", statementMonthNames[lang][startDate.Month()-1], startDate.Year())
+	}
+
+	incomeDelta, _ := digestDelta(current.TotalIncome, previous.TotalIncome, text, lang)
+	expenseDelta, _ := digestDelta(current.TotalExpense, previous.TotalExpense, text, lang)
+
+	frontendURL := os.Getenv("FRONTEND_URL")
+	query := url.Values{}
+	query.Set("token", s.unsubscribeToken(user.ID))
+	query.Set("lang", string(lang))
+
+	templatePath := s.mailService.GetEmailTemplatePath(DigestTemplate, lang)
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse digest email template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, map[string]any{
+		"Title":               title,
+		"UserName":            user.Name,
+		"Period":              period,
+		"Income":              formatAmount(current.TotalIncome),
+		"IncomeDelta":         incomeDelta,
+		"Expense":             formatAmount(current.TotalExpense),
+		"ExpenseDelta":        expenseDelta,
+		"Net":                 formatAmount(current.NetBalance),
+		"SpendingStatus":      spendingStatus,
+		"TopCategories":       topCategories,
+		"LargestTransactions": largest,
+		"AppURL":              fmt.Sprintf("%s/?lang=%s", frontendURL, string(lang)),
+		"UnsubscribeURL":      fmt.Sprintf("%s/unsubscribe?%s", frontendURL, query.Encode()),
+	}); err != nil {
+		return fmt.Errorf("failed to render digest email template: %w", err)
+	}
+
+	subject := s.mailService.GetEmailSubject(DigestTemplate, lang)
+
+	return s.mailService.SendHTML(user.Email, subject, body.String())
+}
+
+// largestTransactions returns the records with the largest amounts in the
+// user's currency, with income shown as positive and expenses as negative.
+func (s *DigestService) largestTransactions(ctx context.Context, userID uint, startDate, endDate time.Time, currency types.CurrencyType, lang types.LanguageType, formatAmount func(float64) string) ([]digestTransaction, error) {
+	var records []models.Record
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND date >= ? AND date <= ?", userID, startDate, endDate).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	converted, err := convertRecords(ctx, s.currencyService, records, currency)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(converted, func(i, j int) bool {
+		return converted[i].ConvertedAmount > converted[j].ConvertedAmount
+	})
+	if len(converted) > DigestLargestTransactions {
+		converted = converted[:DigestLargestTransactions]
+	}
+
+	categories, err := s.categoryService.GetAllByExample(ctx, models.Category{UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	categoryMap := make(map[uint]models.Category, len(categories))
+	for _, category := range categories {
+		categoryMap[category.ID] = category
+	}
+
+	dateLayout := "Jan 2"
+	if lang == types.MacedonianLanguage {
+		dateLayout = "02.01"
+	}
+
+	transactions := make([]digestTransaction, 0, len(converted))
+	for _, record := range converted {
+		category := categoryMap[record.CategoryID]
+		sign := "−"
+		if category.Type == types.Income {
+			sign = "+"
+		}
+		description := ""
+		if record.Description != nil {
+			description = *record.Description
+		}
+		transactions = append(transactions, digestTransaction{
+			Date:        record.Date.Format(dateLayout),
+			Category:    category.Name,
+			Description: description,
+			Amount:      sign + formatAmount(record.ConvertedAmount),
+		})
+	}
+	return transactions, nil
+}
+
+// digestDelta describes the change from previous to current and whether it went
+// up.
+func digestDelta(current, previous float64, text digestText, lang types.LanguageType) (string, bool) {
+	if previous == 0 {
+		if current == 0 {
+			return text.NoChange, false
+		}
+		return text.New, true
+	}
+
+	change := (current - previous) / previous * 100
+	if math.Abs(change) < 0.05 {
+		return text.NoChange, false
+	}
+
+	sign := "+"
+	if change < 0 {
+		sign = "−"
+	}
+	return sign + utils.FormatNumber(math.Abs(change), 1, lang) + "%", change > 0
+}
+
+// digestPeriod returns the key, first and last moment in UTC of the period that
+// ended periodsAgo periods before the one containing now. Weeks start on Monday.
+func digestPeriod(frequency types.DigestFrequencyType, now time.Time, periodsAgo int) (string, time.Time, time.Time) {
+	now = now.UTC()
+	if frequency == types.DigestMonthly {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -periodsAgo, 0)
+		return start.Format("2006-01"), start, start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	}
+
+	weekday := (int(now.Weekday()) + 6) % 7
+	start := time.Date(now.Year(), now.Month(), now.Day()-weekday, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -7*periodsAgo)
+	year, week := start.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week), start, start.AddDate(0, 0, 7).Add(-time.Nanosecond)
+}
+
+func formatDigestPeriod(startDate, endDate time.Time, lang types.LanguageType) string {
+	if lang == types.MacedonianLanguage {
+		return fmt.Sprintf("%s – %s", startDate.Format("02.01"), endDate.Format("02.01.2006"))
+	}
+	return fmt.Sprintf("%s – %s", startDate.Format("Jan 2"), endDate.Format("Jan 2, 2006"))
+}
+
+// unsubscribeToken is "<user id>.<signature>". It does not expire, so links in
+// old digests keep working.
+func (s *DigestService) unsubscribeToken(userID uint) string {
+	id := strconv.FormatUint(uint64(userID), 10)
+	return id + "." + s.signUnsubscribe(id)
+}
+
+func (s *DigestService) verifyUnsubscribeToken(token string) (uint, bool) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signUnsubscribe(id))) {
+		return 0, false
+	}
+
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || userID == 0 {
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+func (s *DigestService) signUnsubscribe(id string) string {
+	mac := hmac.New(sha256.New, s.unsubscribeSecret)
+	mac.Write([]byte("digest-unsubscribe:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/clients"
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/responses"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ExportJobLinkDuration  = 24 * time.Hour
+	ExportJobTimeout       = 30 * time.Minute
+	MaxActiveExportJobs    = 3
+	ExportJobHistoryLength = 20
+)
+
+var (
+	ErrTooManyExportJobs   = errors.New("too many exports in progress, wait for one to finish")
+	ErrInvalidDownloadLink = errors.New("invalid or expired download link")
+)
+
+type ExportJobService struct {
+	db            *gorm.DB
+	exportService *ExportService
+	mailService   *MailService
+	blobStorage   clients.BlobStorage
+	linkSecret    []byte
+	baseURL       string
+}
+
+func NewExportJobService(db *gorm.DB, exportService *ExportService, mailService *MailService, blobStorage clients.BlobStorage) *ExportJobService {
+	secret := os.Getenv("EXPORT_LINK_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+
+	// Download links point at the API. Deployments that serve the API under the
+	// frontend origin do not need to set API_URL.
+	baseURL := os.Getenv("API_URL")
+	if baseURL == "" {
+		baseURL = os.Getenv("FRONTEND_URL")
+	}
+
+	return &ExportJobService{
+		db:            db,
+		exportService: exportService,
+		mailService:   mailService,
+		blobStorage:   blobStorage,
+		linkSecret:    []byte(secret),
+		baseURL:       baseURL,
+	}
+}
+
+// CreateJob queues an export. The file is generated by ProcessNextJob.
+func (s *ExportJobService) CreateJob(ctx context.Context, req requests.ExportRequest) (*responses.ExportJobResponse, error) {
+	if req.UserID == 0 {
+		return nil, errors.New("invalid user id")
+	}
+
+	var active int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.ExportJob{}).
+		Where("user_id = ? AND status IN ?", req.UserID, []types.ExportJobStatus{types.ExportJobPending, types.ExportJobProcessing}).
+		Count(&active).Error; err != nil {
+		return nil, fmt.Errorf("failed to count active export jobs: %w", err)
+	}
+	if active >= MaxActiveExportJobs {
+		return nil, ErrTooManyExportJobs
+	}
+
+	categories := make([]string, 0, len(req.Categories))
+	for _, category := range req.Categories {
+		categories = append(categories, string(category))
+	}
+
+	job := models.ExportJob{
+		UserID:     req.UserID,
+		Status:     types.ExportJobPending,
+		Format:     string(req.Format),
+		Categories: categories,
+		StartDate:  req.StartDate,
+		EndDate:    req.EndDate,
+	}
+	if err := s.db.WithContext(ctx).Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create export job: %w", err)
+	}
+
+	return s.toResponse(&job), nil
+}
+
+func (s *ExportJobService) GetJob(ctx context.Context, userID uint, id uint) (*responses.ExportJobResponse, error) {
+	var job models.ExportJob
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+		return nil, err
+	}
+
+	return s.toResponse(&job), nil
+}
+
+// GetHistory returns the most recent export jobs of the user, newest first.
+func (s *ExportJobService) GetHistory(ctx context.Context, userID uint) ([]responses.ExportJobResponse, error) {
+	var jobs []models.ExportJob
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(ExportJobHistoryLength).
+		Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+
+	history := make([]responses.ExportJobResponse, 0, len(jobs))
+	for i := range jobs {
+		history = append(history, *s.toResponse(&jobs[i]))
+	}
+	return history, nil
+}
+
+// ProcessNextJob generates the oldest pending export, if any, and reports
+// whether a job was picked up. Jobs are claimed with SKIP LOCKED so several
+// workers can run side by side.
+func (s *ExportJobService) ProcessNextJob(ctx context.Context) (bool, error) {
+	job, err := s.claimNextJob(ctx)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	categories := make([]dtotypes.ExportCategoryType, 0, len(job.Categories))
+	for _, category := range job.Categories {
+		categories = append(categories, dtotypes.ExportCategoryType(category))
+	}
+	req := requests.ExportRequest{
+		UserID:     job.UserID,
+		Format:     dtotypes.ExportFormatType(job.Format),
+		Categories: categories,
+		StartDate:  job.StartDate,
+		EndDate:    job.EndDate,
+	}
+
+	fileName, _ := ExportFileName(req.Format, job.CreatedAt)
+	storageKey := fmt.Sprintf("exports/%d/%s-%s", job.UserID, uuid.NewString(), fileName)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.exportService.ExportData(ctx, req, pw))
+	}()
+
+	size, err := s.blobStorage.Put(ctx, storageKey, pr)
+	// Unblocks the export if storing failed before it finished writing.
+	pr.CloseWithError(err)
+	if err != nil {
+		log.Printf("🛑 Error!!! export job %d failed: %v", job.ID, err)
+		if failErr := s.failJob(job.ID, "failed to generate export"); failErr != nil {
+			return true, failErr
+		}
+		return true, nil
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ExportJobLinkDuration)
+	if err := s.db.WithContext(ctx).Model(job).Updates(map[string]any{
+		"status":       types.ExportJobCompleted,
+		"storage_key":  storageKey,
+		"file_name":    fileName,
+		"size":         size,
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	}).Error; err != nil {
+		_ = s.blobStorage.Delete(context.Background(), storageKey)
+		return true, fmt.Errorf("failed to complete export job %d: %w", job.ID, err)
+	}
+
+	job.Status = types.ExportJobCompleted
+	job.StorageKey = storageKey
+	job.FileName = fileName
+	job.Size = size
+	job.CompletedAt = &now
+	job.ExpiresAt = &expiresAt
+
+	s.sendExportReadyEmail(ctx, job)
+
+	return true, nil
+}
+
+func (s *ExportJobService) claimNextJob(ctx context.Context) (*models.ExportJob, error) {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var job models.ExportJob
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ?", types.ExportJobPending).
+		Order("created_at").
+		First(&job).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch pending export job: %w", err)
+	}
+
+	now := time.Now()
+	if err := tx.Model(&job).Updates(map[string]any{
+		"status":     types.ExportJobProcessing,
+		"started_at": now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to claim export job: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit export job claim: %w", err)
+	}
+
+	return &job, nil
+}
+
+// failJob runs on its own context because the job context may be the reason the
+// export failed.
+func (s *ExportJobService) failJob(id uint, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.db.WithContext(ctx).Model(&models.ExportJob{}).Where("id = ?", id).Updates(map[string]any{
+		"status":       types.ExportJobFailed,
+		"error":        message,
+		"completed_at": time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to mark export job %d as failed: %w", id, err)
+	}
+	return nil
+}
+
+// OpenDownload checks a signed download link and opens the export file it
+// points to. The caller must close the returned reader.
+func (s *ExportJobService) OpenDownload(ctx context.Context, id uint, expires int64, signature string) (*models.ExportJob, io.ReadCloser, error) {
+	if time.Now().Unix() > expires {
+		return nil, nil, ErrInvalidDownloadLink
+	}
+
+	var job models.ExportJob
+	if err := s.db.WithContext(ctx).
+		Where("id = ? AND status = ?", id, types.ExportJobCompleted).
+		First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidDownloadLink
+		}
+		return nil, nil, err
+	}
+
+	if job.ExpiresAt == nil || job.ExpiresAt.Unix() != expires || !hmac.Equal([]byte(signature), []byte(s.signDownload(&job))) {
+		return nil, nil, ErrInvalidDownloadLink
+	}
+
+	file, err := s.blobStorage.Open(ctx, job.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open export file: %w", err)
+	}
+
+	return &job, file, nil
+}
+
+// CleanupExpiredExports deletes the files of exports whose link has expired and
+// fails jobs that have been processing for longer than ExportJobTimeout, which
+// means the worker handling them stopped.
+func (s *ExportJobService) CleanupExpiredExports(ctx context.Context) (int64, error) {
+	if err := s.db.WithContext(ctx).Model(&models.ExportJob{}).
+		Where("status = ? AND started_at < ?", types.ExportJobProcessing, time.Now().Add(-ExportJobTimeout)).
+		Updates(map[string]any{
+			"status":       types.ExportJobFailed,
+			"error":        "export timed out",
+			"completed_at": time.Now(),
+		}).Error; err != nil {
+		return 0, fmt.Errorf("failed to fail stale export jobs: %w", err)
+	}
+
+	var jobs []models.ExportJob
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", types.ExportJobCompleted, time.Now()).
+		Find(&jobs).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch expired export jobs: %w", err)
+	}
+
+	var removed int64
+	for _, job := range jobs {
+		if err := s.blobStorage.Delete(ctx, job.StorageKey); err != nil {
+			return removed, fmt.Errorf("failed to delete export file of job %d: %w", job.ID, err)
+		}
+		if err := s.db.WithContext(ctx).Model(&job).Updates(map[string]any{
+			"status":      types.ExportJobExpired,
+			"storage_key": "",
+		}).Error; err != nil {
+			return removed, fmt.Errorf("failed to expire export job %d: %w", job.ID, err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (s *ExportJobService) toResponse(job *models.ExportJob) *responses.ExportJobResponse {
+	response := &responses.ExportJobResponse{
+		ID:          job.ID,
+		Status:      job.Status,
+		Format:      job.Format,
+		Categories:  job.Categories,
+		StartDate:   job.StartDate,
+		EndDate:     job.EndDate,
+		FileName:    job.FileName,
+		Size:        job.Size,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt,
+		StartedAt:   job.StartedAt,
+		CompletedAt: job.CompletedAt,
+		ExpiresAt:   job.ExpiresAt,
+	}
+
+	if job.Status == types.ExportJobCompleted && job.ExpiresAt != nil && job.ExpiresAt.After(time.Now()) {
+		downloadURL := s.downloadURL(job)
+		response.DownloadURL = &downloadURL
+	}
+
+	return response
+}
+
+func (s *ExportJobService) downloadURL(job *models.ExportJob) string {
+	query := url.Values{}
+	query.Set("expires", fmt.Sprintf("%d", job.ExpiresAt.Unix()))
+	query.Set("signature", s.signDownload(job))
+	return fmt.Sprintf("%s/api/v1/exports/%d/download?%s", s.baseURL, job.ID, query.Encode())
+}
+
+// signDownload signs the job, its file and the link expiry, so a link stops
+// working once it expires or the file is replaced.
+func (s *ExportJobService) signDownload(job *models.ExportJob) string {
+	mac := hmac.New(sha256.New, s.linkSecret)
+	fmt.Fprintf(mac, "%d:%d:%s", job.ID, job.ExpiresAt.Unix(), job.StorageKey)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *ExportJobService) sendExportReadyEmail(ctx context.Context, job *models.ExportJob) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", job.UserID).First(&user).Error; err != nil {
+		log.Printf("failed to fetch user for export ready email: %v", err)
+		return
+	}
+
+	var language types.LanguageType
+	var setting models.Setting
+	if err := s.db.WithContext(ctx).Where("user_id = ?", user.ID).First(&setting).Error; err == nil {
+		language = setting.Language
+	} else {
+		language = types.EnglishLanguage
+	}
+
+	dateLayout := "January 2, 2006 15:04 MST"
+	if language == types.MacedonianLanguage {
+		dateLayout = "02.01.2006 15:04 MST"
+	}
+
+	templatePath := s.mailService.GetEmailTemplatePath(ExportReadyTemplate, language)
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		log.Printf("failed to parse export ready email template: %v", err)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, map[string]string{
+		"UserName":    user.Name,
+		"RequestedAt": job.CreatedAt.UTC().Format(dateLayout),
+		"ExpiresAt":   job.ExpiresAt.UTC().Format(dateLayout),
+		"DownloadURL": s.downloadURL(job),
+	}); err != nil {
+		log.Printf("failed to render export ready email template: %v", err)
+		return
+	}
+
+	subject := s.mailService.GetEmailSubject(ExportReadyTemplate, language)
+
+	if err := s.mailService.SendHTML(user.Email, subject, body.String()); err != nil {
+		log.Printf("failed to send export ready email to %s: %v", user.Email, err)
+	}
+}
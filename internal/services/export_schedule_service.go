@@ -0,0 +1,242 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/clients"
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"gorm.io/gorm"
+)
+
+// MaxScheduledExportAttachmentSize keeps attachments well below what mail
+// providers accept. Larger exports are sent as a download link instead.
+const MaxScheduledExportAttachmentSize = 10 << 20
+
+var ScheduledExportFormats = map[dtotypes.ExportFormatType]bool{
+	dtotypes.ExportFormatCSV:  true,
+	dtotypes.ExportFormatXLSX: true,
+	dtotypes.ExportFormatPDF:  true,
+}
+
+var errAttachmentTooLarge = errors.New("export is too large to attach")
+
+type ExportScheduleService struct {
+	db               *gorm.DB
+	exportService    *ExportService
+	exportJobService *ExportJobService
+	mailService      *MailService
+}
+
+func NewExportScheduleService(db *gorm.DB, exportService *ExportService, exportJobService *ExportJobService, mailService *MailService) *ExportScheduleService {
+	return &ExportScheduleService{
+		db:               db,
+		exportService:    exportService,
+		exportJobService: exportJobService,
+		mailService:      mailService,
+	}
+}
+
+func (s *ExportScheduleService) GetByUserID(ctx context.Context, userID uint) (*models.ExportSchedule, error) {
+	var schedule models.ExportSchedule
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// Save creates or updates the schedule of the user. A new schedule starts with
+// the month that is running now, so the first export arrives next month.
+func (s *ExportScheduleService) Save(ctx context.Context, req requests.ExportScheduleRequest) (*models.ExportSchedule, error) {
+	if req.UserID == 0 {
+		return nil, errors.New("invalid user id")
+	}
+	if !ScheduledExportFormats[dtotypes.ExportFormatType(req.Format)] {
+		return nil, errors.New("invalid format, expected CSV, XLSX or PDF")
+	}
+	if !types.IsValidExportDeliveryType(req.Delivery) {
+		return nil, errors.New("invalid delivery, expected ATTACHMENT or LINK")
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	schedule, err := s.GetByUserID(ctx, req.UserID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if schedule == nil {
+		lastPeriod, _, _ := scheduledExportPeriod(time.Now())
+		schedule = &models.ExportSchedule{
+			UserID:     req.UserID,
+			Format:     req.Format,
+			Delivery:   req.Delivery,
+			Enabled:    enabled,
+			LastPeriod: &lastPeriod,
+		}
+		if err := s.db.WithContext(ctx).Create(schedule).Error; err != nil {
+			return nil, fmt.Errorf("failed to create export schedule: %w", err)
+		}
+		return schedule, nil
+	}
+
+	schedule.Format = req.Format
+	schedule.Delivery = req.Delivery
+	schedule.Enabled = enabled
+	if err := s.db.WithContext(ctx).Save(schedule).Error; err != nil {
+		return nil, fmt.Errorf("failed to update export schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+func (s *ExportScheduleService) Delete(ctx context.Context, userID uint) error {
+	result := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.ExportSchedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RunDueSchedules delivers the export of the previous month to every enabled
+// schedule that has not received it yet. A schedule whose delivery fails is
+// retried on the next run.
+func (s *ExportScheduleService) RunDueSchedules(ctx context.Context, now time.Time) (int, error) {
+	period, startDate, endDate := scheduledExportPeriod(now)
+
+	var schedules []models.ExportSchedule
+	if err := s.db.WithContext(ctx).
+		Joins("JOIN users ON users.id = export_schedules.user_id AND users.deleted_at IS NULL").
+		Where("export_schedules.enabled = ? AND (export_schedules.last_period IS NULL OR export_schedules.last_period <> ?)", true, period).
+		Find(&schedules).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch due export schedules: %w", err)
+	}
+
+	delivered := 0
+	for i := range schedules {
+		if err := s.runSchedule(ctx, &schedules[i], period, startDate, endDate); err != nil {
+			log.Printf("🛑 Error!!! scheduled export for user %d failed: %v", schedules[i].UserID, err)
+			continue
+		}
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+func (s *ExportScheduleService) runSchedule(ctx context.Context, schedule *models.ExportSchedule, period string, startDate, endDate time.Time) error {
+	req := requests.ExportRequest{
+		UserID:     schedule.UserID,
+		Format:     dtotypes.ExportFormatType(schedule.Format),
+		Categories: []dtotypes.ExportCategoryType{dtotypes.ExportCategoryRecords},
+		StartDate:  &startDate,
+		EndDate:    &endDate,
+	}
+
+	delivery := schedule.Delivery
+	var attachment bytes.Buffer
+	if delivery == types.ExportDeliveryAttachment {
+		err := s.exportService.ExportData(ctx, req, &limitedWriter{w: &attachment, remaining: MaxScheduledExportAttachmentSize})
+		if errors.Is(err, errAttachmentTooLarge) {
+			delivery = types.ExportDeliveryLink
+		} else if err != nil {
+			return fmt.Errorf("failed to generate export: %w", err)
+		}
+	}
+
+	if delivery == types.ExportDeliveryLink {
+		// The export job emails the download link once the file is ready.
+		if _, err := s.exportJobService.CreateJob(ctx, req); err != nil {
+			return fmt.Errorf("failed to queue export: %w", err)
+		}
+	} else if err := s.sendScheduledExportEmail(ctx, schedule, startDate, attachment.Bytes()); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.db.WithContext(ctx).Model(schedule).Updates(map[string]any{
+		"last_period": period,
+		"last_run_at": now,
+	}).Error
+}
+
+func (s *ExportScheduleService) sendScheduledExportEmail(ctx context.Context, schedule *models.ExportSchedule, startDate time.Time, content []byte) error {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", schedule.UserID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	var language types.LanguageType
+	var setting models.Setting
+	if err := s.db.WithContext(ctx).Where("user_id = ?", user.ID).First(&setting).Error; err == nil {
+		language = setting.Language
+	} else {
+		language = types.EnglishLanguage
+	}
+	if !types.IsValidLanguageType(language) {
+		language = types.EnglishLanguage
+	}
+
+	templatePath := s.mailService.GetEmailTemplatePath(ScheduledExportTemplate, language)
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse scheduled export email template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, map[string]string{
+		"UserName":    user.Name,
+		"Format":      schedule.Format,
+		"Period":      fmt.Sprintf("%s %d", statementMonthNames[language][startDate.Month()-1], startDate.Year()),
+		"SettingsURL": fmt.Sprintf("%s/settings?lang=%s", os.Getenv("FRONTEND_URL"), string(language)),
+	}); err != nil {
+		return fmt.Errorf("failed to render scheduled export email template: %w", err)
+	}
+
+	fileName, contentType := ExportFileName(dtotypes.ExportFormatType(schedule.Format), startDate)
+	subject := s.mailService.GetEmailSubject(ScheduledExportTemplate, language)
+
+	return s.mailService.SendHTMLWithAttachments(user.Email, subject, body.String(), []clients.MailAttachment{{
+		Filename:    fileName,
+		ContentType: contentType,
+		Content:     content,
+	}})
+}
+
+// scheduledExportPeriod returns the month before now as "2006-01" together with
+// its first and last moment in UTC.
+func scheduledExportPeriod(now time.Time) (string, time.Time, time.Time) {
+	thisMonth := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := thisMonth.AddDate(0, -1, 0)
+	end := thisMonth.Add(-time.Nanosecond)
+	return start.Format("2006-01"), start, end
+}
+
+// limitedWriter fails with errAttachmentTooLarge once more than remaining bytes
+// have been written.
+type limitedWriter struct {
+	w         *bytes.Buffer
+	remaining int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > l.remaining {
+		return 0, errAttachmentTooLarge
+	}
+	l.remaining -= len(p)
+	return l.w.Write(p)
+}
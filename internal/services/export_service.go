@@ -7,26 +7,40 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"filippo.io/age"
+	"github.com/emilijan-koteski/monexa/internal/bankfile"
 	"github.com/emilijan-koteski/monexa/internal/dtos"
 	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/journal"
+	"github.com/emilijan-koteski/monexa/internal/models"
 	"github.com/emilijan-koteski/monexa/internal/models/types"
 	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/xuri/excelize/v2"
 	"gorm.io/gorm"
 )
 
 type ExportService struct {
 	db                     *gorm.DB
 	settingService         *SettingService
+	statementService       *StatementService
+	backupService          *BackupService
 	legalComplianceEnabled bool
 }
 
-func NewExportService(db *gorm.DB, settingService *SettingService, legalComplianceEnabled bool) *ExportService {
+func NewExportService(db *gorm.DB, settingService *SettingService, statementService *StatementService, backupService *BackupService, legalComplianceEnabled bool) *ExportService {
 	return &ExportService{
 		db:                     db,
 		settingService:         settingService,
+		statementService:       statementService,
+		backupService:          backupService,
 		legalComplianceEnabled: legalComplianceEnabled,
 	}
 }
@@ -61,10 +75,249 @@ var consentCSVHeaders = map[types.LanguageType][]string{
 	types.MacedonianLanguage: {"Документ", "Верзија", "Прифатено на", "IP адреса", "User Agent"},
 }
 
-func (s *ExportService) ExportData(ctx context.Context, req requests.ExportRequest) ([]byte, error) {
+var exportSheetNames = map[types.LanguageType]map[dtotypes.ExportCategoryType]string{
+	types.EnglishLanguage: {
+		dtotypes.ExportCategoryProfile:        "Profile",
+		dtotypes.ExportCategoryRecords:        "Records",
+		dtotypes.ExportCategoryCategories:     "Categories",
+		dtotypes.ExportCategoryPaymentMethods: "Payment Methods",
+		dtotypes.ExportCategoryPreferences:    "Preferences",
+		dtotypes.ExportCategoryConsent:        "Consent History",
+	},
+	types.MacedonianLanguage: {
+		dtotypes.ExportCategoryProfile:        "Профил",
+		dtotypes.ExportCategoryRecords:        "Записи",
+		dtotypes.ExportCategoryCategories:     "Категории",
+		dtotypes.ExportCategoryPaymentMethods: "Начини на плаќање",
+		dtotypes.ExportCategoryPreferences:    "Преференци",
+		dtotypes.ExportCategoryConsent:        "Историја на согласности",
+	},
+}
+
+const (
+	EncryptedExportFormat     = "monexa-encrypted-export"
+	MinExportPassphraseLength = 12
+	// ExportScryptLogN is the scrypt work factor, age's default of 2^18.
+	ExportScryptLogN = 18
+)
+
+// xlsxWidthSampleRows is how many rows of a sheet are used to size its columns.
+const xlsxWidthSampleRows = 100
+
+var journalExportPaths = map[dtotypes.ExportFormatType]string{
+	dtotypes.ExportFormatLedger:    "journal/monexa.ledger",
+	dtotypes.ExportFormatHledger:   "journal/monexa.journal",
+	dtotypes.ExportFormatBeancount: "journal/monexa.beancount",
+}
+
+type exportSummaryText struct {
+	Sheet       string
+	Title       string
+	GeneratedAt string
+	Period      string
+	AllTime     string
+	Contents    string
+	Rows        string
+	Totals      string
+	Currency    string
+	Income      string
+	Expense     string
+	Net         string
+}
+
+var exportSummaryTexts = map[types.LanguageType]exportSummaryText{
+	types.EnglishLanguage: {
+		Sheet:       "Summary",
+		Title:       "Monexa data export",
+		GeneratedAt: "Generated At",
+		Period:      "Period",
+		AllTime:     "All time",
+		Contents:    "Sheet",
+		Rows:        "Rows",
+		Totals:      "Record totals",
+		Currency:    "Currency",
+		Income:      "Income",
+		Expense:     "Expense",
+		Net:         "Net",
+	},
+	types.MacedonianLanguage: {
+		Sheet:       "Преглед",
+		Title:       "Monexa извоз на податоци",
+		GeneratedAt: "Генерирано на",
+		Period:      "Период",
+		AllTime:     "Целокупен период",
+		Contents:    "Лист",
+		Rows:        "Редови",
+		Totals:      "Вкупно по записи",
+		Currency:    "Валута",
+		Income:      "Приход",
+		Expense:     "Расход",
+		Net:         "Нето",
+	},
+}
+
+// exportTable is the format independent result of exporting one category. Rows
+// hold typed values so every format can render them natively. They are read one
+// at a time from a database cursor, so the size of an export does not decide how
+// much of it is held in memory.
+type exportTable struct {
+	headers []string
+	// single tables hold at most one row, which JSON renders as an object.
+	single bool
+	each   func(fn func(cells []any, data any) error) error
+}
+
+// exportDate marks a cell that holds a calendar date without a time of day.
+type exportDate time.Time
+
+type exportSection struct {
+	category dtotypes.ExportCategoryType
+	path     string
+	table    *exportTable
+}
+
+// ExportData writes the export to w as it is produced. Nothing is buffered per
+// export except for XLSX workbooks, whose sheets excelize spills to temporary
+// files and whose compressed output it assembles before writing, and PDF
+// statements, which are rendered as a whole and therefore limited to
+// MaxStatementRecords records.
+func (s *ExportService) ExportData(ctx context.Context, req requests.ExportRequest, w io.Writer) error {
+	if req.Passphrase != "" {
+		return s.writeEncryptedExport(ctx, req, w)
+	}
+	return s.writeExport(ctx, req, w)
+}
+
+// writeEncryptedExport wraps the export in an age file encrypted with a key
+// derived from the passphrase. It is stored in a zip next to a plaintext
+// manifest that documents the scheme and KDF parameters.
+func (s *ExportService) writeEncryptedExport(ctx context.Context, req requests.ExportRequest, w io.Writer) error {
+	if len([]rune(req.Passphrase)) < MinExportPassphraseLength {
+		return fmt.Errorf("passphrase must be at least %d characters", MinExportPassphraseLength)
+	}
+
+	recipient, err := age.NewScryptRecipient(req.Passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to create encryption recipient: %w", err)
+	}
+	recipient.SetWorkFactor(ExportScryptLogN)
+
+	now := time.Now().UTC()
+	fileName, contentType := ExportFileName(req.Format, now)
+	payload := fileName + ".age"
+
+	manifest := dtos.EncryptedExportManifest{
+		Format:      EncryptedExportFormat,
+		Version:     1,
+		CreatedAt:   now,
+		Payload:     payload,
+		ContentType: contentType,
+		Encryption: dtos.EncryptedExportEncryption{
+			Scheme: "age-encryption.org/v1",
+			Cipher: "ChaCha20-Poly1305",
+			KDF: dtos.EncryptedExportKDF{
+				Algorithm: "scrypt",
+				LogN:      ExportScryptLogN,
+				R:         8,
+				P:         1,
+				SaltBytes: 16,
+				SaltLabel: "age-encryption.org/v1/scrypt",
+				KeyBytes:  32,
+			},
+		},
+		Instructions: fmt.Sprintf("Decrypt with any age implementation, for example: age --decrypt -o %s %s", fileName, payload),
+	}
+	manifestData, err := buildJSON(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to build manifest: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry manifest.json: %w", err)
+	}
+	if _, err := f.Write(manifestData); err != nil {
+		return fmt.Errorf("failed to write zip entry manifest.json: %w", err)
+	}
+
+	// Encrypted data does not compress, so the payload is stored as is.
+	f, err = zw.CreateHeader(&zip.FileHeader{Name: payload, Method: zip.Store, Modified: now})
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry %s: %w", payload, err)
+	}
+	encrypted, err := age.Encrypt(f, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to start encryption: %w", err)
+	}
+	if err := s.writeExport(ctx, req, encrypted); err != nil {
+		return err
+	}
+	if err := encrypted.Close(); err != nil {
+		return fmt.Errorf("failed to finish encryption: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %w", err)
+	}
+	return nil
+}
+
+func (s *ExportService) writeExport(ctx context.Context, req requests.ExportRequest, w io.Writer) error {
+	// A PDF export is a single statement over the requested date range rather than
+	// one file per category.
+	if req.Format == dtotypes.ExportFormatPDF {
+		data, err := s.statementService.GenerateStatementForRange(ctx, req.UserID, req.StartDate, req.EndDate)
+		if err != nil {
+			return fmt.Errorf("failed to export statement: %w", err)
+		}
+
+		zw := zip.NewWriter(w)
+		f, err := zw.Create("statement/statement.pdf")
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry statement/statement.pdf: %w", err)
+		}
+		if _, err := f.Write(data); err != nil {
+			return fmt.Errorf("failed to write zip entry statement/statement.pdf: %w", err)
+		}
+		return closeZIP(zw)
+	}
+
+	// A backup always contains everything that can be restored, so categories and
+	// dates do not apply.
+	if req.Format == dtotypes.ExportFormatBackup {
+		if err := s.backupService.WriteBackup(ctx, req.UserID, w); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+		return nil
+	}
+
+	// OFX and QIF files are per account, so there is one file per payment method.
+	if req.Format == dtotypes.ExportFormatOFX || req.Format == dtotypes.ExportFormatQIF {
+		zw := zip.NewWriter(w)
+		if err := s.exportBankFiles(ctx, req, zw); err != nil {
+			return fmt.Errorf("failed to export %s files: %w", req.Format, err)
+		}
+		return closeZIP(zw)
+	}
+
+	// Plain-text accounting formats export the records as one journal file.
+	if path, ok := journalExportPaths[req.Format]; ok {
+		zw := zip.NewWriter(w)
+		f, err := zw.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry %s: %w", path, err)
+		}
+		if err := s.exportJournal(ctx, req, f); err != nil {
+			return fmt.Errorf("failed to export journal: %w", err)
+		}
+		return closeZIP(zw)
+	}
+
 	setting, err := s.settingService.GetByUserID(ctx, req.UserID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user settings: %w", err)
+		return fmt.Errorf("failed to get user settings: %w", err)
 	}
 
 	lang := setting.Language
@@ -86,109 +339,172 @@ func (s *ExportService) ExportData(ctx context.Context, req requests.ExportReque
 		delete(categorySet, dtotypes.ExportCategoryConsent)
 	}
 
-	ext := strings.ToLower(string(req.Format))
-	files := make(map[string][]byte)
+	// Tables are lazy, so no data is read until a section is written.
+	var sections []exportSection
 
 	if categorySet[dtotypes.ExportCategoryProfile] {
-		data, err := s.exportProfile(ctx, req.UserID, req.Format, lang)
-		if err != nil {
-			return nil, fmt.Errorf("failed to export profile: %w", err)
-		}
-		if data != nil {
-			files["profile/profile."+ext] = data
-		}
+		sections = append(sections, exportSection{dtotypes.ExportCategoryProfile, "profile/profile", s.exportProfile(ctx, req.UserID, lang)})
 	}
-
 	if categorySet[dtotypes.ExportCategoryRecords] {
-		data, err := s.exportRecords(ctx, req.UserID, req.StartDate, req.EndDate, req.Format, lang)
-		if err != nil {
-			return nil, fmt.Errorf("failed to export records: %w", err)
-		}
-		if data != nil {
-			files["records/records."+ext] = data
-		}
+		sections = append(sections, exportSection{dtotypes.ExportCategoryRecords, "records/records", s.exportRecords(ctx, req.UserID, req.StartDate, req.EndDate, lang)})
 	}
-
 	if categorySet[dtotypes.ExportCategoryCategories] {
-		data, err := s.exportCategories(ctx, req.UserID, req.Format, lang)
-		if err != nil {
-			return nil, fmt.Errorf("failed to export categories: %w", err)
-		}
-		if data != nil {
-			files["categories/categories."+ext] = data
-		}
+		sections = append(sections, exportSection{dtotypes.ExportCategoryCategories, "categories/categories", s.exportCategories(ctx, req.UserID, lang)})
 	}
-
 	if categorySet[dtotypes.ExportCategoryPaymentMethods] {
-		data, err := s.exportPaymentMethods(ctx, req.UserID, req.Format, lang)
-		if err != nil {
-			return nil, fmt.Errorf("failed to export payment methods: %w", err)
-		}
-		if data != nil {
-			files["payment-methods/payment-methods."+ext] = data
-		}
+		sections = append(sections, exportSection{dtotypes.ExportCategoryPaymentMethods, "payment-methods/payment-methods", s.exportPaymentMethods(ctx, req.UserID, lang)})
 	}
-
 	if categorySet[dtotypes.ExportCategoryPreferences] {
-		data, err := s.exportPreferences(ctx, req.UserID, req.Format, lang)
-		if err != nil {
-			return nil, fmt.Errorf("failed to export preferences: %w", err)
-		}
-		if data != nil {
-			files["preferences/preferences."+ext] = data
+		sections = append(sections, exportSection{dtotypes.ExportCategoryPreferences, "preferences/preferences", s.exportPreferences(ctx, req.UserID, lang)})
+	}
+	if categorySet[dtotypes.ExportCategoryConsent] {
+		sections = append(sections, exportSection{dtotypes.ExportCategoryConsent, "consent-history/consent-history", s.exportConsent(ctx, req.UserID, lang)})
+	}
+
+	if req.Format == dtotypes.ExportFormatXLSX {
+		var recordTotals []dtos.RecordTotalExportRow
+		if categorySet[dtotypes.ExportCategoryRecords] {
+			recordTotals, err = s.exportRecordTotals(ctx, req.UserID, req.StartDate, req.EndDate)
+			if err != nil {
+				return fmt.Errorf("failed to export record totals: %w", err)
+			}
 		}
+		return writeXLSX(w, sections, recordTotals, req.StartDate, req.EndDate, lang)
 	}
 
-	if categorySet[dtotypes.ExportCategoryConsent] {
-		data, err := s.exportConsent(ctx, req.UserID, req.Format, lang)
-		if err != nil {
-			return nil, fmt.Errorf("failed to export consent history: %w", err)
+	zw := zip.NewWriter(w)
+	ext := strings.ToLower(string(req.Format))
+	for _, section := range sections {
+		path := section.path + "." + ext
+		if req.Format == dtotypes.ExportFormatJSON {
+			err = writeJSONEntry(zw, path, section.table)
+		} else {
+			err = writeCSVEntry(zw, path, section.table)
 		}
-		if data != nil {
-			files["consent-history/consent-history."+ext] = data
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", section.category, err)
 		}
 	}
 
-	return buildZIP(files)
+	return closeZIP(zw)
+}
+
+// ExportFileName returns the download file name and content type of an export.
+// XLSX exports are a single workbook, every other format is zipped.
+func ExportFileName(format dtotypes.ExportFormatType, date time.Time) (string, string) {
+	name, extension, contentType := "monexa-data-export", "zip", "application/zip"
+	switch format {
+	case dtotypes.ExportFormatXLSX:
+		extension, contentType = "xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case dtotypes.ExportFormatBackup:
+		name = "monexa-backup"
+	}
+
+	return fmt.Sprintf("%s-%s.%s", name, date.Format("2006-01-02"), extension), contentType
 }
 
-func (s *ExportService) exportProfile(ctx context.Context, userID uint, format dtotypes.ExportFormatType, lang types.LanguageType) ([]byte, error) {
-	var row dtos.ProfileExportRow
+// EncryptedExportFileName returns the download file name of a passphrase
+// protected export, which is always a zip.
+func EncryptedExportFileName(format dtotypes.ExportFormatType, date time.Time) string {
+	fileName, _ := ExportFileName(format, date)
+	return strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "-encrypted.zip"
+}
 
-	err := s.db.WithContext(ctx).
-		Table("users").
-		Select("name, email, created_at").
-		Where("id = ? AND deleted_at IS NULL", userID).
-		Scan(&row).Error
+// streamRows runs the query and calls fn for every row as it comes off the
+// cursor instead of loading the whole result set.
+func streamRows[T any](query *gorm.DB, fn func(row T) error) error {
+	rows, err := query.Rows()
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer rows.Close()
 
-	if row.Name == "" && row.Email == "" {
-		return nil, nil
+	for rows.Next() {
+		var row T
+		if err := query.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
 	}
+	return rows.Err()
+}
 
-	if format == dtotypes.ExportFormatJSON {
-		return buildJSON(row)
+func (s *ExportService) exportProfile(ctx context.Context, userID uint, lang types.LanguageType) *exportTable {
+	return &exportTable{
+		headers: getHeaders(profileCSVHeaders, lang),
+		single:  true,
+		each: func(fn func(cells []any, data any) error) error {
+			var row dtos.ProfileExportRow
+
+			err := s.db.WithContext(ctx).
+				Table("users").
+				Select("name, email, created_at").
+				Where("id = ? AND deleted_at IS NULL", userID).
+				Scan(&row).Error
+			if err != nil {
+				return err
+			}
+
+			if row.Name == "" && row.Email == "" {
+				return nil
+			}
+
+			return fn([]any{row.Name, row.Email, exportDate(row.CreatedAt)}, row)
+		},
 	}
+}
 
-	headers := getHeaders(profileCSVHeaders, lang)
-	rows := [][]string{{
-		row.Name,
-		row.Email,
-		row.CreatedAt.Format("2006-01-02"),
-	}}
-	return buildCSV(headers, rows)
+func (s *ExportService) exportRecords(ctx context.Context, userID uint, startDate *time.Time, endDate *time.Time, lang types.LanguageType) *exportTable {
+	return &exportTable{
+		headers: getHeaders(recordCSVHeaders, lang),
+		each: func(fn func(cells []any, data any) error) error {
+			query := s.db.WithContext(ctx).
+				Table("records").
+				Select("payment_methods.name as payment_method_name, categories.name as category_name, records.amount, records.currency, records.date, records.description").
+				Joins("LEFT JOIN categories ON categories.id = records.category_id").
+				Joins("LEFT JOIN payment_methods ON payment_methods.id = records.payment_method_id").
+				Where("records.user_id = ? AND records.deleted_at IS NULL", userID)
+
+			if startDate != nil {
+				query = query.Where("records.date >= ?", *startDate)
+			}
+			if endDate != nil {
+				query = query.Where("records.date <= ?", *endDate)
+			}
+
+			query = query.Order("records.date DESC")
+
+			return streamRows(query, func(row dtos.RecordExportRow) error {
+				description := ""
+				if row.Description != nil {
+					description = *row.Description
+				}
+				return fn([]any{
+					row.PaymentMethodName,
+					row.CategoryName,
+					row.Amount,
+					string(row.Currency),
+					exportDate(row.Date),
+					description,
+				}, row)
+			})
+		},
+	}
 }
 
-func (s *ExportService) exportRecords(ctx context.Context, userID uint, startDate *time.Time, endDate *time.Time, format dtotypes.ExportFormatType, lang types.LanguageType) ([]byte, error) {
-	var rows []dtos.RecordExportRow
+// exportRecordTotals sums the exported records per currency and category type.
+// Amounts stay in their original currency.
+func (s *ExportService) exportRecordTotals(ctx context.Context, userID uint, startDate *time.Time, endDate *time.Time) ([]dtos.RecordTotalExportRow, error) {
+	var rows []dtos.RecordTotalExportRow
 
 	query := s.db.WithContext(ctx).
 		Table("records").
-		Select("payment_methods.name as payment_method_name, categories.name as category_name, records.amount, records.currency, records.date, records.description").
+		Select("records.currency, "+
+			"COALESCE(SUM(CASE WHEN categories.type = 'INCOME' THEN records.amount ELSE 0 END), 0) as income, "+
+			"COALESCE(SUM(CASE WHEN categories.type = 'EXPENSE' THEN records.amount ELSE 0 END), 0) as expense").
 		Joins("LEFT JOIN categories ON categories.id = records.category_id").
-		Joins("LEFT JOIN payment_methods ON payment_methods.id = records.payment_method_id").
 		Where("records.user_id = ? AND records.deleted_at IS NULL", userID)
 
 	if startDate != nil {
@@ -198,171 +514,465 @@ func (s *ExportService) exportRecords(ctx context.Context, userID uint, startDat
 		query = query.Where("records.date <= ?", *endDate)
 	}
 
-	query = query.Order("records.date DESC")
-
-	if err := query.Find(&rows).Error; err != nil {
+	if err := query.Group("records.currency").Order("records.currency").Find(&rows).Error; err != nil {
 		return nil, err
 	}
 
-	if len(rows) == 0 {
-		return nil, nil
+	return rows, nil
+}
+
+// exportJournal renders the records as double-entry transactions between the
+// payment method asset account and the category income or expense account,
+// with price directives for every foreign currency the records use. The
+// directives a journal starts with are collected with queries of their own, so
+// the transactions can be streamed from the cursor after them.
+func (s *ExportService) exportJournal(ctx context.Context, req requests.ExportRequest, w io.Writer) error {
+	setting, err := s.settingService.GetByUserID(ctx, req.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get user settings: %w", err)
 	}
 
-	if format == dtotypes.ExportFormatJSON {
-		return buildJSON(rows)
+	firstDate, err := recordDate(s.transactionRowsQuery(ctx, req.UserID, req.StartDate, req.EndDate), "records.date ASC")
+	if err != nil {
+		return err
+	}
+	lastDate, err := recordDate(s.transactionRowsQuery(ctx, req.UserID, req.StartDate, req.EndDate), "records.date DESC")
+	if err != nil {
+		return err
 	}
 
-	headers := getHeaders(recordCSVHeaders, lang)
-	csvRows := make([][]string, 0, len(rows))
-	for _, row := range rows {
-		description := ""
-		if row.Description != nil {
-			description = *row.Description
-		}
-		csvRows = append(csvRows, []string{
-			row.PaymentMethodName,
-			row.CategoryName,
-			fmt.Sprintf("%.2f", row.Amount),
-			string(row.Currency),
-			row.Date.Format("2006-01-02"),
-			description,
-		})
+	var currencies []types.CurrencyType
+	if err := s.transactionRowsQuery(ctx, req.UserID, req.StartDate, req.EndDate).
+		Distinct().
+		Pluck("records.currency", &currencies).Error; err != nil {
+		return fmt.Errorf("failed to get record currencies: %w", err)
 	}
-	return buildCSV(headers, csvRows)
-}
 
-func (s *ExportService) exportCategories(ctx context.Context, userID uint, format dtotypes.ExportFormatType, lang types.LanguageType) ([]byte, error) {
-	var rows []dtos.CategoryExportRow
+	var paymentMethodNames []string
+	if err := s.transactionRowsQuery(ctx, req.UserID, req.StartDate, req.EndDate).
+		Distinct().
+		Pluck("COALESCE(payment_methods.name, '')", &paymentMethodNames).Error; err != nil {
+		return fmt.Errorf("failed to get payment methods: %w", err)
+	}
 
-	err := s.db.WithContext(ctx).
-		Table("categories").
-		Select("name, type, COALESCE(description, '') as description").
-		Where("user_id = ? AND deleted_at IS NULL", userID).
-		Find(&rows).Error
-	if err != nil {
-		return nil, err
+	var categories []dtos.TransactionExportRow
+	if err := s.transactionRowsQuery(ctx, req.UserID, req.StartDate, req.EndDate).
+		Select("DISTINCT categories.name as category_name, categories.type as category_type").
+		Scan(&categories).Error; err != nil {
+		return fmt.Errorf("failed to get categories: %w", err)
 	}
 
-	if len(rows) == 0 {
-		return nil, nil
+	j := journal.Journal{
+		Title:              "Monexa",
+		OperatingCommodity: string(setting.Currency),
+		Accounts:           make([][]string, 0, len(paymentMethodNames)+len(categories)),
+	}
+	if firstDate != nil {
+		j.FirstDate = *firstDate
 	}
 
-	for i := range rows {
-		switch rows[i].Type {
-		case "EXPENSE":
-			rows[i].Type = "Expense"
-		case "INCOME":
-			rows[i].Type = "Income"
+	foreignCurrencies := make(map[types.CurrencyType]bool)
+	for _, currency := range currencies {
+		j.Commodities = append(j.Commodities, string(currency))
+		if currency != setting.Currency {
+			foreignCurrencies[currency] = true
 		}
 	}
+	for _, name := range paymentMethodNames {
+		j.Accounts = append(j.Accounts, []string{journal.RootAssets, name})
+	}
+	for _, category := range categories {
+		j.Accounts = append(j.Accounts, journalCategoryAccount(category))
+	}
 
-	if format == dtotypes.ExportFormatJSON {
-		return buildJSON(rows)
+	if len(foreignCurrencies) > 0 && firstDate != nil && lastDate != nil {
+		j.Prices, err = s.exportJournalPrices(ctx, foreignCurrencies, setting.Currency, *firstDate, *lastDate)
+		if err != nil {
+			return fmt.Errorf("failed to export prices: %w", err)
+		}
 	}
 
-	headers := getHeaders(categoryCSVHeaders, lang)
-	csvRows := make([][]string, 0, len(rows))
-	for _, row := range rows {
-		csvRows = append(csvRows, []string{
-			row.Name,
-			row.Type,
-			row.Description,
+	j.EachTransaction = func(fn func(t journal.Transaction) error) error {
+		query := s.transactionRowsQuery(ctx, req.UserID, req.StartDate, req.EndDate).
+			Select(transactionRowsColumns).
+			Order("records.date ASC, records.id ASC")
+
+		return streamRows(query, func(row dtos.TransactionExportRow) error {
+			return fn(journalTransaction(row))
 		})
 	}
-	return buildCSV(headers, csvRows)
-}
 
-func (s *ExportService) exportPaymentMethods(ctx context.Context, userID uint, format dtotypes.ExportFormatType, lang types.LanguageType) ([]byte, error) {
-	var rows []dtos.PaymentMethodExportRow
+	switch req.Format {
+	case dtotypes.ExportFormatLedger:
+		return journal.WriteLedger(w, j)
+	case dtotypes.ExportFormatHledger:
+		return journal.WriteHledger(w, j)
+	case dtotypes.ExportFormatBeancount:
+		return journal.WriteBeancount(w, j)
+	}
+	return fmt.Errorf("unsupported journal format %s", req.Format)
+}
 
-	err := s.db.WithContext(ctx).
-		Table("payment_methods").
-		Select("name").
-		Where("user_id = ? AND deleted_at IS NULL", userID).
-		Find(&rows).Error
-	if err != nil {
-		return nil, err
+// journalCategoryAccount returns the income or expense account of the category
+// of a record.
+func journalCategoryAccount(row dtos.TransactionExportRow) []string {
+	if row.CategoryType == types.Income {
+		return []string{journal.RootIncome, row.CategoryName}
 	}
+	return []string{journal.RootExpenses, row.CategoryName}
+}
 
-	if len(rows) == 0 {
-		return nil, nil
+func journalTransaction(row dtos.TransactionExportRow) journal.Transaction {
+	payee := row.CategoryName
+	if row.Description != nil && *row.Description != "" {
+		payee = *row.Description
 	}
 
-	if format == dtotypes.ExportFormatJSON {
-		return buildJSON(rows)
+	asset := []string{journal.RootAssets, row.PaymentMethodName}
+	category := journalCategoryAccount(row)
+	commodity := string(row.Currency)
+
+	var postings []journal.Posting
+	if row.CategoryType == types.Income {
+		postings = []journal.Posting{
+			{Account: asset, Amount: row.Amount, Commodity: commodity},
+			{Account: category, Amount: -row.Amount, Commodity: commodity},
+		}
+	} else {
+		postings = []journal.Posting{
+			{Account: category, Amount: row.Amount, Commodity: commodity},
+			{Account: asset, Amount: -row.Amount, Commodity: commodity},
+		}
 	}
 
-	headers := getHeaders(paymentMethodCSVHeaders, lang)
-	csvRows := make([][]string, 0, len(rows))
-	for _, row := range rows {
-		csvRows = append(csvRows, []string{row.Name})
+	return journal.Transaction{
+		Date:     row.Date,
+		Payee:    payee,
+		ID:       strconv.FormatUint(uint64(row.ID), 10),
+		Postings: postings,
 	}
-	return buildCSV(headers, csvRows)
 }
 
-func (s *ExportService) exportPreferences(ctx context.Context, userID uint, format dtotypes.ExportFormatType, lang types.LanguageType) ([]byte, error) {
-	setting, err := s.settingService.GetByUserID(ctx, userID)
-	if err != nil {
+// exportJournalPrices returns one price per currency and day from the stored
+// exchange rates, using the last rate fetched on that day.
+func (s *ExportService) exportJournalPrices(ctx context.Context, currencies map[types.CurrencyType]bool, quote types.CurrencyType, firstDate, lastDate time.Time) ([]journal.Price, error) {
+	fromCurrencies := make([]types.CurrencyType, 0, len(currencies))
+	for currency := range currencies {
+		fromCurrencies = append(fromCurrencies, currency)
+	}
+
+	var rates []models.ExchangeRate
+	if err := s.db.WithContext(ctx).
+		Where("from_currency IN ? AND to_currency = ? AND fetched_at >= ? AND fetched_at < ?",
+			fromCurrencies, quote, firstDate.Truncate(24*time.Hour), lastDate.Truncate(24*time.Hour).AddDate(0, 0, 1)).
+		Order("from_currency ASC, fetched_at ASC").
+		Find(&rates).Error; err != nil {
 		return nil, err
 	}
 
-	if setting == nil {
+	prices := make([]journal.Price, 0, len(rates))
+	for _, rate := range rates {
+		price := journal.Price{
+			Date:      rate.FetchedAt,
+			Commodity: string(rate.FromCurrency),
+			Amount:    rate.Rate,
+			Quote:     string(quote),
+		}
+
+		last := len(prices) - 1
+		if last >= 0 && prices[last].Commodity == price.Commodity &&
+			prices[last].Date.Format("2006-01-02") == price.Date.Format("2006-01-02") {
+			prices[last] = price
+			continue
+		}
+		prices = append(prices, price)
+	}
+
+	sort.SliceStable(prices, func(a, b int) bool {
+		return prices[a].Date.Before(prices[b].Date)
+	})
+
+	return prices, nil
+}
+
+// exportBankFiles writes one OFX or QIF file per payment method and currency.
+// Records before the start date are only used for the OFX ledger balance, which
+// is summed by the database like everything else a file needs besides its
+// transactions. The transactions of each file are streamed from the cursor.
+func (s *ExportService) exportBankFiles(ctx context.Context, req requests.ExportRequest, zw *zip.Writer) error {
+	// Without a start date every record counts as part of the statement.
+	var startDate time.Time
+	if req.StartDate != nil {
+		startDate = *req.StartDate
+	}
+
+	var accounts []struct {
+		PaymentMethodID   uint
+		PaymentMethodName string
+		Currency          types.CurrencyType
+		Balance           float64
+		Transactions      int64
+	}
+	if err := s.transactionRowsQuery(ctx, req.UserID, nil, req.EndDate).
+		Select("records.payment_method_id, "+
+			"COALESCE(MAX(payment_methods.name), '') as payment_method_name, "+
+			"records.currency, "+
+			"COALESCE(SUM(CASE WHEN categories.type = 'EXPENSE' THEN -records.amount ELSE records.amount END), 0) as balance, "+
+			"COUNT(CASE WHEN records.date >= ? THEN 1 END) as transactions", startDate).
+		Group("records.payment_method_id, records.currency").
+		Order("records.payment_method_id ASC, records.currency ASC").
+		Scan(&accounts).Error; err != nil {
+		return fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	currencyCount := make(map[uint]int)
+	for _, account := range accounts {
+		currencyCount[account.PaymentMethodID]++
+	}
+
+	endDate := time.Now().UTC()
+	if req.EndDate != nil {
+		endDate = *req.EndDate
+	}
+
+	ext := strings.ToLower(string(req.Format))
+	paths := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		if account.Transactions == 0 {
+			continue
+		}
+
+		st := bankfile.Statement{
+			AccountID:   fmt.Sprintf("MONEXA-%d-%s", account.PaymentMethodID, account.Currency),
+			AccountName: account.PaymentMethodName,
+			Currency:    string(account.Currency),
+			StartDate:   startDate,
+			EndDate:     endDate,
+			Balance:     account.Balance,
+		}
+		paymentMethodID, currency := account.PaymentMethodID, account.Currency
+		if req.StartDate == nil {
+			firstDate, err := recordDate(s.transactionRowsQuery(ctx, req.UserID, nil, req.EndDate).
+				Where("records.payment_method_id = ? AND records.currency = ?", paymentMethodID, currency), "records.date ASC")
+			if err != nil {
+				return err
+			}
+			if firstDate != nil {
+				st.StartDate = *firstDate
+			}
+		}
+
+		st.EachTransaction = func(fn func(t bankfile.Transaction) error) error {
+			query := s.transactionRowsQuery(ctx, req.UserID, req.StartDate, req.EndDate).
+				Select(transactionRowsColumns).
+				Where("records.payment_method_id = ? AND records.currency = ?", paymentMethodID, currency).
+				Order("records.date ASC, records.id ASC")
+
+			return streamRows(query, func(row dtos.TransactionExportRow) error {
+				return fn(bankTransaction(row))
+			})
+		}
+
+		name := exportFileName(st.AccountName)
+		if currencyCount[account.PaymentMethodID] > 1 {
+			name += "-" + strings.ToLower(st.Currency)
+		}
+		path := fmt.Sprintf("%s/%s.%s", ext, name, ext)
+		if paths[path] {
+			path = fmt.Sprintf("%s/%s-%d.%s", ext, name, account.PaymentMethodID, ext)
+		}
+		paths[path] = true
+
+		f, err := zw.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create zip entry %s: %w", path, err)
+		}
+		if req.Format == dtotypes.ExportFormatOFX {
+			err = bankfile.WriteOFX(f, st, time.Now())
+		} else {
+			err = bankfile.WriteQIF(f, st)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// recordDate returns the date of the first record of the query in the given
+// order, or nil when it has none.
+func recordDate(query *gorm.DB, order string) (*time.Time, error) {
+	var dates []time.Time
+	if err := query.Order(order).Limit(1).Pluck("records.date", &dates).Error; err != nil {
+		return nil, fmt.Errorf("failed to get record date: %w", err)
+	}
+	if len(dates) == 0 {
 		return nil, nil
 	}
+	return &dates[0], nil
+}
 
-	row := dtos.PreferencesExportRow{
-		Language: string(setting.Language),
-		Currency: string(setting.Currency),
+func bankTransaction(row dtos.TransactionExportRow) bankfile.Transaction {
+	amount := row.Amount
+	if row.CategoryType == types.Expense {
+		amount = -amount
 	}
 
-	if format == dtotypes.ExportFormatJSON {
-		return buildJSON(row)
+	memo := ""
+	if row.Description != nil {
+		memo = *row.Description
+	}
+	payee := memo
+	if payee == "" {
+		payee = row.CategoryName
 	}
 
-	headers := getHeaders(preferencesCSVHeaders, lang)
-	csvRows := [][]string{{row.Language, row.Currency}}
-	return buildCSV(headers, csvRows)
+	return bankfile.Transaction{
+		ID:       recordFITID(row.ID),
+		Date:     row.Date,
+		Amount:   amount,
+		Payee:    payee,
+		Memo:     memo,
+		Category: row.CategoryName,
+	}
 }
 
-func (s *ExportService) exportConsent(ctx context.Context, userID uint, format dtotypes.ExportFormatType, lang types.LanguageType) ([]byte, error) {
-	titleColumn := "ld.title"
-	if lang == types.MacedonianLanguage {
-		titleColumn = "ld.title_mk"
+// transactionRowsColumns selects a dtos.TransactionExportRow.
+const transactionRowsColumns = "records.id, records.payment_method_id, payment_methods.name as payment_method_name, categories.name as category_name, categories.type as category_type, records.amount, records.currency, records.date, records.description"
+
+// transactionRowsQuery selects the user's records joined with their category
+// and payment method. Both dates are optional and inclusive. Every call starts
+// a new query, so callers can add their own columns and clauses.
+func (s *ExportService) transactionRowsQuery(ctx context.Context, userID uint, startDate *time.Time, endDate *time.Time) *gorm.DB {
+	query := s.db.WithContext(ctx).
+		Table("records").
+		Joins("LEFT JOIN categories ON categories.id = records.category_id").
+		Joins("LEFT JOIN payment_methods ON payment_methods.id = records.payment_method_id").
+		Where("records.user_id = ? AND records.deleted_at IS NULL", userID)
+
+	if startDate != nil {
+		query = query.Where("records.date >= ?", *startDate)
+	}
+	if endDate != nil {
+		query = query.Where("records.date <= ?", *endDate)
 	}
 
-	var rows []dtos.ConsentExportRow
+	return query
+}
 
-	err := s.db.WithContext(ctx).
-		Table("user_legal_acceptances ula").
-		Select(fmt.Sprintf("%s as document, ld.version, ula.accepted_at, COALESCE(ula.ip_address, '') as ip_address, COALESCE(ula.user_agent, '') as user_agent", titleColumn)).
-		Joins("JOIN legal_documents ld ON ld.id = ula.legal_document_id").
-		Where("ula.user_id = ? AND ula.deleted_at IS NULL", userID).
-		Order("ula.accepted_at DESC").
-		Find(&rows).Error
-	if err != nil {
-		return nil, err
+func (s *ExportService) exportCategories(ctx context.Context, userID uint, lang types.LanguageType) *exportTable {
+	return &exportTable{
+		headers: getHeaders(categoryCSVHeaders, lang),
+		each: func(fn func(cells []any, data any) error) error {
+			query := s.db.WithContext(ctx).
+				Table("categories").
+				Select("name, type, COALESCE(description, '') as description").
+				Where("user_id = ? AND deleted_at IS NULL", userID)
+
+			return streamRows(query, func(row dtos.CategoryExportRow) error {
+				switch row.Type {
+				case "EXPENSE":
+					row.Type = "Expense"
+				case "INCOME":
+					row.Type = "Income"
+				}
+				return fn([]any{row.Name, row.Type, row.Description}, row)
+			})
+		},
 	}
+}
 
-	if len(rows) == 0 {
-		return nil, nil
+func (s *ExportService) exportPaymentMethods(ctx context.Context, userID uint, lang types.LanguageType) *exportTable {
+	return &exportTable{
+		headers: getHeaders(paymentMethodCSVHeaders, lang),
+		each: func(fn func(cells []any, data any) error) error {
+			query := s.db.WithContext(ctx).
+				Table("payment_methods").
+				Select("name").
+				Where("user_id = ? AND deleted_at IS NULL", userID)
+
+			return streamRows(query, func(row dtos.PaymentMethodExportRow) error {
+				return fn([]any{row.Name}, row)
+			})
+		},
+	}
+}
+
+func (s *ExportService) exportPreferences(ctx context.Context, userID uint, lang types.LanguageType) *exportTable {
+	return &exportTable{
+		headers: getHeaders(preferencesCSVHeaders, lang),
+		single:  true,
+		each: func(fn func(cells []any, data any) error) error {
+			setting, err := s.settingService.GetByUserID(ctx, userID)
+			if err != nil {
+				return err
+			}
+
+			if setting == nil {
+				return nil
+			}
+
+			row := dtos.PreferencesExportRow{
+				Language: string(setting.Language),
+				Currency: string(setting.Currency),
+			}
+
+			return fn([]any{row.Language, row.Currency}, row)
+		},
 	}
+}
 
-	if format == dtotypes.ExportFormatJSON {
-		return buildJSON(rows)
+func (s *ExportService) exportConsent(ctx context.Context, userID uint, lang types.LanguageType) *exportTable {
+	titleColumn := "ld.title"
+	if lang == types.MacedonianLanguage {
+		titleColumn = "ld.title_mk"
 	}
 
-	headers := getHeaders(consentCSVHeaders, lang)
-	csvRows := make([][]string, 0, len(rows))
-	for _, row := range rows {
-		csvRows = append(csvRows, []string{
-			row.Document,
-			fmt.Sprintf("%d", row.Version),
-			row.AcceptedAt.Format("2006-01-02 15:04:05"),
-			row.IPAddress,
-			row.UserAgent,
-		})
+	return &exportTable{
+		headers: getHeaders(consentCSVHeaders, lang),
+		each: func(fn func(cells []any, data any) error) error {
+			query := s.db.WithContext(ctx).
+				Table("user_legal_acceptances ula").
+				Select(fmt.Sprintf("%s as document, ld.version, ula.accepted_at, COALESCE(ula.ip_address, '') as ip_address, COALESCE(ula.user_agent, '') as user_agent", titleColumn)).
+				Joins("JOIN legal_documents ld ON ld.id = ula.legal_document_id").
+				Where("ula.user_id = ? AND ula.deleted_at IS NULL", userID).
+				Order("ula.accepted_at DESC")
+
+			return streamRows(query, func(row dtos.ConsentExportRow) error {
+				return fn([]any{
+					row.Document,
+					row.Version,
+					row.AcceptedAt,
+					row.IPAddress,
+					row.UserAgent,
+				}, row)
+			})
+		},
+	}
+}
+
+// csvCells renders typed cells with the same text formats the CSV export has
+// always used.
+func csvCells(row []any) []string {
+	cells := make([]string, 0, len(row))
+	for _, value := range row {
+		switch v := value.(type) {
+		case string:
+			cells = append(cells, v)
+		case float64:
+			cells = append(cells, fmt.Sprintf("%.2f", v))
+		case int:
+			cells = append(cells, fmt.Sprintf("%d", v))
+		case exportDate:
+			cells = append(cells, time.Time(v).Format("2006-01-02"))
+		case time.Time:
+			cells = append(cells, v.Format("2006-01-02 15:04:05"))
+		default:
+			cells = append(cells, fmt.Sprint(v))
+		}
 	}
-	return buildCSV(headers, csvRows)
+	return cells
 }
 
 func getHeaders(headerMap map[types.LanguageType][]string, lang types.LanguageType) []string {
@@ -373,53 +983,425 @@ func getHeaders(headerMap map[types.LanguageType][]string, lang types.LanguageTy
 	return headers
 }
 
-func buildCSV(headers []string, rows [][]string) ([]byte, error) {
-	var buf bytes.Buffer
-
-	// UTF-8 BOM for Excel compatibility
-	buf.Write([]byte{0xEF, 0xBB, 0xBF})
+// writeCSVEntry streams a table into a CSV file in the archive. The entry is only
+// created once the first row arrives, so empty tables leave no file behind.
+func writeCSVEntry(zw *zip.Writer, path string, table *exportTable) error {
+	var writer *csv.Writer
+
+	err := table.each(func(cells []any, _ any) error {
+		if writer == nil {
+			f, err := zw.Create(path)
+			if err != nil {
+				return fmt.Errorf("failed to create zip entry %s: %w", path, err)
+			}
+
+			// UTF-8 BOM for Excel compatibility
+			if _, err := f.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
+				return fmt.Errorf("failed to write zip entry %s: %w", path, err)
+			}
+
+			writer = csv.NewWriter(f)
+			if err := writer.Write(table.headers); err != nil {
+				return fmt.Errorf("failed to write CSV headers: %w", err)
+			}
+		}
 
-	writer := csv.NewWriter(&buf)
+		if err := writer.Write(csvCells(cells)); err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+		return nil
+	})
+	if err != nil || writer == nil {
+		return err
+	}
 
-	if err := writer.Write(headers); err != nil {
-		return nil, fmt.Errorf("failed to write CSV headers: %w", err)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV writer: %w", err)
 	}
+	return nil
+}
 
-	for _, row := range rows {
-		if err := writer.Write(row); err != nil {
-			return nil, fmt.Errorf("failed to write CSV row: %w", err)
+// writeJSONEntry streams a table into a JSON file in the archive. The output is
+// the same as indenting the whole slice at once, one element at a time.
+func writeJSONEntry(zw *zip.Writer, path string, table *exportTable) error {
+	var f io.Writer
+	count := 0
+
+	err := table.each(func(_ []any, data any) error {
+		var chunk bytes.Buffer
+		if f == nil {
+			entry, err := zw.Create(path)
+			if err != nil {
+				return fmt.Errorf("failed to create zip entry %s: %w", path, err)
+			}
+			f = entry
+			if !table.single {
+				chunk.WriteString("[\n")
+			}
 		}
+
+		prefix := ""
+		if !table.single {
+			if count > 0 {
+				chunk.WriteString(",\n")
+			}
+			prefix = "  "
+			chunk.WriteString(prefix)
+		}
+
+		element, err := json.MarshalIndent(data, prefix, "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode JSON row: %w", err)
+		}
+		chunk.Write(element)
+		count++
+
+		if _, err := f.Write(chunk.Bytes()); err != nil {
+			return fmt.Errorf("failed to write zip entry %s: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil || f == nil || table.single {
+		return err
 	}
 
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		return nil, fmt.Errorf("failed to flush CSV writer: %w", err)
+	if _, err := io.WriteString(f, "\n]"); err != nil {
+		return fmt.Errorf("failed to write zip entry %s: %w", path, err)
 	}
+	return nil
+}
 
-	return buf.Bytes(), nil
+// recordFITID returns the transaction ID used by OFX and QIF exports. It only
+// depends on the record, so re-importing an export does not create duplicates.
+func recordFITID(recordID uint) string {
+	return fmt.Sprintf("MONEXA-%d", recordID)
+}
+
+// exportFileName turns a user provided name into a safe file name.
+func exportFileName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	if fileName := strings.TrimRight(b.String(), "-"); fileName != "" {
+		return fileName
+	}
+	return "account"
 }
 
 func buildJSON(data any) ([]byte, error) {
 	return json.MarshalIndent(data, "", "  ")
 }
 
-func buildZIP(files map[string][]byte) ([]byte, error) {
-	var buf bytes.Buffer
-	w := zip.NewWriter(&buf)
+// closeZIP writes the central directory that completes the archive.
+func closeZIP(zw *zip.Writer) error {
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to close zip writer: %w", err)
+	}
+	return nil
+}
+
+// writeXLSX writes every section into its own sheet of a single workbook, with a
+// summary sheet in front. Numbers and dates are written as native cell types.
+// Sections are streamed first so the summary can report how many rows each has.
+func writeXLSX(w io.Writer, sections []exportSection, recordTotals []dtos.RecordTotalExportRow, startDate *time.Time, endDate *time.Time, lang types.LanguageType) error {
+	text, ok := exportSummaryTexts[lang]
+	if !ok {
+		text = exportSummaryTexts[types.EnglishLanguage]
+	}
+	sheetNames, ok := exportSheetNames[lang]
+	if !ok {
+		sheetNames = exportSheetNames[types.EnglishLanguage]
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return err
+	}
+
+	if err := f.SetSheetName("Sheet1", text.Sheet); err != nil {
+		return fmt.Errorf("failed to create summary sheet: %w", err)
+	}
+
+	type sheetCount struct {
+		name string
+		rows int
+	}
+	var counts []sheetCount
+	for _, section := range sections {
+		name := sheetNames[section.category]
+		rows, err := writeXLSXSheet(f, name, section.table, styles)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", section.category, err)
+		}
+		if rows == 0 {
+			if err := f.DeleteSheet(name); err != nil {
+				return fmt.Errorf("failed to remove empty sheet %s: %w", name, err)
+			}
+			continue
+		}
+		counts = append(counts, sheetCount{name, rows})
+	}
+
+	period := text.AllTime
+	if startDate != nil || endDate != nil {
+		from, to := "…", "…"
+		if startDate != nil {
+			from = startDate.Format("2006-01-02")
+		}
+		if endDate != nil {
+			to = endDate.Format("2006-01-02")
+		}
+		period = from + " – " + to
+	}
+
+	summary := [][]any{
+		{text.Title},
+		{text.GeneratedAt, time.Now().UTC()},
+		{text.Period, period},
+		{},
+		{text.Contents, text.Rows},
+	}
+	contentsHeaderRow := len(summary)
+	for _, count := range counts {
+		summary = append(summary, []any{count.name, count.rows})
+	}
+
+	totalsHeaderRow := 0
+	if len(recordTotals) > 0 {
+		summary = append(summary, []any{}, []any{text.Totals}, []any{text.Currency, text.Income, text.Expense, text.Net})
+		totalsHeaderRow = len(summary)
+		for _, total := range recordTotals {
+			summary = append(summary, []any{string(total.Currency), total.Income, total.Expense, total.Income - total.Expense})
+		}
+	}
+
+	if err := writeXLSXRows(f, text.Sheet, 1, summary, styles); err != nil {
+		return err
+	}
+	type styledRange struct {
+		from, to string
+		style    int
+	}
+	summaryStyles := []styledRange{
+		{"A1", "A1", styles.title},
+		{fmt.Sprintf("A%d", contentsHeaderRow), fmt.Sprintf("B%d", contentsHeaderRow), styles.header},
+	}
+	if totalsHeaderRow > 0 {
+		summaryStyles = append(summaryStyles,
+			styledRange{fmt.Sprintf("A%d", totalsHeaderRow-1), fmt.Sprintf("A%d", totalsHeaderRow-1), styles.title},
+			styledRange{fmt.Sprintf("A%d", totalsHeaderRow), fmt.Sprintf("D%d", totalsHeaderRow), styles.header},
+		)
+	}
+	for _, r := range summaryStyles {
+		if err := f.SetCellStyle(text.Sheet, r.from, r.to, r.style); err != nil {
+			return fmt.Errorf("failed to style summary sheet: %w", err)
+		}
+	}
+	if err := f.SetColWidth(text.Sheet, "A", "A", 28); err != nil {
+		return fmt.Errorf("failed to size summary sheet: %w", err)
+	}
+	if err := f.SetColWidth(text.Sheet, "B", "D", 20); err != nil {
+		return fmt.Errorf("failed to size summary sheet: %w", err)
+	}
+
+	if err := f.Write(w); err != nil {
+		return fmt.Errorf("failed to write workbook: %w", err)
+	}
+	return nil
+}
+
+// writeXLSXSheet streams a table into a new sheet and returns the number of data
+// rows written. Column widths must be set before the first row, so they are
+// estimated from the first xlsxWidthSampleRows rows, which are held back until
+// then.
+func writeXLSXSheet(f *excelize.File, name string, table *exportTable, styles *xlsxStyles) (int, error) {
+	if _, err := f.NewSheet(name); err != nil {
+		return 0, fmt.Errorf("failed to create sheet %s: %w", name, err)
+	}
+	sw, err := f.NewStreamWriter(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open sheet %s: %w", name, err)
+	}
+
+	count := 0
+	writeRow := func(cells []any) error {
+		count++
+		cell, err := excelize.CoordinatesToCellName(1, count+1)
+		if err != nil {
+			return fmt.Errorf("failed to resolve cell: %w", err)
+		}
+		if err := sw.SetRow(cell, xlsxCells(cells, styles)); err != nil {
+			return fmt.Errorf("failed to write row %s!%s: %w", name, cell, err)
+		}
+		return nil
+	}
+
+	var sample [][]any
+	started := false
+	start := func() error {
+		started = true
+		for i, header := range table.headers {
+			if err := sw.SetColWidth(i+1, i+1, xlsxColumnWidth(header, sample, i)); err != nil {
+				return fmt.Errorf("failed to size sheet %s: %w", name, err)
+			}
+		}
+		if err := sw.SetPanes(&excelize.Panes{
+			Freeze:      true,
+			YSplit:      1,
+			TopLeftCell: "A2",
+			ActivePane:  "bottomLeft",
+		}); err != nil {
+			return fmt.Errorf("failed to freeze header of sheet %s: %w", name, err)
+		}
+
+		headers := make([]any, len(table.headers))
+		for i, header := range table.headers {
+			headers[i] = excelize.Cell{StyleID: styles.header, Value: header}
+		}
+		if err := sw.SetRow("A1", headers); err != nil {
+			return fmt.Errorf("failed to write header of sheet %s: %w", name, err)
+		}
+
+		for _, row := range sample {
+			if err := writeRow(row); err != nil {
+				return err
+			}
+		}
+		sample = nil
+		return nil
+	}
+
+	err = table.each(func(cells []any, _ any) error {
+		if started {
+			return writeRow(cells)
+		}
+		sample = append(sample, cells)
+		if len(sample) == xlsxWidthSampleRows {
+			return start()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !started {
+		if err := start(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := sw.Flush(); err != nil {
+		return 0, fmt.Errorf("failed to flush sheet %s: %w", name, err)
+	}
+	return count, nil
+}
+
+type xlsxStyles struct {
+	title    int
+	header   int
+	amount   int
+	date     int
+	dateTime int
+}
+
+func newXLSXStyles(f *excelize.File) (*xlsxStyles, error) {
+	amountFormat := "#,##0.00"
+	dateFormat := "yyyy-mm-dd"
+	dateTimeFormat := "yyyy-mm-dd hh:mm:ss"
+
+	definitions := []*excelize.Style{
+		{Font: &excelize.Font{Bold: true, Size: 14}},
+		{
+			Font: &excelize.Font{Bold: true},
+			Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"ECECFF"}},
+		},
+		{CustomNumFmt: &amountFormat},
+		{CustomNumFmt: &dateFormat},
+		{CustomNumFmt: &dateTimeFormat},
+	}
 
-	for path, content := range files {
-		f, err := w.Create(path)
+	ids := make([]int, len(definitions))
+	for i, definition := range definitions {
+		id, err := f.NewStyle(definition)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create zip entry %s: %w", path, err)
+			return nil, fmt.Errorf("failed to create workbook style: %w", err)
 		}
-		if _, err := f.Write(content); err != nil {
-			return nil, fmt.Errorf("failed to write zip entry %s: %w", path, err)
+		ids[i] = id
+	}
+
+	return &xlsxStyles{title: ids[0], header: ids[1], amount: ids[2], date: ids[3], dateTime: ids[4]}, nil
+}
+
+// writeXLSXRows writes rows starting at firstRow and styles numeric and date
+// cells according to their Go type.
+func writeXLSXRows(f *excelize.File, sheet string, firstRow int, rows [][]any, styles *xlsxStyles) error {
+	for r, row := range rows {
+		for c, value := range row {
+			cell, err := excelize.CoordinatesToCellName(c+1, firstRow+r)
+			if err != nil {
+				return fmt.Errorf("failed to resolve cell: %w", err)
+			}
+
+			value, style := xlsxCellStyle(value, styles)
+			if err := f.SetCellValue(sheet, cell, value); err != nil {
+				return fmt.Errorf("failed to write cell %s!%s: %w", sheet, cell, err)
+			}
+			if style != 0 {
+				if err := f.SetCellStyle(sheet, cell, cell, style); err != nil {
+					return fmt.Errorf("failed to style cell %s!%s: %w", sheet, cell, err)
+				}
+			}
 		}
 	}
+	return nil
+}
+
+// xlsxCells wraps typed values in cells carrying their style for a stream writer.
+func xlsxCells(row []any, styles *xlsxStyles) []any {
+	cells := make([]any, len(row))
+	for i, value := range row {
+		value, style := xlsxCellStyle(value, styles)
+		cells[i] = excelize.Cell{StyleID: style, Value: value}
+	}
+	return cells
+}
 
-	if err := w.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close zip writer: %w", err)
+// xlsxCellStyle picks the number format of a value from its Go type and unwraps
+// export specific types into values excelize understands.
+func xlsxCellStyle(value any, styles *xlsxStyles) (any, int) {
+	switch v := value.(type) {
+	case float64:
+		return v, styles.amount
+	case exportDate:
+		return time.Time(v), styles.date
+	case time.Time:
+		return v, styles.dateTime
 	}
+	return value, 0
+}
 
-	return buf.Bytes(), nil
+// xlsxColumnWidth approximates a readable column width from the header and the
+// longest value, capped so long descriptions do not produce huge columns.
+func xlsxColumnWidth(header string, rows [][]any, col int) float64 {
+	longest := len([]rune(header))
+	for _, row := range rows {
+		length := 12
+		if v, ok := row[col].(string); ok {
+			length = len([]rune(v))
+		}
+		longest = max(longest, length)
+	}
+	return float64(min(longest+2, 60))
 }
@@ -8,8 +8,15 @@ import (
 )
 
 const (
-	PasswordResetTemplate   = "templates/email/password_reset.html"
-	AccountDeletionTemplate = "templates/email/account_deletion.html"
+	PasswordResetTemplate      = "templates/email/password_reset.html"
+	AccountDeletionTemplate    = "templates/email/account_deletion.html"
+	ExportReadyTemplate        = "templates/email/export_ready.html"
+	ScheduledExportTemplate    = "templates/email/scheduled_export.html"
+	DigestTemplate             = "templates/email/digest.html"
+	AccountLockedTemplate      = "templates/email/account_locked.html"
+	EmailVerificationTemplate  = "templates/email/email_verification.html"
+	EmailChangeConfirmTemplate = "templates/email/email_change_confirm.html"
+	EmailChangeNoticeTemplate  = "templates/email/email_change_notice.html"
 )
 
 var emailSubjects = map[string]map[types.LanguageType]string{
@@ -21,22 +28,54 @@ var emailSubjects = map[string]map[types.LanguageType]string{
 		types.EnglishLanguage:    "Account Deletion Notice",
 		types.MacedonianLanguage: "Известување за бришење на сметка",
 	},
+	ExportReadyTemplate: {
+		types.EnglishLanguage:    "Your data export is ready",
+		types.MacedonianLanguage: "Вашиот извоз на податоци е подготвен",
+	},
+	ScheduledExportTemplate: {
+		types.EnglishLanguage:    "Your monthly Monexa export",
+		types.MacedonianLanguage: "Вашиот месечен извоз од Монекса",
+	},
+	DigestTemplate: {
+		types.EnglishLanguage:    "Your Monexa digest",
+		types.MacedonianLanguage: "Вашиот преглед од Монекса",
+	},
+	AccountLockedTemplate: {
+		types.EnglishLanguage:    "Your account was temporarily locked",
+		types.MacedonianLanguage: "Вашата сметка е привремено заклучена",
+	},
+	EmailVerificationTemplate: {
+		types.EnglishLanguage:    "Verify your email address",
+		types.MacedonianLanguage: "Потврдете ја вашата е-пошта",
+	},
+	EmailChangeConfirmTemplate: {
+		types.EnglishLanguage:    "Confirm your new email address",
+		types.MacedonianLanguage: "Потврдете ја новата е-пошта",
+	},
+	EmailChangeNoticeTemplate: {
+		types.EnglishLanguage:    "Your email address is being changed",
+		types.MacedonianLanguage: "Вашата е-пошта се менува",
+	},
 }
 
 type MailService struct {
-	mailClient *clients.MailClient
+	mailer clients.Mailer
 }
 
-func NewMailService(mailClient *clients.MailClient) *MailService {
-	return &MailService{mailClient: mailClient}
+func NewMailService(mailer clients.Mailer) *MailService {
+	return &MailService{mailer: mailer}
 }
 
 func (s *MailService) SendText(to, subject, body string) error {
-	return s.mailClient.SendText(to, subject, body)
+	return s.mailer.Send(clients.MailMessage{To: []string{to}, Subject: subject, Text: body})
 }
 
 func (s *MailService) SendHTML(to, subject, htmlBody string) error {
-	return s.mailClient.SendHTML(to, subject, htmlBody)
+	return s.mailer.Send(clients.MailMessage{To: []string{to}, Subject: subject, HTML: htmlBody})
+}
+
+func (s *MailService) SendHTMLWithAttachments(to, subject, htmlBody string, attachments []clients.MailAttachment) error {
+	return s.mailer.Send(clients.MailMessage{To: []string{to}, Subject: subject, HTML: htmlBody, Attachments: attachments})
 }
 
 func (s *MailService) GetEmailTemplatePath(template string, language types.LanguageType) string {
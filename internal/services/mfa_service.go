@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/responses"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TOTPIssuer         = "Monexa"
+	RecoveryCodeCount  = 10
+	RecoveryCodeBytes  = 10
+	recoveryCodeLength = 16
+)
+
+var (
+	ErrInvalidMFACode       = errors.New("invalid authentication code")
+	ErrMFANotEnabled        = errors.New("two-factor authentication is not enabled")
+	ErrMFAAlreadyEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrMFASetupNotStarted   = errors.New("two-factor authentication setup has not been started")
+	ErrMFAReauthentication  = errors.New("password or authentication code is incorrect")
+	ErrMFAChallengeNotFound = errors.New("mfa challenge not found or already used")
+)
+
+// MFAService manages TOTP two-factor authentication and recovery codes.
+// Secrets are encrypted with MFA_ENCRYPTION_KEY, or JWT_SECRET when unset.
+type MFAService struct {
+	db            *gorm.DB
+	encryptionKey string
+}
+
+func NewMFAService(db *gorm.DB) *MFAService {
+	key := os.Getenv("MFA_ENCRYPTION_KEY")
+	if key == "" {
+		key = os.Getenv("JWT_SECRET")
+	}
+
+	return &MFAService{
+		db:            db,
+		encryptionKey: key,
+	}
+}
+
+func (s *MFAService) IsEnabled(ctx context.Context, userID uint) (bool, error) {
+	totp, err := s.getTOTP(ctx, s.db, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.EnabledAt != nil, nil
+}
+
+func (s *MFAService) GetStatus(ctx context.Context, userID uint) (*responses.MFAStatusResponse, error) {
+	status := &responses.MFAStatusResponse{}
+
+	totp, err := s.getTOTP(ctx, s.db, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if totp != nil {
+		status.Enabled = totp.EnabledAt != nil
+		status.EnabledAt = totp.EnabledAt
+		status.PendingSetup = totp.PendingSecret != nil
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&status.RecoveryCodesRemaining).Error; err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return status, nil
+}
+
+// BeginSetup generates a new secret that becomes active once ConfirmSetup sees
+// a valid code for it. Replacing an active secret needs Reauthenticate first.
+func (s *MFAService) BeginSetup(ctx context.Context, user models.User, reauthenticated bool) (*responses.TOTPSetupResponse, error) {
+	totp, err := s.getTOTP(ctx, s.db, user.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if totp != nil && totp.EnabledAt != nil && !reauthenticated {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptString(s.encryptionKey, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	if totp == nil {
+		totp = &models.UserTOTP{UserID: user.ID, PendingSecret: &encrypted}
+		if err := s.db.WithContext(ctx).Create(totp).Error; err != nil {
+			return nil, fmt.Errorf("failed to store totp secret: %w", err)
+		}
+	} else if err := s.db.WithContext(ctx).Model(totp).Update("pending_secret", encrypted).Error; err != nil {
+		return nil, fmt.Errorf("failed to store totp secret: %w", err)
+	}
+
+	return &responses.TOTPSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmSetup activates the pending secret and returns a fresh set of
+// recovery codes, replacing any earlier ones.
+func (s *MFAService) ConfirmSetup(ctx context.Context, userID uint, code string) ([]string, error) {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	totp, err := s.getTOTP(ctx, tx, userID)
+	if err != nil || totp.PendingSecret == nil {
+		tx.Rollback()
+		return nil, ErrMFASetupNotStarted
+	}
+
+	secret, err := utils.DecryptString(s.encryptionKey, *totp.PendingSecret)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		tx.Rollback()
+		return nil, ErrInvalidMFACode
+	}
+
+	now := time.Now()
+	if err := tx.Model(totp).Updates(map[string]any{
+		"secret":         *totp.PendingSecret,
+		"pending_secret": nil,
+		"last_used_step": step,
+		"enabled_at":     now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to enable totp: %w", err)
+	}
+
+	codes, err := s.replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit totp setup: %w", err)
+	}
+
+	return codes, nil
+}
+
+// SaveChallenge records a challenge token issued to the user, so that
+// VerifyChallenge accepts it once. Expired challenges are dropped on the way.
+func (s *MFAService) SaveChallenge(ctx context.Context, userID uint, tokenID string, expiresAt time.Time) error {
+	if err := s.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.MFAChallenge{}).Error; err != nil {
+		return fmt.Errorf("failed to delete expired mfa challenges: %w", err)
+	}
+
+	challenge := models.MFAChallenge{ID: tokenID, UserID: userID, ExpiresAt: expiresAt}
+	if err := s.db.WithContext(ctx).Create(&challenge).Error; err != nil {
+		return fmt.Errorf("failed to store mfa challenge: %w", err)
+	}
+	return nil
+}
+
+// VerifyChallenge answers a challenge saved by SaveChallenge with a code, as
+// Verify does. The challenge is used up even when the code is wrong, so every
+// code needs a fresh challenge and with it the password.
+func (s *MFAService) VerifyChallenge(ctx context.Context, userID uint, tokenID string, code string) error {
+	result := s.db.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND expires_at > ?", tokenID, userID, time.Now()).
+		Delete(&models.MFAChallenge{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to use mfa challenge: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAChallengeNotFound
+	}
+
+	return s.Verify(ctx, userID, code)
+}
+
+// Verify accepts an authenticator code or an unused recovery code. Each
+// authenticator code and each recovery code works only once.
+func (s *MFAService) Verify(ctx context.Context, userID uint, code string) error {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Locking the row serializes concurrent attempts with the same code.
+	var totp models.UserTOTP
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).
+		First(&totp).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMFANotEnabled
+		}
+		return fmt.Errorf("failed to fetch totp: %w", err)
+	}
+
+	if err := s.verifyWithTx(tx, &totp, code); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit mfa verification: %w", err)
+	}
+	return nil
+}
+
+func (s *MFAService) verifyWithTx(tx *gorm.DB, totp *models.UserTOTP, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(strings.ReplaceAll(code, " ", "")) == utils.TOTPDigits {
+		secret, err := utils.DecryptString(s.encryptionKey, *totp.Secret)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt totp secret: %w", err)
+		}
+
+		step, ok := utils.ValidateTOTP(secret, code, time.Now())
+		if !ok || step <= totp.LastUsedStep {
+			return ErrInvalidMFACode
+		}
+		if err := tx.Model(totp).Update("last_used_step", step).Error; err != nil {
+			return fmt.Errorf("failed to record totp use: %w", err)
+		}
+		return nil
+	}
+
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", totp.UserID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to use recovery code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// Reauthenticate checks the password and the second factor of a signed in
+// user before 2FA is disabled or reset.
+func (s *MFAService) Reauthenticate(ctx context.Context, user models.User, password, code string) error {
+	if err := utils.CheckPassword(password, user.Password); err != nil {
+		return ErrMFAReauthentication
+	}
+	if err := s.Verify(ctx, user.ID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			return ErrMFAReauthentication
+		}
+		return err
+	}
+	return nil
+}
+
+// Disable removes the secret and all recovery codes of the user.
+func (s *MFAService) Disable(ctx context.Context, userID uint) error {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserTOTP{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit disabling totp: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates all recovery codes and returns new ones.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	codes, err := s.replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+func (s *MFAService) getTOTP(ctx context.Context, db *gorm.DB, userID uint) (*models.UserTOTP, error) {
+	var totp models.UserTOTP
+	if err := db.WithContext(ctx).Where("user_id = ?", userID).First(&totp).Error; err != nil {
+		return nil, err
+	}
+	return &totp, nil
+}
+
+// generateRecoveryCode returns a code such as "abcd-efgh-ijkl-mnop".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, RecoveryCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:recoveryCodeLength]
+
+	parts := make([]string, 0, recoveryCodeLength/4)
+	for i := 0; i < len(raw); i += 4 {
+		parts = append(parts, raw[i:i+4])
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so codes can be typed the way
+// they are read.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	h := sha256.Sum256([]byte(normalized))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPMatchesRFC6238TestVectors(t *testing.T) {
+	// The RFC lists 8 digit codes, authenticator apps show their last 6.
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		at := time.Unix(v.unix, 0)
+		code, err := utils.TOTPCode(rfc6238Secret, utils.TOTPStep(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("TOTPCode() at %d = %s, want %s", v.unix, code, v.code)
+		}
+		if step, ok := utils.ValidateTOTP(rfc6238Secret, v.code, at); !ok || step != utils.TOTPStep(at) {
+			t.Errorf("ValidateTOTP() at %d = %d, %v, want step %d", v.unix, step, ok, utils.TOTPStep(at))
+		}
+	}
+
+	// One step of clock drift is accepted either way, two are not.
+	at := time.Unix(1111111111, 0)
+	for drift, want := range map[time.Duration]bool{-utils.TOTPPeriod * time.Second: true, utils.TOTPPeriod * time.Second: true, 2 * utils.TOTPPeriod * time.Second: false} {
+		if _, ok := utils.ValidateTOTP(rfc6238Secret, "050471", at.Add(drift)); ok != want {
+			t.Errorf("ValidateTOTP() with %v drift = %v, want %v", drift, ok, want)
+		}
+	}
+}
+
+// newTestMFAService returns a service and a user with 2FA enabled, the
+// authenticator code of the given number of steps after the one used for the
+// setup, and the recovery codes.
+func newTestMFAService(t *testing.T) (*MFAService, models.User, func(steps int64) string, []string) {
+	t.Helper()
+	t.Setenv("MFA_ENCRYPTION_KEY", "test-mfa-encryption-key-of-32-chars")
+
+	ctx := context.Background()
+	db := newTestDB(t, &models.User{}, &models.UserTOTP{}, &models.RecoveryCode{}, &models.MFAChallenge{})
+	s := NewMFAService(db)
+
+	password, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: "ana@example.com", Password: password, Name: "Ana", PPID: "ppid-ana"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	setup, err := s.BeginSetup(ctx, user, false)
+	if err != nil {
+		t.Fatalf("BeginSetup() error = %v", err)
+	}
+	setupStep := utils.TOTPStep(time.Now())
+	code := func(steps int64) string {
+		code, err := utils.TOTPCode(setup.Secret, setupStep+steps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	codes, err := s.ConfirmSetup(ctx, user.ID, code(0))
+	if err != nil {
+		t.Fatalf("ConfirmSetup() error = %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("ConfirmSetup() returned %d recovery codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	return s, user, code, codes
+}
+
+func TestVerifyRejectsReplayedCodes(t *testing.T) {
+	ctx := context.Background()
+	s, user, code, _ := newTestMFAService(t)
+
+	if err := s.Verify(ctx, user.ID, code(0)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Verify() with the code of the setup = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	next := code(1)
+	if err := s.Verify(ctx, user.ID, next); err != nil {
+		t.Fatalf("Verify() with the next code error = %v", err)
+	}
+	if err := s.Verify(ctx, user.ID, next); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Verify() with a used code = %v, want %v", err, ErrInvalidMFACode)
+	}
+	// An earlier step is refused as well, even though it is within the skew.
+	if err := s.Verify(ctx, user.ID, code(-1)); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Verify() with an earlier code = %v, want %v", err, ErrInvalidMFACode)
+	}
+}
+
+func TestVerifyUsesUpRecoveryCodes(t *testing.T) {
+	ctx := context.Background()
+	s, user, _, codes := newTestMFAService(t)
+
+	// Codes can be typed in upper case and without the dashes.
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+	if err := s.Verify(ctx, user.ID, typed); err != nil {
+		t.Fatalf("Verify() with a recovery code error = %v", err)
+	}
+	if err := s.Verify(ctx, user.ID, codes[0]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Verify() with a used recovery code = %v, want %v", err, ErrInvalidMFACode)
+	}
+
+	status, err := s.GetStatus(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RecoveryCodesRemaining != RecoveryCodeCount-1 {
+		t.Errorf("%d recovery codes remaining, want %d", status.RecoveryCodesRemaining, RecoveryCodeCount-1)
+	}
+
+	// Regenerating the codes invalidates the old ones.
+	fresh, err := s.RegenerateRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify(ctx, user.ID, codes[1]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("Verify() with a replaced recovery code = %v, want %v", err, ErrInvalidMFACode)
+	}
+	if err := s.Verify(ctx, user.ID, fresh[0]); err != nil {
+		t.Errorf("Verify() with a regenerated recovery code error = %v", err)
+	}
+}
+
+func TestReauthenticateNeedsPasswordAndCode(t *testing.T) {
+	ctx := context.Background()
+	s, user, code, codes := newTestMFAService(t)
+	next := code(1)
+
+	if err := s.Reauthenticate(ctx, user, "wrong horse", next); !errors.Is(err, ErrMFAReauthentication) {
+		t.Errorf("Reauthenticate() with a wrong password = %v, want %v", err, ErrMFAReauthentication)
+	}
+	if err := s.Reauthenticate(ctx, user, "correct horse", "000000"); !errors.Is(err, ErrMFAReauthentication) {
+		t.Errorf("Reauthenticate() with a wrong code = %v, want %v", err, ErrMFAReauthentication)
+	}
+	if err := s.Reauthenticate(ctx, user, "correct horse", next); err != nil {
+		t.Fatalf("Reauthenticate() error = %v", err)
+	}
+	if err := s.Reauthenticate(ctx, user, "correct horse", next); !errors.Is(err, ErrMFAReauthentication) {
+		t.Errorf("Reauthenticate() with a used code = %v, want %v", err, ErrMFAReauthentication)
+	}
+
+	if err := s.Disable(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, err := s.IsEnabled(ctx, user.ID); err != nil || enabled {
+		t.Errorf("IsEnabled() after Disable() = %v, %v, want false", enabled, err)
+	}
+	if err := s.Verify(ctx, user.ID, codes[0]); !errors.Is(err, ErrMFANotEnabled) {
+		t.Errorf("Verify() after Disable() = %v, want %v", err, ErrMFANotEnabled)
+	}
+}
+
+func TestVerifyChallengeWorksOnce(t *testing.T) {
+	ctx := context.Background()
+	s, user, _, codes := newTestMFAService(t)
+
+	challenge := func(id string, expiresAt time.Time) string {
+		t.Helper()
+		if err := s.SaveChallenge(ctx, user.ID, id, expiresAt); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	later := time.Now().Add(5 * time.Minute)
+
+	id := challenge("challenge-1", later)
+	if err := s.VerifyChallenge(ctx, user.ID, id, codes[0]); err != nil {
+		t.Fatalf("VerifyChallenge() error = %v", err)
+	}
+	if err := s.VerifyChallenge(ctx, user.ID, id, codes[1]); !errors.Is(err, ErrMFAChallengeNotFound) {
+		t.Errorf("VerifyChallenge() with a used challenge = %v, want %v", err, ErrMFAChallengeNotFound)
+	}
+
+	// A wrong code uses up the challenge as well.
+	id = challenge("challenge-2", later)
+	if err := s.VerifyChallenge(ctx, user.ID, id, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyChallenge() with a wrong code = %v, want %v", err, ErrInvalidMFACode)
+	}
+	if err := s.VerifyChallenge(ctx, user.ID, id, codes[1]); !errors.Is(err, ErrMFAChallengeNotFound) {
+		t.Errorf("VerifyChallenge() after a wrong code = %v, want %v", err, ErrMFAChallengeNotFound)
+	}
+
+	id = challenge("challenge-3", later)
+	if err := s.VerifyChallenge(ctx, user.ID+1, id, codes[1]); !errors.Is(err, ErrMFAChallengeNotFound) {
+		t.Errorf("VerifyChallenge() of another user = %v, want %v", err, ErrMFAChallengeNotFound)
+	}
+
+	id = challenge("challenge-4", time.Now().Add(-time.Second))
+	if err := s.VerifyChallenge(ctx, user.ID, id, codes[1]); !errors.Is(err, ErrMFAChallengeNotFound) {
+		t.Errorf("VerifyChallenge() of an expired challenge = %v, want %v", err, ErrMFAChallengeNotFound)
+	}
+}
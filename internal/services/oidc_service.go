@@ -0,0 +1,433 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/responses"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	OIDCStateDuration = 10 * time.Minute
+	OIDCHTTPTimeout   = 10 * time.Second
+)
+
+var (
+	ErrOIDCProviderNotFound  = errors.New("identity provider not found")
+	ErrOIDCStateNotFound     = errors.New("sign in request not found or expired")
+	ErrOIDCVerification      = errors.New("identity provider response could not be verified")
+	ErrOIDCEmailNotVerified  = errors.New("identity provider did not confirm the email address")
+	ErrOIDCAccountNotFound   = errors.New("no account uses this email address, register first and link the provider from the settings")
+	ErrOIDCIdentityLinked    = errors.New("this identity is already linked to another account")
+	ErrOIDCProviderLinked    = errors.New("an identity of this provider is already linked")
+	ErrOIDCAccountUnverified = errors.New("the account with this email address is not verified, sign in with your password and verify it first")
+)
+
+var defaultOIDCScopes = []string{oidc.ScopeOpenID, "email", "profile"}
+
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// oidcProvider runs discovery on first use, so a provider that is down at
+// startup does not keep the API from starting.
+type oidcProvider struct {
+	config   OIDCProviderConfig
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// oidcClaims are the ID token claims used to find or link an account.
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// OIDCService signs users in with OpenID Connect providers using the
+// authorization code flow with PKCE. Providers are listed in OIDC_PROVIDERS
+// and configured with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, and the
+// optional _SCOPES and _DISPLAY_NAME. The provider redirects the browser to
+// OIDC_REDIRECT_URL, by default FRONTEND_URL/auth/oidc/callback, and the
+// frontend passes the code and state on to the API.
+type OIDCService struct {
+	db          *gorm.DB
+	redirectURL string
+	providers   map[string]*oidcProvider
+	order       []string
+	httpClient  *http.Client
+}
+
+func NewOIDCService(db *gorm.DB) *OIDCService {
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = strings.TrimSuffix(os.Getenv("FRONTEND_URL"), "/") + "/auth/oidc/callback"
+	}
+
+	var configs []OIDCProviderConfig
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		config := OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  os.Getenv(prefix + "DISPLAY_NAME"),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(strings.ReplaceAll(os.Getenv(prefix+"SCOPES"), ",", " ")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			log.Fatalf("⛔ Exit!!! OIDC provider %q needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		configs = append(configs, config)
+	}
+
+	return NewOIDCServiceWithProviders(db, redirectURL, configs)
+}
+
+// NewOIDCServiceWithProviders takes the provider configuration directly, so
+// the flow can run against a mock provider.
+func NewOIDCServiceWithProviders(db *gorm.DB, redirectURL string, configs []OIDCProviderConfig) *OIDCService {
+	s := &OIDCService{
+		db:          db,
+		redirectURL: redirectURL,
+		providers:   make(map[string]*oidcProvider, len(configs)),
+		httpClient:  &http.Client{Timeout: OIDCHTTPTimeout},
+	}
+
+	for _, config := range configs {
+		if config.DisplayName == "" {
+			config.DisplayName = config.Name
+		}
+		if len(config.Scopes) == 0 {
+			config.Scopes = defaultOIDCScopes
+		}
+		s.providers[config.Name] = &oidcProvider{config: config}
+		s.order = append(s.order, config.Name)
+	}
+
+	return s
+}
+
+func (s *OIDCService) GetProviders() []responses.OIDCProviderResponse {
+	providers := make([]responses.OIDCProviderResponse, 0, len(s.order))
+	for _, name := range s.order {
+		providers = append(providers, responses.OIDCProviderResponse{
+			Name:        name,
+			DisplayName: s.providers[name].config.DisplayName,
+		})
+	}
+	return providers
+}
+
+// BeginAuthorization returns the URL that starts a sign in at the provider.
+// With a userID the result links the identity to that user instead.
+func (s *OIDCService) BeginAuthorization(ctx context.Context, providerName string, userID *uint) (*responses.OIDCAuthorizationResponse, error) {
+	p, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+
+	oauth2Config, _, err := s.discover(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	stateID, err := generateOIDCValue()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := generateOIDCValue()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.OIDCState{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete expired oidc states: %w", err)
+	}
+
+	state := models.OIDCState{
+		ID:           stateID,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		UserID:       userID,
+		ExpiresAt:    now.Add(OIDCStateDuration),
+	}
+	if err := s.db.WithContext(ctx).Create(&state).Error; err != nil {
+		return nil, fmt.Errorf("failed to store oidc state: %w", err)
+	}
+
+	authorizationURL := oauth2Config.AuthCodeURL(state.ID,
+		oidc.Nonce(state.Nonce),
+		oauth2.S256ChallengeOption(state.CodeVerifier),
+	)
+	return &responses.OIDCAuthorizationResponse{AuthorizationURL: authorizationURL}, nil
+}
+
+// Login finishes a sign in started without a user. A known identity signs in
+// its user. An unknown one is linked to the account with the same email, but
+// only when the provider verified that email. Accounts scheduled for deletion
+// are returned too, the caller reactivates them.
+func (s *OIDCService) Login(ctx context.Context, stateID, code string) (*models.User, error) {
+	state, err := s.consumeState(ctx, stateID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, claims, err := s.exchange(ctx, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.getIdentity(ctx, state.Provider, subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch oidc identity: %w", err)
+	}
+
+	if identity == nil {
+		if !claims.EmailVerified || claims.Email == "" {
+			return nil, ErrOIDCEmailNotVerified
+		}
+
+		var user models.User
+		if err := s.db.WithContext(ctx).Unscoped().
+			Where("email = ?", utils.NormalizeEmail(claims.Email)).
+			First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrOIDCAccountNotFound
+			}
+			return nil, fmt.Errorf("failed to fetch user: %w", err)
+		}
+		// Whoever registered an unverified address may not own it, linking
+		// would let them share the account of the real owner.
+		if user.EmailVerifiedAt == nil {
+			return nil, ErrOIDCAccountUnverified
+		}
+
+		identity, err = s.createIdentity(ctx, user.ID, state.Provider, subject, claims.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Unscoped().Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	if err := s.touchIdentity(ctx, identity, claims.Email); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Link finishes a flow started by a signed in user and links the identity to
+// them. The email of the identity does not have to match the account.
+func (s *OIDCService) Link(ctx context.Context, userID uint, stateID, code string) (*models.OIDCIdentity, error) {
+	state, err := s.consumeState(ctx, stateID, &userID)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, claims, err := s.exchange(ctx, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.getIdentity(ctx, state.Provider, subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to fetch oidc identity: %w", err)
+	}
+	if identity != nil {
+		if identity.UserID != userID {
+			return nil, ErrOIDCIdentityLinked
+		}
+		return identity, s.touchIdentity(ctx, identity, claims.Email)
+	}
+
+	return s.createIdentity(ctx, userID, state.Provider, subject, claims.Email)
+}
+
+func (s *OIDCService) GetIdentitiesByUserID(ctx context.Context, userID uint) ([]models.OIDCIdentity, error) {
+	var identities []models.OIDCIdentity
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// Unlink removes an identity. Every account keeps its password, so the user
+// can still sign in without the provider.
+func (s *OIDCService) Unlink(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.OIDCIdentity{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *OIDCService) discover(ctx context.Context, p *oidcProvider) (*oauth2.Config, *oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider == nil {
+		provider, err := oidc.NewProvider(oidc.ClientContext(ctx, s.httpClient), p.config.Issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to discover identity provider %s: %w", p.config.Name, err)
+		}
+		p.provider = provider
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		Endpoint:     p.provider.Endpoint(),
+		RedirectURL:  s.redirectURL,
+		Scopes:       p.config.Scopes,
+	}, p.provider, nil
+}
+
+// exchange redeems the authorization code with the PKCE verifier and verifies
+// the signature, audience, expiry and nonce of the returned ID token.
+func (s *OIDCService) exchange(ctx context.Context, state *models.OIDCState, code string) (string, *oidcClaims, error) {
+	p, ok := s.providers[state.Provider]
+	if !ok {
+		return "", nil, ErrOIDCProviderNotFound
+	}
+
+	oauth2Config, provider, err := s.discover(ctx, p)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ctx = oidc.ClientContext(ctx, s.httpClient)
+	token, err := oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrOIDCVerification, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", nil, fmt.Errorf("%w: token response has no id_token", ErrOIDCVerification)
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrOIDCVerification, err)
+	}
+	if idToken.Nonce != state.Nonce {
+		return "", nil, fmt.Errorf("%w: nonce does not match", ErrOIDCVerification)
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrOIDCVerification, err)
+	}
+
+	return idToken.Subject, &claims, nil
+}
+
+// consumeState deletes the state while reading it, so each authorization
+// response is accepted once and only by the flow that started it.
+func (s *OIDCService) consumeState(ctx context.Context, id string, userID *uint) (*models.OIDCState, error) {
+	var states []models.OIDCState
+	if err := s.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND expires_at > ?", id, time.Now()).
+		Delete(&states).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc state: %w", err)
+	}
+	if len(states) == 0 {
+		return nil, ErrOIDCStateNotFound
+	}
+
+	state := states[0]
+	if (userID == nil) != (state.UserID == nil) || (userID != nil && *userID != *state.UserID) {
+		return nil, ErrOIDCStateNotFound
+	}
+	return &state, nil
+}
+
+func (s *OIDCService) getIdentity(ctx context.Context, provider, subject string) (*models.OIDCIdentity, error) {
+	var identity models.OIDCIdentity
+	if err := s.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (s *OIDCService) createIdentity(ctx context.Context, userID uint, provider, subject, email string) (*models.OIDCIdentity, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.OIDCIdentity{}).
+		Where("user_id = ? AND provider = ?", userID, provider).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to count oidc identities: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrOIDCProviderLinked
+	}
+
+	now := time.Now()
+	identity := &models.OIDCIdentity{
+		UserID:     userID,
+		Provider:   provider,
+		Subject:    subject,
+		Email:      email,
+		LastUsedAt: &now,
+	}
+	if err := s.db.WithContext(ctx).Create(identity).Error; err != nil {
+		return nil, fmt.Errorf("failed to link oidc identity: %w", err)
+	}
+	return identity, nil
+}
+
+func (s *OIDCService) touchIdentity(ctx context.Context, identity *models.OIDCIdentity, email string) error {
+	now := time.Now()
+	identity.LastUsedAt = &now
+	if email != "" {
+		identity.Email = email
+	}
+	if err := s.db.WithContext(ctx).Model(identity).
+		Select("email", "last_used_at").
+		Updates(identity).Error; err != nil {
+		return fmt.Errorf("failed to update oidc identity: %w", err)
+	}
+	return nil
+}
+
+func generateOIDCValue() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate oidc state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
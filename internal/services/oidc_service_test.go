@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOIDCProvider = "mock"
+	testOIDCClientID = "monexa"
+	testOIDCKeyID    = "mock-key"
+)
+
+// mockIssuer is an OpenID Connect provider that hands out codes for whatever
+// identity the test authorizes. The token endpoint checks the PKCE verifier
+// against the challenge of the authorization request like a real provider.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+type mockAuthorization struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{t: t, key: key, codes: make(map[string]mockAuthorization)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("GET /jwks", m.jwks)
+	mux.HandleFunc("POST /token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                m.server.URL,
+		"authorization_endpoint":                m.server.URL + "/authorize",
+		"token_endpoint":                        m.server.URL + "/token",
+		"jwks_uri":                              m.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testOIDCKeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mu.Lock()
+	authorization, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	verifierHash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != authorization.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, authorization.claims)
+	token.Header["kid"] = testOIDCKeyID
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		m.t.Error(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// authorize plays the user consenting at the provider. It reads the state,
+// nonce and PKCE challenge from the authorization URL and returns the state
+// and code the provider redirects back with. The claims override those of a
+// verified ana@example.com.
+func (m *mockIssuer) authorize(authorizationURL, subject string, claims jwt.MapClaims) (string, string) {
+	m.t.Helper()
+
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("authorization URL has no S256 PKCE challenge: %s", authorizationURL)
+	}
+
+	now := time.Now()
+	idTokenClaims := jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            subject,
+		"aud":            testOIDCClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          query.Get("nonce"),
+		"email":          "ana@example.com",
+		"email_verified": true,
+	}
+	for name, value := range claims {
+		idTokenClaims[name] = value
+	}
+
+	code := rand.Text()
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{challenge: query.Get("code_challenge"), claims: idTokenClaims}
+	m.mu.Unlock()
+
+	return query.Get("state"), code
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func newTestOIDCService(t *testing.T) (*OIDCService, *mockIssuer) {
+	t.Helper()
+
+	issuer := newMockIssuer(t)
+	db := newTestDB(t, &models.User{}, &models.OIDCIdentity{}, &models.OIDCState{})
+	s := NewOIDCServiceWithProviders(db, "https://monexa.test/auth/oidc/callback", []OIDCProviderConfig{{
+		Name:     testOIDCProvider,
+		Issuer:   issuer.server.URL,
+		ClientID: testOIDCClientID,
+	}})
+	return s, issuer
+}
+
+func createOIDCTestUser(t *testing.T, s *OIDCService, email string, verified bool) models.User {
+	t.Helper()
+
+	user := models.User{Email: email, Password: "hash", Name: "Ana", PPID: "ppid-" + email}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// beginOIDC starts a flow and lets the provider authorize it.
+func beginOIDC(t *testing.T, s *OIDCService, issuer *mockIssuer, userID *uint, subject string, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+
+	authorization, err := s.BeginAuthorization(context.Background(), testOIDCProvider, userID)
+	if err != nil {
+		t.Fatalf("BeginAuthorization() error = %v", err)
+	}
+	return issuer.authorize(authorization.AuthorizationURL, subject, claims)
+}
+
+func countRows(t *testing.T, s *OIDCService, model any) int64 {
+	t.Helper()
+
+	var count int64
+	if err := s.db.Model(model).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestOIDCLoginLinksVerifiedAccount(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+	user := createOIDCTestUser(t, s, "ana@example.com", true)
+
+	state, code := beginOIDC(t, s, issuer, nil, "subject-1", jwt.MapClaims{"email": "Ana@Example.com"})
+	loggedIn, err := s.Login(ctx, state, code)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if loggedIn.ID != user.ID {
+		t.Errorf("logged in user %d, want %d", loggedIn.ID, user.ID)
+	}
+
+	identities, err := s.GetIdentitiesByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 1 || identities[0].Subject != "subject-1" || identities[0].Provider != testOIDCProvider {
+		t.Fatalf("identities = %+v, want subject-1 of %s", identities, testOIDCProvider)
+	}
+
+	// The linked identity signs in by its subject, whatever email it has now.
+	state, code = beginOIDC(t, s, issuer, nil, "subject-1", jwt.MapClaims{"email": "ana@elsewhere.com", "email_verified": false})
+	loggedIn, err = s.Login(ctx, state, code)
+	if err != nil {
+		t.Fatalf("second Login() error = %v", err)
+	}
+	if loggedIn.ID != user.ID {
+		t.Errorf("second login signed in user %d, want %d", loggedIn.ID, user.ID)
+	}
+	if got := countRows(t, s, &models.User{}); got != 1 {
+		t.Errorf("%d users after signing in, want 1", got)
+	}
+}
+
+func TestOIDCLoginDoesNotCreateAccounts(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+
+	state, code := beginOIDC(t, s, issuer, nil, "subject-1", nil)
+	if _, err := s.Login(ctx, state, code); !errors.Is(err, ErrOIDCAccountNotFound) {
+		t.Errorf("Login() error = %v, want %v", err, ErrOIDCAccountNotFound)
+	}
+	if got := countRows(t, s, &models.User{}); got != 0 {
+		t.Errorf("%d users after signing in with an unknown email, want 0", got)
+	}
+	if got := countRows(t, s, &models.OIDCIdentity{}); got != 0 {
+		t.Errorf("%d identities after signing in with an unknown email, want 0", got)
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+	createOIDCTestUser(t, s, "ana@example.com", true)
+
+	state, code := beginOIDC(t, s, issuer, nil, "subject-1", jwt.MapClaims{"email_verified": false})
+	if _, err := s.Login(ctx, state, code); !errors.Is(err, ErrOIDCEmailNotVerified) {
+		t.Errorf("Login() error = %v, want %v", err, ErrOIDCEmailNotVerified)
+	}
+	if got := countRows(t, s, &models.OIDCIdentity{}); got != 0 {
+		t.Errorf("%d identities after an unverified email, want 0", got)
+	}
+}
+
+func TestOIDCLoginRejectsUnverifiedAccount(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+	createOIDCTestUser(t, s, "ana@example.com", false)
+
+	state, code := beginOIDC(t, s, issuer, nil, "subject-1", nil)
+	if _, err := s.Login(ctx, state, code); !errors.Is(err, ErrOIDCAccountUnverified) {
+		t.Errorf("Login() error = %v, want %v", err, ErrOIDCAccountUnverified)
+	}
+	if got := countRows(t, s, &models.OIDCIdentity{}); got != 0 {
+		t.Errorf("%d identities after an unverified account, want 0", got)
+	}
+}
+
+func TestOIDCLinkToSignedInUser(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+	ana := createOIDCTestUser(t, s, "ana@example.com", true)
+	marko := createOIDCTestUser(t, s, "marko@example.com", true)
+
+	// Linking does not need the emails to match.
+	state, code := beginOIDC(t, s, issuer, &ana.ID, "subject-1", jwt.MapClaims{"email": "ana@work.com", "email_verified": false})
+	identity, err := s.Link(ctx, ana.ID, state, code)
+	if err != nil {
+		t.Fatalf("Link() error = %v", err)
+	}
+	if identity.UserID != ana.ID || identity.Email != "ana@work.com" {
+		t.Errorf("identity = %+v, want ana@work.com of user %d", identity, ana.ID)
+	}
+
+	state, code = beginOIDC(t, s, issuer, &marko.ID, "subject-1", nil)
+	if _, err := s.Link(ctx, marko.ID, state, code); !errors.Is(err, ErrOIDCIdentityLinked) {
+		t.Errorf("Link() of an identity of another user error = %v, want %v", err, ErrOIDCIdentityLinked)
+	}
+
+	state, code = beginOIDC(t, s, issuer, &ana.ID, "subject-2", nil)
+	if _, err := s.Link(ctx, ana.ID, state, code); !errors.Is(err, ErrOIDCProviderLinked) {
+		t.Errorf("Link() of a second identity of the provider error = %v, want %v", err, ErrOIDCProviderLinked)
+	}
+}
+
+func TestOIDCStateMismatch(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+	user := createOIDCTestUser(t, s, "ana@example.com", true)
+
+	_, code := beginOIDC(t, s, issuer, nil, "subject-1", nil)
+	if _, err := s.Login(ctx, "forged-state", code); !errors.Is(err, ErrOIDCStateNotFound) {
+		t.Errorf("Login() with an unknown state error = %v, want %v", err, ErrOIDCStateNotFound)
+	}
+
+	state, code := beginOIDC(t, s, issuer, nil, "subject-1", nil)
+	if _, err := s.Login(ctx, state, code); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if _, err := s.Login(ctx, state, code); !errors.Is(err, ErrOIDCStateNotFound) {
+		t.Errorf("replayed Login() error = %v, want %v", err, ErrOIDCStateNotFound)
+	}
+
+	// A flow started to link an identity cannot finish as a sign in.
+	state, code = beginOIDC(t, s, issuer, &user.ID, "subject-1", nil)
+	if _, err := s.Login(ctx, state, code); !errors.Is(err, ErrOIDCStateNotFound) {
+		t.Errorf("Login() with a link state error = %v, want %v", err, ErrOIDCStateNotFound)
+	}
+}
+
+func TestOIDCNonceMismatch(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+	createOIDCTestUser(t, s, "ana@example.com", true)
+
+	state, code := beginOIDC(t, s, issuer, nil, "subject-1", jwt.MapClaims{"nonce": "replayed-nonce"})
+	if _, err := s.Login(ctx, state, code); !errors.Is(err, ErrOIDCVerification) {
+		t.Errorf("Login() with another nonce error = %v, want %v", err, ErrOIDCVerification)
+	}
+	if got := countRows(t, s, &models.OIDCIdentity{}); got != 0 {
+		t.Errorf("%d identities after a nonce mismatch, want 0", got)
+	}
+}
+
+func TestOIDCPKCEVerifierMismatch(t *testing.T) {
+	ctx := context.Background()
+	s, issuer := newTestOIDCService(t)
+	createOIDCTestUser(t, s, "ana@example.com", true)
+
+	// The code was issued to another flow, so the verifier of this state does
+	// not match its challenge.
+	state, _ := beginOIDC(t, s, issuer, nil, "subject-1", nil)
+	_, code := beginOIDC(t, s, issuer, nil, "subject-1", nil)
+	if _, err := s.Login(ctx, state, code); !errors.Is(err, ErrOIDCVerification) {
+		t.Errorf("Login() with another flow's code error = %v, want %v", err, ErrOIDCVerification)
+	}
+	if got := countRows(t, s, &models.OIDCIdentity{}); got != 0 {
+		t.Errorf("%d identities after a PKCE mismatch, want 0", got)
+	}
+}
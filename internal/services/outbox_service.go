@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxOutboxAttempts    = 8
+	OutboxBaseBackoff    = time.Minute
+	OutboxMaxBackoff     = 6 * time.Hour
+	OutboxSendLease      = 5 * time.Minute
+	OutboxSentRetention  = 30 * 24 * time.Hour
+	OutboxAdminListLimit = 100
+)
+
+var ErrOutboxEmailNotDead = errors.New("only dead emails can be retried")
+
+// OutboxService stores outgoing emails in the database so they survive mail
+// provider outages and restarts. Emails are enqueued in the same transaction
+// as the change that triggers them and delivered by OutboxWorker.
+type OutboxService struct {
+	db          *gorm.DB
+	mailService *MailService
+}
+
+func NewOutboxService(db *gorm.DB, mailService *MailService) *OutboxService {
+	return &OutboxService{
+		db:          db,
+		mailService: mailService,
+	}
+}
+
+// Enqueue adds an email to the outbox using tx, so it is only sent if tx
+// commits.
+func (s *OutboxService) Enqueue(tx *gorm.DB, userID *uint, template, to, subject, body string) error {
+	email := models.OutboxEmail{
+		UserID:        userID,
+		Template:      template,
+		Recipient:     to,
+		Subject:       subject,
+		Body:          body,
+		Status:        types.OutboxEmailPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := tx.Create(&email).Error; err != nil {
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return nil
+}
+
+// DeliverNext sends the next due email. It returns false when nothing is due.
+func (s *OutboxService) DeliverNext(ctx context.Context) (bool, error) {
+	email, err := s.claimNext(ctx)
+	if err != nil {
+		return false, err
+	}
+	if email == nil {
+		return false, nil
+	}
+
+	if err := s.mailService.SendHTML(email.Recipient, email.Subject, email.Body); err != nil {
+		return true, s.recordFailure(ctx, email, err)
+	}
+
+	// The body is dropped once delivered since it can carry one-time links.
+	if err := s.db.WithContext(ctx).Model(email).Updates(map[string]any{
+		"status":     types.OutboxEmailSent,
+		"sent_at":    time.Now(),
+		"body":       "",
+		"last_error": nil,
+	}).Error; err != nil {
+		return true, fmt.Errorf("failed to mark email %d as sent: %w", email.ID, err)
+	}
+	return true, nil
+}
+
+// claimNext leases the next due email by pushing its next attempt past the
+// send timeout, so a worker that dies while sending does not lose the email.
+func (s *OutboxService) claimNext(ctx context.Context) (*models.OutboxEmail, error) {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var email models.OutboxEmail
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", types.OutboxEmailPending, time.Now()).
+		Order("next_attempt_at").
+		First(&email).Error
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch pending email: %w", err)
+	}
+
+	email.Attempts++
+	if err := tx.Model(&email).Updates(map[string]any{
+		"attempts":        email.Attempts,
+		"next_attempt_at": time.Now().Add(OutboxSendLease),
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to claim email: %w", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit email claim: %w", err)
+	}
+
+	return &email, nil
+}
+
+// recordFailure schedules the next attempt with exponential backoff, or moves
+// the email to the dead letters once it ran out of attempts.
+func (s *OutboxService) recordFailure(ctx context.Context, email *models.OutboxEmail, sendErr error) error {
+	message := sendErr.Error()
+	updates := map[string]any{"last_error": message}
+
+	if email.Attempts >= MaxOutboxAttempts {
+		log.Printf("🛑 Error!!! email %d to %s is dead after %d attempts: %v", email.ID, email.Recipient, email.Attempts, sendErr)
+		updates["status"] = types.OutboxEmailDead
+	} else {
+		log.Printf("🛑 Error!!! email %d to %s failed on attempt %d: %v", email.ID, email.Recipient, email.Attempts, sendErr)
+		updates["next_attempt_at"] = time.Now().Add(outboxBackoff(email.Attempts))
+	}
+
+	if err := s.db.WithContext(ctx).Model(email).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to record failure of email %d: %w", email.ID, err)
+	}
+	return nil
+}
+
+// outboxBackoff doubles the delay after every attempt, from OutboxBaseBackoff
+// up to OutboxMaxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	backoff := OutboxBaseBackoff
+	for i := 1; i < attempts && backoff < OutboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, OutboxMaxBackoff)
+}
+
+// GetByStatus lists the most recent emails with the given status.
+func (s *OutboxService) GetByStatus(ctx context.Context, status types.OutboxEmailStatus) ([]models.OutboxEmail, error) {
+	var emails []models.OutboxEmail
+	if err := s.db.WithContext(ctx).
+		Where("status = ?", status).
+		Order("updated_at DESC").
+		Limit(OutboxAdminListLimit).
+		Find(&emails).Error; err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// Retry puts a dead email back into the queue with a fresh set of attempts.
+func (s *OutboxService) Retry(ctx context.Context, id uint) (*models.OutboxEmail, error) {
+	var email models.OutboxEmail
+	if err := s.db.WithContext(ctx).Where("id = ?", id).First(&email).Error; err != nil {
+		return nil, err
+	}
+	if email.Status != types.OutboxEmailDead {
+		return nil, ErrOutboxEmailNotDead
+	}
+
+	email.Status = types.OutboxEmailPending
+	email.Attempts = 0
+	email.NextAttemptAt = time.Now()
+	if err := s.db.WithContext(ctx).Model(&email).Updates(map[string]any{
+		"status":          email.Status,
+		"attempts":        email.Attempts,
+		"next_attempt_at": email.NextAttemptAt,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to retry email: %w", err)
+	}
+	return &email, nil
+}
+
+// CleanupSentEmails deletes delivered emails older than OutboxSentRetention.
+func (s *OutboxService) CleanupSentEmails(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("status = ? AND sent_at < ?", types.OutboxEmailSent, time.Now().Add(-OutboxSentRetention)).
+		Delete(&models.OutboxEmail{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to cleanup sent emails: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
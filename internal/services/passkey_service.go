@@ -0,0 +1,321 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/responses"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PasskeyCeremonyDuration = 5 * time.Minute
+	MaxPasskeysPerUser      = 10
+	PasskeyNameMaxLength    = 64
+)
+
+var (
+	ErrPasskeyCeremonyNotFound = errors.New("passkey ceremony not found or expired")
+	ErrPasskeyVerification     = errors.New("passkey verification failed")
+	ErrTooManyPasskeys         = errors.New("too many passkeys")
+)
+
+// PasskeyService runs the WebAuthn registration and login ceremonies. The
+// relying party is configured with WEBAUTHN_RP_ID and WEBAUTHN_RP_ORIGINS,
+// which default to the host and origin of FRONTEND_URL.
+type PasskeyService struct {
+	db       *gorm.DB
+	webAuthn *webauthn.WebAuthn
+}
+
+func NewPasskeyService(db *gorm.DB) *PasskeyService {
+	frontendURL, _ := url.Parse(os.Getenv("FRONTEND_URL"))
+
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" && frontendURL != nil {
+		rpID = frontendURL.Hostname()
+	}
+
+	var origins []string
+	if env := os.Getenv("WEBAUTHN_RP_ORIGINS"); env != "" {
+		origins = strings.Split(env, ",")
+	} else if frontendURL != nil {
+		origins = []string{frontendURL.Scheme + "://" + frontendURL.Host}
+	}
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: "Monexa",
+		RPOrigins:     origins,
+	})
+	if err != nil {
+		log.Fatalf("⛔ Exit!!! Invalid WebAuthn configuration: %v", err)
+	}
+
+	return NewPasskeyServiceWithWebAuthn(db, webAuthn)
+}
+
+// NewPasskeyServiceWithWebAuthn takes a configured relying party, so the
+// ceremonies can run against a software authenticator with a fixed origin.
+func NewPasskeyServiceWithWebAuthn(db *gorm.DB, webAuthn *webauthn.WebAuthn) *PasskeyService {
+	return &PasskeyService{
+		db:       db,
+		webAuthn: webAuthn,
+	}
+}
+
+// passkeyUser adapts a user and their credentials to webauthn.User. The user
+// handle is the PPID, which is stable and reveals nothing about the account.
+type passkeyUser struct {
+	user        models.User
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return []byte(u.user.PPID)
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (s *PasskeyService) GetAllByUserID(ctx context.Context, userID uint) ([]models.PasskeyCredential, error) {
+	var credentials []models.PasskeyCredential
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (s *PasskeyService) Delete(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.PasskeyCredential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// BeginRegistration creates the options for navigator.credentials.create. The
+// passkeys the user already has are excluded so the same authenticator is not
+// registered twice.
+func (s *PasskeyService) BeginRegistration(ctx context.Context, user models.User) (*responses.PasskeyCeremonyResponse, error) {
+	existing, err := s.loadPasskeyUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing.credentials) >= MaxPasskeysPerUser {
+		return nil, ErrTooManyPasskeys
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(existing.credentials))
+	for _, credential := range existing.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(existing,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey registration: %w", err)
+	}
+
+	ceremonyID, err := s.saveCeremony(ctx, &user.ID, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.PasskeyCeremonyResponse{CeremonyID: ceremonyID, Options: creation}, nil
+}
+
+// FinishRegistration verifies the attestation from the browser and stores the
+// new credential.
+func (s *PasskeyService) FinishRegistration(ctx context.Context, user models.User, ceremonyID, name string, body []byte) (*models.PasskeyCredential, error) {
+	session, err := s.consumeCeremony(ctx, ceremonyID, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyVerification, err)
+	}
+
+	existing, err := s.loadPasskeyUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.webAuthn.CreateCredential(existing, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyVerification, err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len([]rune(name)) > PasskeyNameMaxLength {
+		name = string([]rune(name)[:PasskeyNameMaxLength])
+	}
+
+	passkey := &models.PasskeyCredential{
+		UserID:       user.ID,
+		CredentialID: credential.ID,
+		Name:         name,
+		Credential:   *credential,
+		SignCount:    credential.Authenticator.SignCount,
+	}
+	if err := s.db.WithContext(ctx).Create(passkey).Error; err != nil {
+		return nil, fmt.Errorf("failed to store passkey: %w", err)
+	}
+
+	return passkey, nil
+}
+
+// BeginLogin creates the options for navigator.credentials.get. The login is
+// discoverable, the authenticator tells which account the passkey belongs to.
+func (s *PasskeyService) BeginLogin(ctx context.Context) (*responses.PasskeyCeremonyResponse, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey login: %w", err)
+	}
+
+	ceremonyID, err := s.saveCeremony(ctx, nil, session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.PasskeyCeremonyResponse{CeremonyID: ceremonyID, Options: assertion}, nil
+}
+
+// FinishLogin verifies the assertion from the browser and returns the user it
+// belongs to, including accounts scheduled for deletion. The sign counter is
+// updated, and a counter that went backwards fails the login since it points
+// to a cloned authenticator.
+func (s *PasskeyService) FinishLogin(ctx context.Context, ceremonyID string, body []byte) (*models.User, error) {
+	session, err := s.consumeCeremony(ctx, ceremonyID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyVerification, err)
+	}
+
+	var passkey models.PasskeyCredential
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		if err := s.db.WithContext(ctx).Where("credential_id = ?", rawID).First(&passkey).Error; err != nil {
+			return nil, err
+		}
+
+		var user models.User
+		if err := s.db.WithContext(ctx).Unscoped().Where("id = ?", passkey.UserID).First(&user).Error; err != nil {
+			return nil, err
+		}
+		if !bytes.Equal([]byte(user.PPID), userHandle) {
+			return nil, errors.New("user handle does not match the credential")
+		}
+
+		return &passkeyUser{user: user, credentials: []webauthn.Credential{passkey.Credential}}, nil
+	}
+
+	webAuthnUser, credential, err := s.webAuthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyVerification, err)
+	}
+	if credential.Authenticator.CloneWarning {
+		return nil, fmt.Errorf("%w: sign counter did not increase", ErrPasskeyVerification)
+	}
+
+	now := time.Now()
+	passkey.Credential = *credential
+	passkey.SignCount = credential.Authenticator.SignCount
+	passkey.LastUsedAt = &now
+	if err := s.db.WithContext(ctx).Model(&passkey).
+		Select("credential", "sign_count", "last_used_at").
+		Updates(&passkey).Error; err != nil {
+		return nil, fmt.Errorf("failed to update passkey: %w", err)
+	}
+
+	user := webAuthnUser.(*passkeyUser).user
+	return &user, nil
+}
+
+func (s *PasskeyService) loadPasskeyUser(ctx context.Context, user models.User) (*passkeyUser, error) {
+	passkeys, err := s.GetAllByUserID(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch passkeys: %w", err)
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		credentials = append(credentials, passkey.Credential)
+	}
+	return &passkeyUser{user: user, credentials: credentials}, nil
+}
+
+// saveCeremony stores the session data of a ceremony and drops expired ones
+// on the way.
+func (s *PasskeyService) saveCeremony(ctx context.Context, userID *uint, session *webauthn.SessionData) (string, error) {
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&models.PasskeyCeremony{}).Error; err != nil {
+		return "", fmt.Errorf("failed to delete expired passkey ceremonies: %w", err)
+	}
+
+	ceremony := models.PasskeyCeremony{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Session:   *session,
+		ExpiresAt: now.Add(PasskeyCeremonyDuration),
+	}
+	if err := s.db.WithContext(ctx).Create(&ceremony).Error; err != nil {
+		return "", fmt.Errorf("failed to store passkey ceremony: %w", err)
+	}
+	return ceremony.ID, nil
+}
+
+// consumeCeremony deletes the ceremony while reading it, so a challenge can be
+// answered only once.
+func (s *PasskeyService) consumeCeremony(ctx context.Context, id string, userID *uint) (*webauthn.SessionData, error) {
+	var ceremonies []models.PasskeyCeremony
+	if err := s.db.WithContext(ctx).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND expires_at > ?", id, time.Now()).
+		Delete(&ceremonies).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch passkey ceremony: %w", err)
+	}
+	if len(ceremonies) == 0 {
+		return nil, ErrPasskeyCeremonyNotFound
+	}
+
+	ceremony := ceremonies[0]
+	if (userID == nil) != (ceremony.UserID == nil) || (userID != nil && *userID != *ceremony.UserID) {
+		return nil, ErrPasskeyCeremonyNotFound
+	}
+	return &ceremony.Session, nil
+}
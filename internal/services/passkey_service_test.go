@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	testRPID   = "monexa.test"
+	testOrigin = "https://monexa.test"
+)
+
+// softwareAuthenticator is a passkey that lives in memory. It answers the
+// ceremonies the way a browser and platform authenticator would, with a "none"
+// attestation and an ES256 key.
+type softwareAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+	return &softwareAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+func (a *softwareAuthenticator) clientData(ceremony protocol.CeremonyType, challenge string) []byte {
+	clientData, err := json.Marshal(map[string]string{
+		"type":      string(ceremony),
+		"challenge": challenge,
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return clientData
+}
+
+func (a *softwareAuthenticator) authenticatorData(flags protocol.AuthenticatorFlags, attestedCredentialData []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attestedCredentialData...)
+}
+
+// create answers navigator.credentials.create for the given challenge.
+func (a *softwareAuthenticator) create(challenge string, userHandle []byte) []byte {
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // all-zero AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(flags, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.marshal(map[string]any{
+		"clientDataJSON":    encode(a.clientData(protocol.CreateCeremony, challenge)),
+		"attestationObject": encode(attestationObject),
+	})
+}
+
+// get answers navigator.credentials.get for the given challenge, counting the
+// signature like a hardware authenticator would.
+func (a *softwareAuthenticator) get(challenge string) []byte {
+	a.signCount++
+	return a.getWithCount(challenge, a.signCount)
+}
+
+func (a *softwareAuthenticator) getWithCount(challenge string, signCount uint32) []byte {
+	a.signCount = signCount
+
+	authenticatorData := a.authenticatorData(protocol.FlagUserPresent|protocol.FlagUserVerified, nil)
+	clientData := a.clientData(protocol.AssertCeremony, challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.marshal(map[string]any{
+		"clientDataJSON":    encode(clientData),
+		"authenticatorData": encode(authenticatorData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softwareAuthenticator) marshal(response map[string]any) []byte {
+	body, err := json.Marshal(map[string]any{
+		"id":       encode(a.credentialID),
+		"rawId":    encode(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return body
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newTestPasskeyService(t *testing.T) (*PasskeyService, models.User) {
+	t.Helper()
+
+	db := newTestDB(t, &models.User{}, &models.PasskeyCredential{}, &models.PasskeyCeremony{})
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Monexa",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := models.User{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return NewPasskeyServiceWithWebAuthn(db, webAuthn), user
+}
+
+func beginRegistration(t *testing.T, s *PasskeyService, user models.User) (string, string, []byte) {
+	t.Helper()
+
+	ceremony, err := s.BeginRegistration(context.Background(), user)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	creation := ceremony.Options.(*protocol.CredentialCreation)
+	return ceremony.CeremonyID, creation.Response.Challenge.String(), creation.Response.User.ID.(protocol.URLEncodedBase64)
+}
+
+func beginLogin(t *testing.T, s *PasskeyService) (string, string) {
+	t.Helper()
+
+	ceremony, err := s.BeginLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	assertion := ceremony.Options.(*protocol.CredentialAssertion)
+	return ceremony.CeremonyID, assertion.Response.Challenge.String()
+}
+
+// registerPasskey runs a whole registration and returns the authenticator that
+// now holds the passkey.
+func registerPasskey(t *testing.T, s *PasskeyService, user models.User) *softwareAuthenticator {
+	t.Helper()
+
+	authenticator := newSoftwareAuthenticator(t)
+	ceremonyID, challenge, userHandle := beginRegistration(t, s, user)
+	if _, err := s.FinishRegistration(context.Background(), user, ceremonyID, "Laptop", authenticator.create(challenge, userHandle)); err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	return authenticator
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPasskeyService(t)
+
+	authenticator := newSoftwareAuthenticator(t)
+	ceremonyID, challenge, userHandle := beginRegistration(t, s, user)
+	if string(userHandle) != user.PPID {
+		t.Errorf("user handle = %q, want the PPID %q", userHandle, user.PPID)
+	}
+
+	passkey, err := s.FinishRegistration(ctx, user, ceremonyID, "  Laptop  ", authenticator.create(challenge, userHandle))
+	if err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	if passkey.Name != "Laptop" || passkey.UserID != user.ID {
+		t.Errorf("passkey = %q of user %d, want \"Laptop\" of user %d", passkey.Name, passkey.UserID, user.ID)
+	}
+
+	ceremonyID, challenge = beginLogin(t, s)
+	loggedIn, err := s.FinishLogin(ctx, ceremonyID, authenticator.get(challenge))
+	if err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+	if loggedIn.ID != user.ID {
+		t.Errorf("logged in user %d, want %d", loggedIn.ID, user.ID)
+	}
+
+	passkeys, err := s.GetAllByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passkeys) != 1 || passkeys[0].SignCount != 1 || passkeys[0].LastUsedAt == nil {
+		t.Errorf("passkey after login = %+v, want sign count 1 and a last use", passkeys)
+	}
+}
+
+func TestPasskeyCeremonyCannotBeReplayed(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPasskeyService(t)
+
+	authenticator := newSoftwareAuthenticator(t)
+	ceremonyID, challenge, userHandle := beginRegistration(t, s, user)
+	attestation := authenticator.create(challenge, userHandle)
+	if _, err := s.FinishRegistration(ctx, user, ceremonyID, "Laptop", attestation); err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	if _, err := s.FinishRegistration(ctx, user, ceremonyID, "Laptop", attestation); !errors.Is(err, ErrPasskeyCeremonyNotFound) {
+		t.Errorf("replayed FinishRegistration() error = %v, want %v", err, ErrPasskeyCeremonyNotFound)
+	}
+
+	ceremonyID, challenge = beginLogin(t, s)
+	assertion := authenticator.get(challenge)
+	if _, err := s.FinishLogin(ctx, ceremonyID, assertion); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+	if _, err := s.FinishLogin(ctx, ceremonyID, assertion); !errors.Is(err, ErrPasskeyCeremonyNotFound) {
+		t.Errorf("replayed FinishLogin() error = %v, want %v", err, ErrPasskeyCeremonyNotFound)
+	}
+}
+
+func TestPasskeyCeremonyIsConsumedByFailedAttempt(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPasskeyService(t)
+	authenticator := registerPasskey(t, s, user)
+
+	ceremonyID, challenge := beginLogin(t, s)
+	if _, err := s.FinishLogin(ctx, ceremonyID, []byte("{}")); !errors.Is(err, ErrPasskeyVerification) {
+		t.Fatalf("FinishLogin() with a broken body error = %v, want %v", err, ErrPasskeyVerification)
+	}
+	if _, err := s.FinishLogin(ctx, ceremonyID, authenticator.get(challenge)); !errors.Is(err, ErrPasskeyCeremonyNotFound) {
+		t.Errorf("FinishLogin() after a failed attempt error = %v, want %v", err, ErrPasskeyCeremonyNotFound)
+	}
+}
+
+func TestPasskeyRegistrationCeremonyBelongsToUser(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPasskeyService(t)
+
+	other := models.User{Email: "marko@example.com", Password: "hash", Name: "Marko", PPID: "ppid-marko"}
+	if err := s.db.Create(&other).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ceremonyID, challenge, userHandle := beginRegistration(t, s, user)
+	attestation := newSoftwareAuthenticator(t).create(challenge, userHandle)
+	if _, err := s.FinishRegistration(ctx, other, ceremonyID, "Laptop", attestation); !errors.Is(err, ErrPasskeyCeremonyNotFound) {
+		t.Errorf("FinishRegistration() by another user error = %v, want %v", err, ErrPasskeyCeremonyNotFound)
+	}
+}
+
+func TestPasskeyChallengeMismatch(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPasskeyService(t)
+
+	ceremonyID, _, userHandle := beginRegistration(t, s, user)
+	_, otherChallenge, _ := beginRegistration(t, s, user)
+	authenticator := newSoftwareAuthenticator(t)
+	if _, err := s.FinishRegistration(ctx, user, ceremonyID, "Laptop", authenticator.create(otherChallenge, userHandle)); !errors.Is(err, ErrPasskeyVerification) {
+		t.Errorf("FinishRegistration() with another challenge error = %v, want %v", err, ErrPasskeyVerification)
+	}
+
+	authenticator = registerPasskey(t, s, user)
+	ceremonyID, _ = beginLogin(t, s)
+	_, otherChallenge = beginLogin(t, s)
+	if _, err := s.FinishLogin(ctx, ceremonyID, authenticator.get(otherChallenge)); !errors.Is(err, ErrPasskeyVerification) {
+		t.Errorf("FinishLogin() with another challenge error = %v, want %v", err, ErrPasskeyVerification)
+	}
+}
+
+func TestPasskeySignCountRegression(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPasskeyService(t)
+	authenticator := registerPasskey(t, s, user)
+
+	ceremonyID, challenge := beginLogin(t, s)
+	if _, err := s.FinishLogin(ctx, ceremonyID, authenticator.getWithCount(challenge, 5)); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+
+	for _, signCount := range []uint32{5, 3} {
+		ceremonyID, challenge = beginLogin(t, s)
+		if _, err := s.FinishLogin(ctx, ceremonyID, authenticator.getWithCount(challenge, signCount)); !errors.Is(err, ErrPasskeyVerification) {
+			t.Errorf("FinishLogin() with sign count %d after 5 error = %v, want %v", signCount, err, ErrPasskeyVerification)
+		}
+	}
+
+	passkeys, err := s.GetAllByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passkeys) != 1 || passkeys[0].SignCount != 5 || passkeys[0].Credential.Authenticator.CloneWarning {
+		t.Errorf("passkey after rejected logins = %+v, want sign count 5 without a clone warning", passkeys)
+	}
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/responses"
+	"gorm.io/gorm"
+)
+
+const (
+	PersonalAccessTokenPrefix         = "mnx_pat_"
+	PersonalAccessTokenBytes          = 32
+	PersonalAccessTokenDisplayLength  = len(PersonalAccessTokenPrefix) + 6
+	DefaultPersonalAccessTokenDays    = 90
+	MaxPersonalAccessTokenDays        = 365
+	MaxPersonalAccessTokensPerUser    = 20
+	PersonalAccessTokenNameMaxLength  = 64
+	PersonalAccessTokenLastUsedWindow = time.Minute
+)
+
+var (
+	ErrInvalidPersonalAccessToken   = errors.New("invalid or expired personal access token")
+	ErrTooManyPersonalAccessTokens  = errors.New("too many personal access tokens")
+	ErrPersonalAccessTokenName      = errors.New("name is required and must be at most 64 characters")
+	ErrPersonalAccessTokenScopes    = errors.New("at least one valid scope is required: records:read, records:write, export")
+	ErrPersonalAccessTokenExpiresIn = errors.New("expiresInDays must be between 1 and 365")
+)
+
+type PersonalAccessTokenService struct {
+	db *gorm.DB
+}
+
+func NewPersonalAccessTokenService(db *gorm.DB) *PersonalAccessTokenService {
+	return &PersonalAccessTokenService{db: db}
+}
+
+func (s *PersonalAccessTokenService) GetAllByUserID(ctx context.Context, userID uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Create stores a new token and returns it in plain text together with its
+// record. The plain token cannot be recovered later.
+func (s *PersonalAccessTokenService) Create(ctx context.Context, req requests.PersonalAccessTokenRequest) (*responses.PersonalAccessTokenCreatedResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > PersonalAccessTokenNameMaxLength {
+		return nil, ErrPersonalAccessTokenName
+	}
+
+	var scopes []types.TokenScope
+	for _, scope := range req.Scopes {
+		if !types.IsValidTokenScope(scope) {
+			return nil, ErrPersonalAccessTokenScopes
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, ErrPersonalAccessTokenScopes
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = DefaultPersonalAccessTokenDays
+	}
+	if days < 1 || days > MaxPersonalAccessTokenDays {
+		return nil, ErrPersonalAccessTokenExpiresIn
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("user_id = ?", req.UserID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to count personal access tokens: %w", err)
+	}
+	if count >= MaxPersonalAccessTokensPerUser {
+		return nil, ErrTooManyPersonalAccessTokens
+	}
+
+	b := make([]byte, PersonalAccessTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate personal access token: %w", err)
+	}
+	plain := PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	record := models.PersonalAccessToken{
+		UserID:    req.UserID,
+		Name:      name,
+		Prefix:    plain[:PersonalAccessTokenDisplayLength],
+		TokenHash: hashPersonalAccessToken(plain),
+		Scopes:    scopes,
+		ExpiresAt: time.Now().AddDate(0, 0, days),
+	}
+	if err := s.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store personal access token: %w", err)
+	}
+
+	return &responses.PersonalAccessTokenCreatedResponse{Token: plain, PersonalAccessToken: record}, nil
+}
+
+func (s *PersonalAccessTokenService) Revoke(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.PersonalAccessToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the token and its user for a plain token that has not
+// expired. Accounts scheduled for deletion cannot use their tokens. The last
+// use is recorded at most once per PersonalAccessTokenLastUsedWindow to keep
+// API calls from writing on every request.
+func (s *PersonalAccessTokenService) Authenticate(ctx context.Context, plain string) (*models.PersonalAccessToken, *models.User, error) {
+	var record models.PersonalAccessToken
+	if err := s.db.WithContext(ctx).
+		Where("token_hash = ? AND expires_at > ?", hashPersonalAccessToken(plain), time.Now()).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidPersonalAccessToken
+		}
+		return nil, nil, fmt.Errorf("failed to fetch personal access token: %w", err)
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", record.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidPersonalAccessToken
+		}
+		return nil, nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	now := time.Now()
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) > PersonalAccessTokenLastUsedWindow {
+		if err := s.db.WithContext(ctx).Model(&record).Update("last_used_at", now).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to record personal access token use: %w", err)
+		}
+	}
+
+	return &record, &user, nil
+}
+
+func hashPersonalAccessToken(plain string) string {
+	h := sha256.Sum256([]byte(plain))
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
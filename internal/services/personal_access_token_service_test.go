@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"gorm.io/gorm"
+)
+
+func newTestPersonalAccessTokenService(t *testing.T) (*PersonalAccessTokenService, models.User) {
+	t.Helper()
+
+	db := newTestDB(t, &models.User{}, &models.PersonalAccessToken{})
+	user := models.User{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return NewPersonalAccessTokenService(db), user
+}
+
+func TestCreatePersonalAccessTokenStoresOnlyTheHash(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPersonalAccessTokenService(t)
+
+	created, err := s.Create(ctx, requests.PersonalAccessTokenRequest{
+		UserID: user.ID,
+		Name:   " MCP server ",
+		Scopes: []types.TokenScope{types.TokenScopeRecordsRead, types.TokenScopeExport, types.TokenScopeRecordsRead},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	record := created.PersonalAccessToken
+
+	if !strings.HasPrefix(created.Token, PersonalAccessTokenPrefix) || !strings.HasPrefix(created.Token, record.Prefix) {
+		t.Errorf("Create() token %q does not start with %q and its prefix %q", created.Token, PersonalAccessTokenPrefix, record.Prefix)
+	}
+	if len(record.Prefix) != PersonalAccessTokenDisplayLength {
+		t.Errorf("prefix %q has %d characters, want %d", record.Prefix, len(record.Prefix), PersonalAccessTokenDisplayLength)
+	}
+	if record.Name != "MCP server" {
+		t.Errorf("name = %q, want it trimmed", record.Name)
+	}
+	if want := []types.TokenScope{types.TokenScopeRecordsRead, types.TokenScopeExport}; !slices.Equal(record.Scopes, want) {
+		t.Errorf("scopes = %v, want %v", record.Scopes, want)
+	}
+	if days := time.Until(record.ExpiresAt).Hours() / 24; days < DefaultPersonalAccessTokenDays-1 || days > DefaultPersonalAccessTokenDays {
+		t.Errorf("token expires in %.1f days, want %d", days, DefaultPersonalAccessTokenDays)
+	}
+
+	var stored models.PersonalAccessToken
+	if err := s.db.First(&stored, record.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.TokenHash == created.Token || strings.Contains(stored.TokenHash, created.Token[len(record.Prefix):]) {
+		t.Errorf("stored token hash %q contains the plain token", stored.TokenHash)
+	}
+	if stored.TokenHash != hashPersonalAccessToken(created.Token) {
+		t.Errorf("stored token hash %q is not the hash of the token", stored.TokenHash)
+	}
+}
+
+func TestCreatePersonalAccessTokenValidatesTheRequest(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPersonalAccessTokenService(t)
+	scopes := []types.TokenScope{types.TokenScopeRecordsRead}
+
+	tests := []struct {
+		name string
+		req  requests.PersonalAccessTokenRequest
+		want error
+	}{
+		{"blank name", requests.PersonalAccessTokenRequest{Name: " ", Scopes: scopes}, ErrPersonalAccessTokenName},
+		{"long name", requests.PersonalAccessTokenRequest{Name: strings.Repeat("a", PersonalAccessTokenNameMaxLength+1), Scopes: scopes}, ErrPersonalAccessTokenName},
+		{"no scopes", requests.PersonalAccessTokenRequest{Name: "MCP"}, ErrPersonalAccessTokenScopes},
+		{"unknown scope", requests.PersonalAccessTokenRequest{Name: "MCP", Scopes: []types.TokenScope{"admin"}}, ErrPersonalAccessTokenScopes},
+		{"negative lifetime", requests.PersonalAccessTokenRequest{Name: "MCP", Scopes: scopes, ExpiresInDays: -1}, ErrPersonalAccessTokenExpiresIn},
+		{"long lifetime", requests.PersonalAccessTokenRequest{Name: "MCP", Scopes: scopes, ExpiresInDays: MaxPersonalAccessTokenDays + 1}, ErrPersonalAccessTokenExpiresIn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.UserID = user.ID
+			if _, err := s.Create(ctx, tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("Create() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	for i := range MaxPersonalAccessTokensPerUser {
+		if _, err := s.Create(ctx, requests.PersonalAccessTokenRequest{UserID: user.ID, Name: fmt.Sprint("token ", i), Scopes: scopes}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Create(ctx, requests.PersonalAccessTokenRequest{UserID: user.ID, Name: "one too many", Scopes: scopes}); !errors.Is(err, ErrTooManyPersonalAccessTokens) {
+		t.Errorf("Create() past the limit error = %v, want %v", err, ErrTooManyPersonalAccessTokens)
+	}
+}
+
+func TestAuthenticatePersonalAccessToken(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestPersonalAccessTokenService(t)
+
+	create := func(name string) string {
+		t.Helper()
+		created, err := s.Create(ctx, requests.PersonalAccessTokenRequest{UserID: user.ID, Name: name, Scopes: []types.TokenScope{types.TokenScopeRecordsRead}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.Token
+	}
+
+	plain := create("MCP")
+	record, authenticated, err := s.Authenticate(ctx, plain)
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if authenticated.ID != user.ID || record.LastUsedAt == nil {
+		t.Errorf("Authenticate() = user %d, last used %v, want user %d and the use recorded", authenticated.ID, record.LastUsedAt, user.ID)
+	}
+
+	if _, _, err := s.Authenticate(ctx, plain+"x"); !errors.Is(err, ErrInvalidPersonalAccessToken) {
+		t.Errorf("Authenticate() with a wrong token error = %v, want %v", err, ErrInvalidPersonalAccessToken)
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		plain := create("expired")
+		if err := s.db.Model(&models.PersonalAccessToken{}).
+			Where("token_hash = ?", hashPersonalAccessToken(plain)).
+			Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.Authenticate(ctx, plain); !errors.Is(err, ErrInvalidPersonalAccessToken) {
+			t.Errorf("Authenticate() with an expired token error = %v, want %v", err, ErrInvalidPersonalAccessToken)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		plain := create("revoked")
+		record, _, err := s.Authenticate(ctx, plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Revoke(ctx, user.ID+1, record.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Revoke() of another user's token error = %v, want %v", err, gorm.ErrRecordNotFound)
+		}
+		if err := s.Revoke(ctx, user.ID, record.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.Authenticate(ctx, plain); !errors.Is(err, ErrInvalidPersonalAccessToken) {
+			t.Errorf("Authenticate() with a revoked token error = %v, want %v", err, ErrInvalidPersonalAccessToken)
+		}
+	})
+
+	t.Run("deleted account", func(t *testing.T) {
+		if err := s.db.Delete(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.Authenticate(ctx, plain); !errors.Is(err, ErrInvalidPersonalAccessToken) {
+			t.Errorf("Authenticate() of a deleted account error = %v, want %v", err, ErrInvalidPersonalAccessToken)
+		}
+	})
+}
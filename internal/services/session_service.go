@@ -7,15 +7,20 @@ import (
 
 	"github.com/emilijan-koteski/monexa/internal/models"
 	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/responses"
 	"gorm.io/gorm"
 )
 
 type SessionService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	revocations *tokenRevocationCache
 }
 
 func NewSessionService(db *gorm.DB) *SessionService {
-	return &SessionService{db: db}
+	return &SessionService{
+		db:          db,
+		revocations: newTokenRevocationCache(TokenRevocationCacheTTL),
+	}
 }
 
 func (s *SessionService) GetSessionByExample(ctx context.Context, example models.Session) (*models.Session, error) {
@@ -72,6 +77,7 @@ func (s *SessionService) RevokeSession(ctx context.Context, req requests.Refresh
 		return errors.New("session not found")
 	}
 
+	s.revocations.clearFamilies()
 	return nil
 }
 
@@ -92,6 +98,7 @@ func (s *SessionService) DeleteSession(ctx context.Context, req requests.Refresh
 		return errors.New("session not found")
 	}
 
+	s.revocations.clearFamilies()
 	return nil
 }
 
@@ -111,6 +118,7 @@ func (s *SessionService) GetUserSessions(ctx context.Context, userID uint) ([]mo
 	var sessions []models.Session
 	err := s.db.WithContext(ctx).
 		Where("user_id = ? AND is_revoked = ? AND expires_at > ?", userID, false, time.Now()).
+		Order("signed_in_at DESC").
 		Find(&sessions).Error
 
 	if err != nil {
@@ -145,14 +153,146 @@ func (s *SessionService) RevokeSessionFamily(ctx context.Context, tokenFamily st
 		Where("token_family = ?", tokenFamily).
 		Update("is_revoked", true)
 
+	s.revocations.clearFamilies()
 	return result.Error
 }
 
+// RevokeAllUserSessions revokes the refresh tokens of the user and every
+// access token issued so far, including ones without a session family.
 func (s *SessionService) RevokeAllUserSessions(ctx context.Context, userID uint) error {
+	// Token timestamps have second precision, so a token issued later in the
+	// same second, such as the one of the sign in after a reset, stays valid.
+	revokedAt := time.Now().Truncate(time.Second)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ?", userID).
+			Update("is_revoked", true).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).
+			Unscoped().
+			Where("id = ?", userID).
+			Update("tokens_revoked_at", revokedAt).Error
+	})
+
+	s.revocations.clearFamilies()
+	s.revocations.clearUser(userID)
+	return err
+}
+
+// IsAccessTokenRevoked reports whether an access token was revoked after it
+// was issued, either with all tokens of its user or by ending its session
+// family through logout or revocation.
+func (s *SessionService) IsAccessTokenRevoked(ctx context.Context, userID uint, tokenFamily string, issuedAt time.Time) (bool, error) {
+	revokedAt, ok := s.revocations.getUser(userID)
+	if !ok {
+		var user models.User
+		if err := s.db.WithContext(ctx).Unscoped().Select("tokens_revoked_at").Where("id = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return true, nil
+			}
+			return false, err
+		}
+		revokedAt = user.TokensRevokedAt
+		s.revocations.setUser(userID, revokedAt)
+	}
+	if revokedAt != nil && issuedAt.Before(*revokedAt) {
+		return true, nil
+	}
+
+	if tokenFamily == "" {
+		return false, nil
+	}
+
+	active, ok := s.revocations.getFamily(tokenFamily)
+	if !ok {
+		var count int64
+		if err := s.db.WithContext(ctx).
+			Model(&models.Session{}).
+			Where("token_family = ? AND user_id = ? AND is_revoked = ? AND expires_at > ?", tokenFamily, userID, false, time.Now()).
+			Count(&count).Error; err != nil {
+			return false, err
+		}
+		active = count > 0
+		s.revocations.setFamily(tokenFamily, active)
+	}
+
+	return !active, nil
+}
+
+// GetActiveSessions lists the signed in devices of a user. Refresh tokens
+// rotate within a family, so each device has one active session at a time.
+// The session of currentFamily is marked as the current one.
+func (s *SessionService) GetActiveSessions(ctx context.Context, userID uint, currentFamily string) ([]responses.SessionResponse, error) {
+	sessions, err := s.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]responses.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, responses.SessionResponse{
+			ID:          session.ID,
+			TokenFamily: session.TokenFamily,
+			DeviceName:  session.DeviceName,
+			UserAgent:   session.UserAgent,
+			IPAddress:   session.IPAddress,
+			SignedInAt:  session.SignedInAt,
+			LastUsedAt:  session.LastUsedAt,
+			ExpiresAt:   session.ExpiresAt,
+			Current:     currentFamily != "" && session.TokenFamily == currentFamily,
+		})
+	}
+
+	return result, nil
+}
+
+// RevokeUserSession revokes one session of the user. Refreshing it afterwards
+// revokes the rest of its family as well.
+func (s *SessionService) RevokeUserSession(ctx context.Context, userID uint, sessionID string) error {
 	result := s.db.WithContext(ctx).
 		Model(&models.Session{}).
-		Where("user_id = ?", userID).
+		Where("id = ? AND user_id = ? AND is_revoked = ?", sessionID, userID, false).
 		Update("is_revoked", true)
 
-	return result.Error
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	s.revocations.clearFamilies()
+	return nil
+}
+
+func (s *SessionService) RevokeUserSessionFamily(ctx context.Context, userID uint, tokenFamily string) error {
+	result := s.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("token_family = ? AND user_id = ? AND is_revoked = ?", tokenFamily, userID, false).
+		Update("is_revoked", true)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	s.revocations.clearFamilies()
+	return nil
+}
+
+// RevokeOtherUserSessions signs the user out of every device except the one
+// holding currentFamily and returns how many sessions were revoked.
+func (s *SessionService) RevokeOtherUserSessions(ctx context.Context, userID uint, currentFamily string) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("user_id = ? AND token_family <> ? AND is_revoked = ?", userID, currentFamily, false).
+		Update("is_revoked", true)
+
+	s.revocations.clearFamilies()
+	return result.RowsAffected, result.Error
 }
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+)
+
+// testSession is models.Session without the Postgres column default, which
+// SQLite cannot parse.
+type testSession struct {
+	ID           string `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    *time.Time
+	UserID       uint   `gorm:"not null;index"`
+	TokenFamily  string `gorm:"not null;index"`
+	RefreshToken string `gorm:"not null"`
+	IsRevoked    bool   `gorm:"not null"`
+	ExpiresAt    time.Time
+	SignedInAt   time.Time
+	LastUsedAt   *time.Time
+	UserAgent    string
+	IPAddress    string
+	DeviceName   string
+}
+
+func (testSession) TableName() string {
+	return "sessions"
+}
+
+func newTestSessionService(t *testing.T) (*SessionService, models.User) {
+	t.Helper()
+
+	db := newTestDB(t, &models.User{}, &testSession{})
+	user := models.User{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return NewSessionService(db), user
+}
+
+func createTestSession(t *testing.T, s *SessionService, userID uint, family string) *models.Session {
+	t.Helper()
+
+	now := time.Now()
+	session, err := s.CreateSessionFromExample(context.Background(), models.Session{
+		ID:           family + "-session",
+		UserID:       userID,
+		TokenFamily:  family,
+		RefreshToken: family + "-refresh-token",
+		ExpiresAt:    now.Add(time.Hour),
+		SignedInAt:   now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+// assertRevoked checks an access token of the family issued at issuedAt. The
+// first check of each test fills the cache, so later ones show that
+// revocations clear it.
+func assertRevoked(t *testing.T, s *SessionService, userID uint, family string, issuedAt time.Time, want bool) {
+	t.Helper()
+
+	revoked, err := s.IsAccessTokenRevoked(context.Background(), userID, family, issuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != want {
+		t.Errorf("IsAccessTokenRevoked() of family %q = %v, want %v", family, revoked, want)
+	}
+}
+
+func TestRevokeAllUserSessionsRevokesIssuedAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSessionService(t)
+	createTestSession(t, s, user.ID, "laptop")
+	issuedAt := time.Now().Add(-time.Minute)
+
+	assertRevoked(t, s, user.ID, "laptop", issuedAt, false)
+	assertRevoked(t, s, user.ID, "", issuedAt, false)
+
+	if err := s.RevokeAllUserSessions(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unexpired tokens issued before are revoked, with or without a family.
+	assertRevoked(t, s, user.ID, "laptop", issuedAt, true)
+	assertRevoked(t, s, user.ID, "", issuedAt, true)
+
+	// The sign in that follows gets tokens that work.
+	createTestSession(t, s, user.ID, "phone")
+	assertRevoked(t, s, user.ID, "phone", time.Now().Add(time.Second), false)
+}
+
+func TestRevokingOneSessionRevokesItsAccessTokens(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSessionService(t)
+	issuedAt := time.Now()
+
+	laptop := createTestSession(t, s, user.ID, "laptop")
+	phone := createTestSession(t, s, user.ID, "phone")
+	tablet := createTestSession(t, s, user.ID, "tablet")
+	createTestSession(t, s, user.ID, "desktop")
+	for _, family := range []string{"laptop", "phone", "tablet", "desktop"} {
+		assertRevoked(t, s, user.ID, family, issuedAt, false)
+	}
+
+	if err := s.RevokeUserSession(ctx, user.ID, laptop.ID); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked(t, s, user.ID, "laptop", issuedAt, true)
+
+	if err := s.RevokeUserSessionFamily(ctx, user.ID, phone.TokenFamily); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked(t, s, user.ID, "phone", issuedAt, true)
+
+	// Logging out deletes the session, which ends its family as well.
+	if err := s.DeleteSession(ctx, requests.RefreshTokenRequest{RefreshToken: tablet.RefreshToken}); err != nil {
+		t.Fatal(err)
+	}
+	assertRevoked(t, s, user.ID, "tablet", issuedAt, true)
+
+	assertRevoked(t, s, user.ID, "desktop", issuedAt, false)
+
+	// A family of another user does not count.
+	assertRevoked(t, s, user.ID+1, "desktop", issuedAt, true)
+}
+
+func TestRevocationsReachOtherInstancesOnceTheCacheExpires(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestSessionService(t)
+	other := &SessionService{db: s.db, revocations: newTokenRevocationCache(50 * time.Millisecond)}
+	createTestSession(t, s, user.ID, "laptop")
+	issuedAt := time.Now().Add(-time.Minute)
+
+	assertRevoked(t, other, user.ID, "laptop", issuedAt, false)
+	if err := s.RevokeAllUserSessions(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other instance answers from its cache until the entry expires.
+	assertRevoked(t, other, user.ID, "laptop", issuedAt, false)
+	time.Sleep(60 * time.Millisecond)
+	assertRevoked(t, other, user.ID, "laptop", issuedAt, true)
+}
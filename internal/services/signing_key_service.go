@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/token"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	SigningKeyReloadInterval = 5 * time.Minute
+	// DefaultSigningKeyActivationDelay gives every instance time to load a
+	// new key, and JWKS clients time to fetch it, before tokens use it.
+	DefaultSigningKeyActivationDelay = 2 * SigningKeyReloadInterval
+	// signingKeyMissReloadInterval limits how often an unknown kid reloads
+	// the keys.
+	signingKeyMissReloadInterval = 10 * time.Second
+	// MinSigningKeyEncryptionKeyLength is the shortest secret the private
+	// keys are encrypted with.
+	MinSigningKeyEncryptionKeyLength = 32
+)
+
+var ErrLastSigningKey = errors.New("cannot retire the only signing key, rotate with -activate-in 0 first")
+
+// SigningKeyService keeps the JWT signing keys in the database so every API
+// instance signs and verifies with the same keys. The keys are cached and
+// reloaded every SigningKeyReloadInterval. Private keys are encrypted with
+// JWT_KEY_ENCRYPTION_KEY, or JWT_SECRET when unset, and the API does not start
+// without one of at least MinSigningKeyEncryptionKeyLength characters.
+type SigningKeyService struct {
+	db            *gorm.DB
+	encryptionKey string
+	algorithm     string
+
+	mu             sync.Mutex
+	keys           []token.SigningKey
+	firstCreatedAt time.Time
+	loadedAt       time.Time
+}
+
+func NewSigningKeyService(db *gorm.DB) *SigningKeyService {
+	key := os.Getenv("JWT_KEY_ENCRYPTION_KEY")
+	if key == "" {
+		key = os.Getenv("JWT_SECRET")
+	}
+	if len(key) < MinSigningKeyEncryptionKeyLength {
+		log.Fatalf("⛔ Exit!!! JWT_KEY_ENCRYPTION_KEY, or JWT_SECRET when unset, must be at least %d characters", MinSigningKeyEncryptionKeyLength)
+	}
+
+	algorithm := os.Getenv("JWT_SIGNING_ALGORITHM")
+	if !token.IsValidSigningAlgorithm(algorithm) {
+		algorithm = token.AlgorithmEdDSA
+	}
+
+	return &SigningKeyService{
+		db:            db,
+		encryptionKey: key,
+		algorithm:     algorithm,
+	}
+}
+
+// EnsureSigningKey creates a key that is active right away when there is no
+// key yet, so a new installation works without running the rotation first.
+func (s *SigningKeyService) EnsureSigningKey(ctx context.Context) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.SigningKey{}).
+		Where("retired_at IS NULL").
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count signing keys: %w", err)
+	}
+
+	if count == 0 {
+		if _, err := s.Create(ctx, s.algorithm, 0); err != nil {
+			return err
+		}
+	}
+
+	return s.reload(ctx)
+}
+
+func (s *SigningKeyService) GetAll(ctx context.Context) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	if err := s.db.WithContext(ctx).Order("activates_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Create adds a key that starts signing after activateIn. An empty algorithm
+// uses JWT_SIGNING_ALGORITHM.
+func (s *SigningKeyService) Create(ctx context.Context, algorithm string, activateIn time.Duration) (*models.SigningKey, error) {
+	if algorithm == "" {
+		algorithm = s.algorithm
+	}
+
+	key, err := token.GenerateSigningKey(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	private, err := key.MarshalPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptString(s.encryptionKey, private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+
+	record := models.SigningKey{
+		Kid:         key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  encrypted,
+		ActivatesAt: time.Now().Add(activateIn),
+	}
+	if err := s.db.WithContext(ctx).Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+
+	return &record, nil
+}
+
+// RetireSupersededKeys retires the keys that stopped signing more than
+// lifetime ago. No token signed with them can still be valid.
+func (s *SigningKeyService) RetireSupersededKeys(ctx context.Context, lifetime time.Duration) (int64, error) {
+	latestExpired := s.db.Model(&models.SigningKey{}).
+		Select("MAX(activates_at)").
+		Where("retired_at IS NULL AND activates_at <= ?", time.Now().Add(-lifetime))
+
+	result := s.db.WithContext(ctx).
+		Model(&models.SigningKey{}).
+		Where("retired_at IS NULL AND activates_at < (?)", latestExpired).
+		Updates(map[string]interface{}{"retired_at": time.Now(), "private_key": ""})
+
+	return result.RowsAffected, result.Error
+}
+
+// Retire stops signing and verifying with a key, for example after it leaked.
+// Tokens signed with it are rejected once the instances reload their keys. It
+// needs another key that already signs, a key that only waits to activate
+// would leave the instances without a key to sign with until it does.
+func (s *SigningKeyService) Retire(ctx context.Context, kid string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var others int64
+		if err := tx.Model(&models.SigningKey{}).
+			Where("kid <> ? AND retired_at IS NULL AND activates_at <= ?", kid, time.Now()).
+			Count(&others).Error; err != nil {
+			return err
+		}
+		if others == 0 {
+			return ErrLastSigningKey
+		}
+
+		result := tx.Model(&models.SigningKey{}).
+			Where("kid = ? AND retired_at IS NULL", kid).
+			Updates(map[string]interface{}{"retired_at": time.Now(), "private_key": ""})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// GetPublicKeys returns the keys that verify tokens, including keys that do
+// not sign yet, for the JWKS endpoint.
+func (s *SigningKeyService) GetPublicKeys() ([]token.JWK, error) {
+	keys, err := s.cachedKeys(SigningKeyReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := make([]token.JWK, 0, len(keys))
+	for i := range keys {
+		jwks = append(jwks, keys[i].PublicJWK())
+	}
+	return jwks, nil
+}
+
+// SigningKey returns the most recently activated key.
+func (s *SigningKeyService) SigningKey() (*token.SigningKey, error) {
+	keys, err := s.cachedKeys(SigningKeyReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range keys {
+		if !keys[i].ActivatesAt.After(now) {
+			return &keys[i], nil
+		}
+	}
+	return nil, token.ErrSigningKeyNotFound
+}
+
+// VerificationKey reloads the keys early for an unknown kid, since another
+// instance may have created the key.
+func (s *SigningKeyService) VerificationKey(kid string) (*token.SigningKey, error) {
+	if kid == "" {
+		return nil, token.ErrSigningKeyNotFound
+	}
+
+	for _, maxAge := range []time.Duration{SigningKeyReloadInterval, signingKeyMissReloadInterval} {
+		keys, err := s.cachedKeys(maxAge)
+		if err != nil {
+			return nil, err
+		}
+		for i := range keys {
+			if keys[i].ID == kid {
+				return &keys[i], nil
+			}
+		}
+	}
+	return nil, token.ErrSigningKeyNotFound
+}
+
+func (s *SigningKeyService) FirstKeyCreatedAt() time.Time {
+	if _, err := s.cachedKeys(SigningKeyReloadInterval); err != nil {
+		return time.Time{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.firstCreatedAt
+}
+
+// cachedKeys returns the keys ordered from the newest activation on, and
+// reloads them when they are older than maxAge. A failed reload keeps the
+// previous keys so a database hiccup does not sign everyone out.
+func (s *SigningKeyService) cachedKeys(maxAge time.Duration) ([]token.SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.loadedAt) < maxAge {
+		return s.keys, nil
+	}
+
+	if err := s.load(context.Background()); err != nil {
+		if s.loadedAt.IsZero() {
+			return nil, err
+		}
+		log.Printf("🛑 Error!!! reloading signing keys failed: %v", err)
+		s.loadedAt = time.Now()
+	}
+	return s.keys, nil
+}
+
+func (s *SigningKeyService) reload(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(ctx)
+}
+
+func (s *SigningKeyService) load(ctx context.Context) error {
+	var records []models.SigningKey
+	if err := s.db.WithContext(ctx).
+		Where("retired_at IS NULL").
+		Order("activates_at DESC").
+		Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	var first models.SigningKey
+	if err := s.db.WithContext(ctx).Order("created_at ASC").First(&first).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to fetch first signing key: %w", err)
+	}
+
+	keys := make([]token.SigningKey, 0, len(records))
+	for _, record := range records {
+		private, err := utils.DecryptString(s.encryptionKey, record.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt signing key %s: %w", record.Kid, err)
+		}
+		key, err := token.ParseSigningKey(record.Kid, record.Algorithm, private, record.ActivatesAt)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, *key)
+	}
+
+	s.keys = keys
+	s.firstCreatedAt = first.CreatedAt
+	s.loadedAt = time.Now()
+	return nil
+}
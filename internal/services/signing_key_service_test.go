@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+)
+
+func TestRetireNeedsAnotherSigningKey(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("JWT_KEY_ENCRYPTION_KEY", "test-encryption-key-that-is-long-enough")
+
+	s := NewSigningKeyService(newTestDB(t, &models.SigningKey{}))
+	active, err := s.Create(ctx, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Retire(ctx, active.Kid); !errors.Is(err, ErrLastSigningKey) {
+		t.Errorf("Retire() of the only key error = %v, want %v", err, ErrLastSigningKey)
+	}
+
+	pending, err := s.Create(ctx, "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Retire(ctx, active.Kid); !errors.Is(err, ErrLastSigningKey) {
+		t.Errorf("Retire() with only a pending key left error = %v, want %v", err, ErrLastSigningKey)
+	}
+
+	if _, err := s.Create(ctx, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Retire(ctx, active.Kid); err != nil {
+		t.Errorf("Retire() with another active key error = %v", err)
+	}
+	if err := s.Retire(ctx, pending.Kid); err != nil {
+		t.Errorf("Retire() of a pending key error = %v", err)
+	}
+}
@@ -0,0 +1,831 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/charts"
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/responses"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"gorm.io/gorm"
+)
+
+// maxStatementTrendCharts caps how many of the user's trend reports are rendered
+// into a single statement.
+const maxStatementTrendCharts = 4
+
+// MaxStatementRecords caps how many records a statement reads, counting those
+// before the period that make up the opening balances. A PDF is rendered as a
+// whole in memory, so larger histories have to be exported as CSV or JSON,
+// which are streamed.
+const MaxStatementRecords = 50000
+
+var ErrStatementTooLarge = fmt.Errorf("statements are limited to %d records, export CSV or JSON instead", MaxStatementRecords)
+
+const (
+	statementFontFamily  = "Go"
+	statementMargin      = 15.0
+	statementRowHeight   = 6.0
+	statementChartWidth  = 1000
+	statementChartHeight = 400
+)
+
+type statementText struct {
+	MonthlyTitle   string
+	AnnualTitle    string
+	RangeTitle     string
+	GeneratedOn    string
+	CurrencyNote   string
+	Summary        string
+	TotalIncome    string
+	TotalExpense   string
+	NetBalance     string
+	Categories     string
+	Category       string
+	Type           string
+	Records        string
+	Amount         string
+	Share          string
+	Income         string
+	Expense        string
+	Trends         string
+	NetByMonth     string
+	NetByDay       string
+	Accounts       string
+	PaymentMethod  string
+	Opening        string
+	Closing        string
+	Total          string
+	Transactions   string
+	Date           string
+	Description    string
+	OriginalAmount string
+	NoTransactions string
+	PageFormat     string
+}
+
+var statementTexts = map[types.LanguageType]statementText{
+	types.EnglishLanguage: {
+		MonthlyTitle:   "Monthly statement",
+		AnnualTitle:    "Annual statement",
+		RangeTitle:     "Financial statement",
+		GeneratedOn:    "Generated on %s",
+		CurrencyNote:   "All amounts in %s",
+		Summary:        "Summary",
+		TotalIncome:    "Total income",
+		TotalExpense:   "Total expenses",
+		NetBalance:     "Net balance",
+		Categories:     "Category breakdown",
+		Category:       "Category",
+		Type:           "Type",
+		Records:        "Records",
+		Amount:         "Amount",
+		Share:          "Share",
+		Income:         "Income",
+		Expense:        "Expense",
+		Trends:         "Trends",
+		NetByMonth:     "Net balance by month",
+		NetByDay:       "Net balance by day",
+		Accounts:       "Account balances",
+		PaymentMethod:  "Payment method",
+		Opening:        "Opening",
+		Closing:        "Closing",
+		Total:          "Total",
+		Transactions:   "Transactions",
+		Date:           "Date",
+		Description:    "Description",
+		OriginalAmount: "Original",
+		NoTransactions: "No transactions in this period.",
+		PageFormat:     "Page %d of {nb}",
+	},
+	types.MacedonianLanguage: {
+		MonthlyTitle:   "Месечен извод",
+		AnnualTitle:    "Годишен извод",
+		RangeTitle:     "Финансиски извод",
+		GeneratedOn:    "Генерирано на %s",
+		CurrencyNote:   "Сите износи се во %s",
+		Summary:        "Преглед",
+		TotalIncome:    "Вкупни приходи",
+		TotalExpense:   "Вкупни расходи",
+		NetBalance:     "Нето салдо",
+		Categories:     "Преглед по категории",
+		Category:       "Категорија",
+		Type:           "Тип",
+		Records:        "Записи",
+		Amount:         "Износ",
+		Share:          "Удел",
+		Income:         "Приход",
+		Expense:        "Расход",
+		Trends:         "Трендови",
+		NetByMonth:     "Нето салдо по месеци",
+		NetByDay:       "Нето салдо по денови",
+		Accounts:       "Салда по сметки",
+		PaymentMethod:  "Начин на плаќање",
+		Opening:        "Почетно",
+		Closing:        "Крајно",
+		Total:          "Вкупно",
+		Transactions:   "Трансакции",
+		Date:           "Датум",
+		Description:    "Опис",
+		OriginalAmount: "Оригинал",
+		NoTransactions: "Нема трансакции во овој период.",
+		PageFormat:     "Страница %d од {nb}",
+	},
+}
+
+var statementMonthNames = map[types.LanguageType][]string{
+	types.EnglishLanguage:    {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	types.MacedonianLanguage: {"Јануари", "Февруари", "Март", "Април", "Мај", "Јуни", "Јули", "Август", "Септември", "Октомври", "Ноември", "Декември"},
+}
+
+var (
+	statementPrimaryColor = [3]int{0x66, 0x69, 0xff}
+	statementTextColor    = [3]int{0x1b, 0x14, 0x3d}
+	statementMutedColor   = [3]int{0x6b, 0x6e, 0x8a}
+	statementIncomeColor  = [3]int{0x1e, 0x9e, 0x62}
+	statementExpenseColor = [3]int{0xd6, 0x3a, 0x4a}
+	statementHeaderFill   = [3]int{0xec, 0xec, 0xff}
+	statementStripeFill   = [3]int{0xf7, 0xf7, 0xfb}
+)
+
+type StatementService struct {
+	db                 *gorm.DB
+	settingService     *SettingService
+	categoryService    *CategoryService
+	currencyService    *CurrencyService
+	trendReportService *TrendReportService
+}
+
+func NewStatementService(db *gorm.DB, settingService *SettingService, categoryService *CategoryService, currencyService *CurrencyService, trendReportService *TrendReportService) *StatementService {
+	return &StatementService{
+		db:                 db,
+		settingService:     settingService,
+		categoryService:    categoryService,
+		currencyService:    currencyService,
+		trendReportService: trendReportService,
+	}
+}
+
+type statementAccount struct {
+	Name    string
+	Opening float64
+	Income  float64
+	Expense float64
+}
+
+func (a statementAccount) closing() float64 {
+	return a.Opening + a.Income - a.Expense
+}
+
+type statementTransaction struct {
+	Date            time.Time
+	Category        string
+	PaymentMethod   string
+	Description     string
+	Amount          float64
+	Currency        types.CurrencyType
+	ConvertedAmount float64
+}
+
+type statementData struct {
+	Lang         types.LanguageType
+	Currency     types.CurrencyType
+	Title        string
+	PeriodLabel  string
+	UserName     string
+	UserEmail    string
+	Statistics   *responses.CategoryStatisticsResponse
+	Accounts     []statementAccount
+	Transactions []statementTransaction
+	NetChart     []byte
+	TrendCharts  [][]byte
+}
+
+// GenerateStatement renders the PDF statement for a calendar month or year.
+func (s *StatementService) GenerateStatement(ctx context.Context, req requests.StatementRequest) ([]byte, error) {
+	if req.UserID == 0 {
+		return nil, errors.New("invalid user id")
+	}
+	if !dtotypes.IsValidStatementPeriod(req.Period) {
+		return nil, errors.New("invalid statement period")
+	}
+	if req.Year <= 0 {
+		return nil, errors.New("invalid year")
+	}
+
+	lang, err := s.getLanguage(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	text := statementTexts[lang]
+
+	var startDate, endDate time.Time
+	var title, periodLabel string
+	switch req.Period {
+	case dtotypes.StatementPeriodMonthly:
+		if req.Month < 1 || req.Month > 12 {
+			return nil, errors.New("invalid month")
+		}
+		startDate = time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC)
+		endDate = startDate.AddDate(0, 1, 0)
+		title = text.MonthlyTitle
+		periodLabel = fmt.Sprintf("%s %d", statementMonthNames[lang][req.Month-1], req.Year)
+	case dtotypes.StatementPeriodAnnual:
+		startDate = time.Date(req.Year, 1, 1, 0, 0, 0, 0, time.UTC)
+		endDate = startDate.AddDate(1, 0, 0)
+		title = text.AnnualTitle
+		periodLabel = strconv.Itoa(req.Year)
+	}
+
+	return s.generate(ctx, req.UserID, lang, title, periodLabel, startDate, endDate)
+}
+
+// GenerateStatementForRange renders the PDF statement for an arbitrary range of
+// days. Both dates are inclusive; a missing start date falls back to the user's
+// first record and a missing end date to today.
+func (s *StatementService) GenerateStatementForRange(ctx context.Context, userID uint, startDate *time.Time, endDate *time.Time) ([]byte, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user id")
+	}
+
+	lang, err := s.getLanguage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	end := today.AddDate(0, 0, 1)
+	if endDate != nil {
+		end = endDate.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	}
+
+	var start time.Time
+	if startDate != nil {
+		start = startDate.UTC().Truncate(24 * time.Hour)
+	} else {
+		var firstDate *time.Time
+		if err := s.db.WithContext(ctx).
+			Model(&models.Record{}).
+			Select("MIN(date)").
+			Where("user_id = ?", userID).
+			Scan(&firstDate).Error; err != nil {
+			return nil, fmt.Errorf("failed to get first record date: %w", err)
+		}
+		start = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if firstDate != nil {
+			start = firstDate.UTC().Truncate(24 * time.Hour)
+		}
+	}
+
+	if !end.After(start) {
+		return nil, errors.New("end date must not be before start date")
+	}
+
+	periodLabel := fmt.Sprintf("%s – %s", formatStatementDate(start, lang), formatStatementDate(end.AddDate(0, 0, -1), lang))
+	return s.generate(ctx, userID, lang, statementTexts[lang].RangeTitle, periodLabel, start, end)
+}
+
+func (s *StatementService) getLanguage(ctx context.Context, userID uint) (types.LanguageType, error) {
+	setting, err := s.settingService.GetByUserID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user settings: %w", err)
+	}
+	if !types.IsValidLanguageType(setting.Language) {
+		return types.EnglishLanguage, nil
+	}
+	return setting.Language, nil
+}
+
+// generate collects the statement data for [startDate, endDate) and renders it.
+func (s *StatementService) generate(ctx context.Context, userID uint, lang types.LanguageType, title, periodLabel string, startDate, endDate time.Time) ([]byte, error) {
+	setting, err := s.settingService.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user settings: %w", err)
+	}
+	userCurrency := setting.Currency
+
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// GetStatistics treats the end date as inclusive.
+	inclusiveEnd := endDate.Add(-time.Nanosecond)
+	statistics, err := s.categoryService.GetStatistics(ctx, requests.CategoryStatisticsRequest{
+		UserID:    &userID,
+		StartDate: &startDate,
+		EndDate:   &inclusiveEnd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statistics: %w", err)
+	}
+
+	categories, err := s.categoryService.GetAllByExample(ctx, models.Category{UserID: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %w", err)
+	}
+	categoryMap := make(map[uint]models.Category, len(categories))
+	for _, category := range categories {
+		categoryMap[category.ID] = category
+	}
+
+	var paymentMethods []models.PaymentMethod
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("name ASC").
+		Find(&paymentMethods).Error; err != nil {
+		return nil, fmt.Errorf("failed to get payment methods: %w", err)
+	}
+
+	// Everything before the period is needed for the opening balances.
+	var recordCount int64
+	if err := s.db.WithContext(ctx).
+		Model(&models.Record{}).
+		Where("user_id = ? AND date < ?", userID, endDate).
+		Count(&recordCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count records: %w", err)
+	}
+	if recordCount > MaxStatementRecords {
+		return nil, ErrStatementTooLarge
+	}
+
+	var records []models.Record
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND date < ?", userID, endDate).
+		Order("date ASC, id ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	converted, err := convertRecords(ctx, s.currencyService, records, userCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[uint]*statementAccount, len(paymentMethods))
+	paymentMethodNames := make(map[uint]string, len(paymentMethods))
+	for _, pm := range paymentMethods {
+		accounts[pm.ID] = &statementAccount{Name: pm.Name}
+		paymentMethodNames[pm.ID] = pm.Name
+	}
+
+	labels, bucketOf := statementBuckets(startDate, endDate, lang)
+	netValues := make([]float64, len(labels))
+
+	var transactions []statementTransaction
+	for _, record := range converted {
+		category, exists := categoryMap[record.CategoryID]
+		if !exists {
+			continue
+		}
+		account := accounts[record.PaymentMethodID]
+
+		signedAmount := record.ConvertedAmount
+		if category.Type == types.Expense {
+			signedAmount = -signedAmount
+		}
+
+		if record.Date.Before(startDate) {
+			if account != nil {
+				account.Opening += signedAmount
+			}
+			continue
+		}
+
+		if account != nil {
+			if category.Type == types.Income {
+				account.Income += record.ConvertedAmount
+			} else {
+				account.Expense += record.ConvertedAmount
+			}
+		}
+		netValues[bucketOf(record.Date)] += signedAmount
+
+		description := ""
+		if record.Description != nil {
+			description = *record.Description
+		}
+		transactions = append(transactions, statementTransaction{
+			Date:            record.Date,
+			Category:        category.Name,
+			PaymentMethod:   paymentMethodNames[record.PaymentMethodID],
+			Description:     description,
+			Amount:          record.Amount,
+			Currency:        record.Currency,
+			ConvertedAmount: signedAmount,
+		})
+	}
+
+	text := statementTexts[lang]
+	netTitle := text.NetByMonth
+	if endDate.Sub(startDate) <= 31*24*time.Hour {
+		netTitle = text.NetByDay
+	}
+	netChart, err := charts.RenderPNG(charts.Chart{
+		Title:       netTitle,
+		Subtitle:    periodLabel,
+		Labels:      labels,
+		Values:      netValues,
+		Color:       defaultColor,
+		Kind:        charts.KindBar,
+		FormatValue: chartAmountFormatter(lang),
+	}, charts.Options{Width: statementChartWidth, Height: statementChartHeight, Theme: charts.ThemeLight})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render net balance chart: %w", err)
+	}
+
+	trendCharts, err := s.renderTrendCharts(ctx, userID, endDate.AddDate(0, 0, -1).Year())
+	if err != nil {
+		return nil, err
+	}
+
+	data := statementData{
+		Lang:         lang,
+		Currency:     userCurrency,
+		Title:        title,
+		PeriodLabel:  periodLabel,
+		UserName:     user.Name,
+		UserEmail:    user.Email,
+		Statistics:   statistics,
+		Transactions: transactions,
+		NetChart:     netChart,
+		TrendCharts:  trendCharts,
+	}
+	for _, pm := range paymentMethods {
+		data.Accounts = append(data.Accounts, *accounts[pm.ID])
+	}
+
+	return buildStatementPDF(data)
+}
+
+func (s *StatementService) renderTrendCharts(ctx context.Context, userID uint, year int) ([][]byte, error) {
+	reports, err := s.trendReportService.GetAll(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trend reports: %w", err)
+	}
+	if len(reports) > maxStatementTrendCharts {
+		reports = reports[:maxStatementTrendCharts]
+	}
+
+	images := make([][]byte, 0, len(reports))
+	for _, report := range reports {
+		image, err := s.trendReportService.RenderChart(ctx, requests.TrendReportChartRequest{
+			ReportID: report.ID,
+			UserID:   userID,
+			Year:     year,
+			Format:   charts.FormatPNG,
+			Kind:     charts.KindLine,
+			Width:    statementChartWidth,
+			Height:   statementChartHeight,
+			Theme:    charts.ThemeLight,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to render trend report chart #%d: %w", report.ID, err)
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// statementBuckets splits [startDate, endDate) into daily buckets for ranges of
+// up to a month and into monthly buckets otherwise. It returns the bucket labels
+// and a function mapping a date onto its bucket index.
+func statementBuckets(startDate, endDate time.Time, lang types.LanguageType) ([]string, func(time.Time) int) {
+	if endDate.Sub(startDate) <= 31*24*time.Hour {
+		var labels []string
+		for d := startDate; d.Before(endDate); d = d.AddDate(0, 0, 1) {
+			labels = append(labels, strconv.Itoa(d.Day()))
+		}
+		return labels, func(date time.Time) int {
+			index := int(date.Sub(startDate) / (24 * time.Hour))
+			return min(max(index, 0), len(labels)-1)
+		}
+	}
+
+	first := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	multipleYears := startDate.Year() != endDate.Add(-time.Nanosecond).Year()
+
+	var labels []string
+	for d := first; d.Before(endDate); d = d.AddDate(0, 1, 0) {
+		label := chartMonthLabels[lang][d.Month()-1]
+		if multipleYears {
+			label = fmt.Sprintf("%s %02d", label, d.Year()%100)
+		}
+		labels = append(labels, label)
+	}
+	return labels, func(date time.Time) int {
+		index := (date.Year()-first.Year())*12 + int(date.Month()-first.Month())
+		return min(max(index, 0), len(labels)-1)
+	}
+}
+
+func formatStatementDate(date time.Time, lang types.LanguageType) string {
+	if lang == types.MacedonianLanguage {
+		return date.Format("02.01.2006")
+	}
+	return date.Format("2006-01-02")
+}
+
+func formatStatementAmount(amount float64, currency types.CurrencyType, lang types.LanguageType) string {
+	return utils.FormatNumber(amount, 2, lang) + " " + string(currency)
+}
+
+type statementColumn struct {
+	Header string
+	Width  float64
+	Align  string
+}
+
+type statementRow struct {
+	Cells []string
+	Bold  bool
+}
+
+func buildStatementPDF(data statementData) ([]byte, error) {
+	text := statementTexts[data.Lang]
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(statementMargin, statementMargin, statementMargin)
+	pdf.SetAutoPageBreak(true, statementMargin+5)
+	pdf.SetTitle(fmt.Sprintf("%s · %s", data.Title, data.PeriodLabel), true)
+	pdf.SetCreator("Monexa", true)
+
+	// The Go fonts cover Latin and Cyrillic and are embedded into the document,
+	// so Macedonian statements render the same on every viewer.
+	pdf.AddUTF8FontFromBytes(statementFontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(statementFontFamily, "B", gobold.TTF)
+
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-statementMargin)
+		pdf.SetFont(statementFontFamily, "", 8)
+		setStatementTextColor(pdf, statementMutedColor)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Monexa · %s · %s", data.Title, data.PeriodLabel), "", 0, "L", false, 0, "")
+		pdf.SetX(statementMargin)
+		pdf.CellFormat(0, 5, fmt.Sprintf(text.PageFormat, pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+
+	pdf.AddPage()
+	contentWidth := statementContentWidth(pdf)
+
+	// Header
+	pdf.SetFont(statementFontFamily, "B", 20)
+	setStatementTextColor(pdf, statementTextColor)
+	pdf.CellFormat(0, 10, data.Title, "", 1, "L", false, 0, "")
+	pdf.SetFont(statementFontFamily, "", 13)
+	setStatementTextColor(pdf, statementPrimaryColor)
+	pdf.CellFormat(0, 7, data.PeriodLabel, "", 1, "L", false, 0, "")
+	pdf.SetFont(statementFontFamily, "", 9)
+	setStatementTextColor(pdf, statementMutedColor)
+	pdf.CellFormat(0, 5, fmt.Sprintf("%s · %s", data.UserName, data.UserEmail), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, fmt.Sprintf("%s · %s",
+		fmt.Sprintf(text.CurrencyNote, data.Currency),
+		fmt.Sprintf(text.GeneratedOn, formatStatementDate(time.Now(), data.Lang))), "", 1, "L", false, 0, "")
+
+	// Summary
+	drawStatementHeading(pdf, text.Summary)
+	boxWidth := (contentWidth - 2*4) / 3
+	top := pdf.GetY()
+	summary := []struct {
+		label  string
+		amount float64
+		color  [3]int
+	}{
+		{text.TotalIncome, data.Statistics.TotalIncome, statementIncomeColor},
+		{text.TotalExpense, data.Statistics.TotalExpense, statementExpenseColor},
+		{text.NetBalance, data.Statistics.NetBalance, statementPrimaryColor},
+	}
+	for i, item := range summary {
+		x := statementMargin + float64(i)*(boxWidth+4)
+		setStatementFillColor(pdf, statementStripeFill)
+		pdf.Rect(x, top, boxWidth, 18, "F")
+		pdf.SetXY(x+3, top+2)
+		pdf.SetFont(statementFontFamily, "", 9)
+		setStatementTextColor(pdf, statementMutedColor)
+		pdf.CellFormat(boxWidth-6, 5, item.label, "", 2, "L", false, 0, "")
+		pdf.SetFont(statementFontFamily, "B", 13)
+		setStatementTextColor(pdf, item.color)
+		pdf.CellFormat(boxWidth-6, 8, formatStatementAmount(item.amount, data.Currency, data.Lang), "", 0, "L", false, 0, "")
+	}
+	pdf.SetXY(statementMargin, top+18)
+
+	// Category breakdown
+	drawStatementHeading(pdf, text.Categories)
+	if len(data.Statistics.Categories) == 0 {
+		drawStatementNote(pdf, text.NoTransactions)
+	} else {
+		rows := make([]statementRow, 0, len(data.Statistics.Categories))
+		for _, item := range data.Statistics.Categories {
+			typeLabel, typeTotal := text.Expense, data.Statistics.TotalExpense
+			if item.CategoryType == types.Income {
+				typeLabel, typeTotal = text.Income, data.Statistics.TotalIncome
+			}
+			share := 0.0
+			if typeTotal != 0 {
+				share = item.TotalAmount / typeTotal * 100
+			}
+			rows = append(rows, statementRow{Cells: []string{
+				item.CategoryName,
+				typeLabel,
+				strconv.Itoa(item.RecordCount),
+				formatStatementAmount(item.TotalAmount, data.Currency, data.Lang),
+				utils.FormatNumber(share, 1, data.Lang) + " %",
+			}})
+		}
+		drawStatementTable(pdf, []statementColumn{
+			{Header: text.Category, Width: 62, Align: "L"},
+			{Header: text.Type, Width: 28, Align: "L"},
+			{Header: text.Records, Width: 22, Align: "R"},
+			{Header: text.Amount, Width: 44, Align: "R"},
+			{Header: text.Share, Width: 24, Align: "R"},
+		}, rows)
+	}
+
+	// Charts
+	drawStatementHeading(pdf, text.Trends)
+	for i, image := range append([][]byte{data.NetChart}, data.TrendCharts...) {
+		name := fmt.Sprintf("chart-%d", i)
+		options := fpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(image))
+
+		height := contentWidth * statementChartHeight / statementChartWidth
+		ensureStatementSpace(pdf, height+4)
+		pdf.ImageOptions(name, statementMargin, pdf.GetY(), contentWidth, height, false, options, 0, "")
+		pdf.SetY(pdf.GetY() + height + 4)
+	}
+
+	// Account balances
+	drawStatementHeading(pdf, text.Accounts)
+	if len(data.Accounts) == 0 {
+		drawStatementNote(pdf, text.NoTransactions)
+	} else {
+		var total statementAccount
+		rows := make([]statementRow, 0, len(data.Accounts)+1)
+		for _, account := range data.Accounts {
+			total.Opening += account.Opening
+			total.Income += account.Income
+			total.Expense += account.Expense
+			rows = append(rows, statementAccountRow(account.Name, account, data))
+		}
+		totalRow := statementAccountRow(text.Total, total, data)
+		totalRow.Bold = true
+		rows = append(rows, totalRow)
+
+		drawStatementTable(pdf, []statementColumn{
+			{Header: text.PaymentMethod, Width: 44, Align: "L"},
+			{Header: text.Opening, Width: 34, Align: "R"},
+			{Header: text.Income, Width: 34, Align: "R"},
+			{Header: text.Expense, Width: 34, Align: "R"},
+			{Header: text.Closing, Width: 34, Align: "R"},
+		}, rows)
+	}
+
+	// Transactions
+	drawStatementHeading(pdf, text.Transactions)
+	if len(data.Transactions) == 0 {
+		drawStatementNote(pdf, text.NoTransactions)
+	} else {
+		rows := make([]statementRow, 0, len(data.Transactions))
+		for _, t := range data.Transactions {
+			rows = append(rows, statementRow{Cells: []string{
+				formatStatementDate(t.Date, data.Lang),
+				t.Category,
+				t.PaymentMethod,
+				t.Description,
+				formatStatementAmount(t.Amount, t.Currency, data.Lang),
+				formatStatementAmount(t.ConvertedAmount, data.Currency, data.Lang),
+			}})
+		}
+		drawStatementTable(pdf, []statementColumn{
+			{Header: text.Date, Width: 21, Align: "L"},
+			{Header: text.Category, Width: 31, Align: "L"},
+			{Header: text.PaymentMethod, Width: 29, Align: "L"},
+			{Header: text.Description, Width: 43, Align: "L"},
+			{Header: text.OriginalAmount, Width: 28, Align: "R"},
+			{Header: text.Amount, Width: 28, Align: "R"},
+		}, rows)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write statement pdf: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func statementAccountRow(name string, account statementAccount, data statementData) statementRow {
+	return statementRow{Cells: []string{
+		name,
+		formatStatementAmount(account.Opening, data.Currency, data.Lang),
+		formatStatementAmount(account.Income, data.Currency, data.Lang),
+		formatStatementAmount(account.Expense, data.Currency, data.Lang),
+		formatStatementAmount(account.closing(), data.Currency, data.Lang),
+	}}
+}
+
+func drawStatementHeading(pdf *fpdf.Fpdf, heading string) {
+	ensureStatementSpace(pdf, 22)
+	pdf.Ln(6)
+	pdf.SetFont(statementFontFamily, "B", 13)
+	setStatementTextColor(pdf, statementTextColor)
+	pdf.CellFormat(0, 7, heading, "", 1, "L", false, 0, "")
+	setStatementDrawColor(pdf, statementPrimaryColor)
+	pdf.SetLineWidth(0.4)
+	pdf.Line(statementMargin, pdf.GetY(), statementMargin+statementContentWidth(pdf), pdf.GetY())
+	pdf.Ln(3)
+}
+
+func drawStatementNote(pdf *fpdf.Fpdf, note string) {
+	pdf.SetFont(statementFontFamily, "", 9)
+	setStatementTextColor(pdf, statementMutedColor)
+	pdf.CellFormat(0, statementRowHeight, note, "", 1, "L", false, 0, "")
+}
+
+// drawStatementTable draws a table and repeats its header row on every page the
+// table spills onto. Cells that do not fit their column are truncated.
+func drawStatementTable(pdf *fpdf.Fpdf, columns []statementColumn, rows []statementRow) {
+	drawHeader := func() {
+		pdf.SetFont(statementFontFamily, "B", 8)
+		setStatementTextColor(pdf, statementTextColor)
+		setStatementFillColor(pdf, statementHeaderFill)
+		for _, column := range columns {
+			pdf.CellFormat(column.Width, statementRowHeight+1, fitStatementText(pdf, column.Header, column.Width), "", 0, column.Align, true, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	ensureStatementSpace(pdf, 2*statementRowHeight+1)
+	drawHeader()
+
+	for i, row := range rows {
+		if ensureStatementSpace(pdf, statementRowHeight) {
+			drawHeader()
+		}
+
+		style := ""
+		if row.Bold {
+			style = "B"
+		}
+		pdf.SetFont(statementFontFamily, style, 8)
+		setStatementTextColor(pdf, statementTextColor)
+		setStatementFillColor(pdf, statementStripeFill)
+		for j, column := range columns {
+			pdf.CellFormat(column.Width, statementRowHeight, fitStatementText(pdf, row.Cells[j], column.Width), "", 0, column.Align, i%2 == 1, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+// ensureStatementSpace starts a new page when less than height is left above the
+// bottom margin and reports whether it did.
+func ensureStatementSpace(pdf *fpdf.Fpdf, height float64) bool {
+	_, pageHeight := pdf.GetPageSize()
+	_, bottomMargin := pdf.GetAutoPageBreak()
+	if pdf.GetY()+height <= pageHeight-bottomMargin {
+		return false
+	}
+	pdf.AddPage()
+	return true
+}
+
+func fitStatementText(pdf *fpdf.Fpdf, value string, width float64) string {
+	available := width - 2*pdf.GetCellMargin()
+	if pdf.GetStringWidth(value) <= available {
+		return value
+	}
+	runes := []rune(value)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > available {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func statementContentWidth(pdf *fpdf.Fpdf) float64 {
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	return pageWidth - left - right
+}
+
+func setStatementTextColor(pdf *fpdf.Fpdf, c [3]int) {
+	pdf.SetTextColor(c[0], c[1], c[2])
+}
+
+func setStatementFillColor(pdf *fpdf.Fpdf, c [3]int) {
+	pdf.SetFillColor(c[0], c[1], c[2])
+}
+
+func setStatementDrawColor(pdf *fpdf.Fpdf, c [3]int) {
+	pdf.SetDrawColor(c[0], c[1], c[2])
+}
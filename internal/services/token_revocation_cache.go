@@ -0,0 +1,109 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	TokenRevocationCacheTTL     = 30 * time.Second
+	tokenRevocationCacheMaxSize = 10000
+)
+
+// tokenRevocationCache remembers for a short time which session families are
+// active and since when the tokens of a user are revoked, so AuthMiddleware
+// does not query the database on every request. Revocations made by this
+// process clear the affected entries right away, other instances see them
+// once the entries expire.
+type tokenRevocationCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	families map[string]cachedFamily
+	users    map[uint]cachedUserRevocation
+}
+
+type cachedFamily struct {
+	active    bool
+	expiresAt time.Time
+}
+
+type cachedUserRevocation struct {
+	revokedAt *time.Time
+	expiresAt time.Time
+}
+
+func newTokenRevocationCache(ttl time.Duration) *tokenRevocationCache {
+	return &tokenRevocationCache{
+		ttl:      ttl,
+		families: map[string]cachedFamily{},
+		users:    map[uint]cachedUserRevocation{},
+	}
+}
+
+func (c *tokenRevocationCache) getFamily(family string) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.families[family]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return false, false
+	}
+	return entry.active, true
+}
+
+func (c *tokenRevocationCache) setFamily(family string, active bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.families) >= tokenRevocationCacheMaxSize {
+		for key, entry := range c.families {
+			if now.After(entry.expiresAt) {
+				delete(c.families, key)
+			}
+		}
+	}
+	c.families[family] = cachedFamily{active: active, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *tokenRevocationCache) getUser(userID uint) (*time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.users[userID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.revokedAt, true
+}
+
+func (c *tokenRevocationCache) setUser(userID uint, revokedAt *time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.users) >= tokenRevocationCacheMaxSize {
+		for key, entry := range c.users {
+			if now.After(entry.expiresAt) {
+				delete(c.users, key)
+			}
+		}
+	}
+	c.users[userID] = cachedUserRevocation{revokedAt: revokedAt, expiresAt: now.Add(c.ttl)}
+}
+
+// clearFamilies drops all family entries. Revocations by refresh token or
+// user do not know the affected families, and the entries refill cheaply.
+func (c *tokenRevocationCache) clearFamilies() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.families = map[string]cachedFamily{}
+}
+
+func (c *tokenRevocationCache) clearUser(userID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.users, userID)
+}
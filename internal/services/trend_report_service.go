@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/emilijan-koteski/monexa/internal/charts"
+	dtotypes "github.com/emilijan-koteski/monexa/internal/dtos/types"
 	"github.com/emilijan-koteski/monexa/internal/models"
 	"github.com/emilijan-koteski/monexa/internal/models/types"
 	"github.com/emilijan-koteski/monexa/internal/requests"
@@ -18,6 +22,23 @@ import (
 
 const defaultColor = "#6669ff"
 
+const MaxComparisonPeriods = 5
+
+var chartMonthLabels = map[types.LanguageType][]string{
+	types.EnglishLanguage:    {"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	types.MacedonianLanguage: {"Јан", "Фев", "Мар", "Апр", "Мај", "Јун", "Јул", "Авг", "Сеп", "Окт", "Ное", "Дек"},
+}
+
+var chartDefaultTitles = map[types.LanguageType]string{
+	types.EnglishLanguage:    "Trend report",
+	types.MacedonianLanguage: "Трендовски извештај",
+}
+
+var chartSubtitleFormats = map[types.LanguageType]string{
+	types.EnglishLanguage:    "%d · Amounts in %s",
+	types.MacedonianLanguage: "%d · Износи во %s",
+}
+
 type TrendReportService struct {
 	db              *gorm.DB
 	settingService  *SettingService
@@ -37,6 +58,7 @@ func (s *TrendReportService) GetAll(ctx context.Context, userID uint) ([]models.
 	if err := s.db.WithContext(ctx).
 		Where("user_id = ?", userID).
 		Preload("Categories").
+		Preload("PaymentMethods").
 		Find(&reports).Error; err != nil {
 		return nil, err
 	}
@@ -48,6 +70,7 @@ func (s *TrendReportService) GetByID(ctx context.Context, reportID uint) (*model
 	if err := s.db.WithContext(ctx).
 		Where("id = ?", reportID).
 		Preload("Categories").
+		Preload("PaymentMethods").
 		First(&report).Error; err != nil {
 		return nil, err
 	}
@@ -67,14 +90,27 @@ func (s *TrendReportService) Create(ctx context.Context, req requests.TrendRepor
 		return nil, err
 	}
 
+	if err := validateAmountRange(req.MinAmount, req.MaxAmount); err != nil {
+		return nil, err
+	}
+
+	paymentMethods, err := s.loadPaymentMethods(ctx, *req.UserID, req.PaymentMethodIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	color := resolveColor(req.Color, categories)
 
 	report := models.TrendReport{
-		UserID:      *req.UserID,
-		Title:       utils.NilIfEmpty(req.Title),
-		Description: utils.NilIfEmpty(req.Description),
-		Color:       &color,
-		Categories:  categories,
+		UserID:         *req.UserID,
+		Title:          utils.NilIfEmpty(req.Title),
+		Description:    utils.NilIfEmpty(req.Description),
+		Color:          &color,
+		Search:         utils.NilIfEmpty(req.Search),
+		MinAmount:      req.MinAmount,
+		MaxAmount:      req.MaxAmount,
+		Categories:     categories,
+		PaymentMethods: paymentMethods,
 	}
 
 	if err := s.db.WithContext(ctx).Create(&report).Error; err != nil {
@@ -105,11 +141,23 @@ func (s *TrendReportService) Update(ctx context.Context, req requests.TrendRepor
 		return nil, err
 	}
 
+	if err := validateAmountRange(req.MinAmount, req.MaxAmount); err != nil {
+		return nil, err
+	}
+
+	paymentMethods, err := s.loadPaymentMethods(ctx, *req.UserID, req.PaymentMethodIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	color := resolveColor(req.Color, categories)
 
 	report.Title = utils.NilIfEmpty(req.Title)
 	report.Description = utils.NilIfEmpty(req.Description)
 	report.Color = &color
+	report.Search = utils.NilIfEmpty(req.Search)
+	report.MinAmount = req.MinAmount
+	report.MaxAmount = req.MaxAmount
 
 	tx := s.db.WithContext(ctx).Begin()
 
@@ -129,11 +177,17 @@ func (s *TrendReportService) Update(ctx context.Context, req requests.TrendRepor
 		return nil, fmt.Errorf("failed to update categories: %w", err)
 	}
 
+	if err := tx.Model(report).Association("PaymentMethods").Replace(paymentMethods); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update payment methods: %w", err)
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, fmt.Errorf("failed to commit update: %w", err)
 	}
 
 	report.Categories = categories
+	report.PaymentMethods = paymentMethods
 	return report, nil
 }
 
@@ -175,78 +229,34 @@ func (s *TrendReportService) GetMonthlyData(ctx context.Context, req requests.Tr
 		return emptyMonthlyData(userCurrency, req.Year), nil
 	}
 
-	categoryIDs := make([]uint, 0, len(report.Categories))
-	categoryTypeMap := make(map[uint]types.CategoryType, len(report.Categories))
-	hasIncome := false
-	hasExpense := false
-
-	for _, cat := range report.Categories {
-		if req.Type != nil && cat.Type != *req.Type {
-			continue
-		}
-		categoryIDs = append(categoryIDs, cat.ID)
-		categoryTypeMap[cat.ID] = cat.Type
-		if cat.Type == types.Income {
-			hasIncome = true
-		} else {
-			hasExpense = true
-		}
-	}
-
-	if len(categoryIDs) == 0 {
+	reportCategories := filterReportCategories(report.Categories, req.Type)
+	if len(reportCategories) == 0 {
 		return emptyMonthlyData(userCurrency, req.Year), nil
 	}
 
+	hasIncome, hasExpense := categoryTypePresence(reportCategories)
+
 	startDate := time.Date(req.Year, 1, 1, 0, 0, 0, 0, time.UTC)
 	endDate := time.Date(req.Year+1, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	var records []models.Record
-	if err := s.db.WithContext(ctx).
-		Where("user_id = ? AND category_id IN ? AND date >= ? AND date < ?", req.UserID, categoryIDs, startDate, endDate).
-		Find(&records).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch records: %w", err)
+	records, err := s.fetchConvertedRecords(ctx, req.UserID, report, reportCategories, startDate, endDate, userCurrency)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(records) == 0 {
 		return emptyMonthlyData(userCurrency, req.Year), nil
 	}
 
-	var historicalRates map[string]float64
-	for _, record := range records {
-		if record.Currency != userCurrency {
-			historicalRates, err = s.currencyService.GetHistoricalRatesForRecords(ctx, records, userCurrency)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get historical exchange rates: %w", err)
-			}
-			break
-		}
-	}
-
 	monthIncome := make(map[int]float64)
 	monthExpense := make(map[int]float64)
 
 	for _, record := range records {
-		var convertedAmount float64
-		if record.Currency != userCurrency {
-			rateKey := fmt.Sprintf("%s_%s_%s",
-				record.Date.Format("2006-01-02"),
-				record.Currency,
-				userCurrency)
-			rate, exists := historicalRates[rateKey]
-			if !exists {
-				return nil, fmt.Errorf("no rate found for record #%d on %s", record.ID, record.Date.Format("2006-01-02"))
-			}
-			convertedAmount = record.Amount * rate
-		} else {
-			convertedAmount = record.Amount
-		}
-
 		month := int(record.Date.Month())
-		catType := categoryTypeMap[record.CategoryID]
-		if catType == types.Income {
-			monthIncome[month] += convertedAmount
+		if reportCategories[record.CategoryID].Type == types.Income {
+			monthIncome[month] += record.ConvertedAmount
 		} else {
-			monthExpense[month] += convertedAmount
+			monthExpense[month] += record.ConvertedAmount
 		}
 	}
 
@@ -255,17 +265,7 @@ func (s *TrendReportService) GetMonthlyData(ctx context.Context, req requests.Tr
 		income := monthIncome[i]
 		expense := monthExpense[i]
 
-		var amount float64
-		switch {
-		case hasIncome && hasExpense:
-			amount = income - expense
-		case hasIncome:
-			amount = income
-		default:
-			amount = expense
-		}
-
-		data[i-1] = responses.MonthlyDataPoint{Month: i, Amount: amount}
+		data[i-1] = responses.MonthlyDataPoint{Month: i, Amount: bucketAmount(income, expense, hasIncome, hasExpense)}
 	}
 
 	return &responses.TrendReportMonthlyDataResponse{
@@ -295,46 +295,23 @@ func (s *TrendReportService) GetMonthlyDetails(ctx context.Context, req requests
 		return emptyMonthlyDetails(userCurrency, req.Year), nil
 	}
 
-	categoryIDs := make([]uint, 0, len(report.Categories))
-	categoryNameMap := make(map[uint]string, len(report.Categories))
-
-	for _, cat := range report.Categories {
-		if req.Type != nil && cat.Type != *req.Type {
-			continue
-		}
-		categoryIDs = append(categoryIDs, cat.ID)
-		categoryNameMap[cat.ID] = cat.Name
-	}
-
-	if len(categoryIDs) == 0 {
+	reportCategories := filterReportCategories(report.Categories, req.Type)
+	if len(reportCategories) == 0 {
 		return emptyMonthlyDetails(userCurrency, req.Year), nil
 	}
 
 	startDate := time.Date(req.Year, 1, 1, 0, 0, 0, 0, time.UTC)
 	endDate := time.Date(req.Year+1, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	var records []models.Record
-	if err := s.db.WithContext(ctx).
-		Where("user_id = ? AND category_id IN ? AND date >= ? AND date < ?", req.UserID, categoryIDs, startDate, endDate).
-		Find(&records).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch records: %w", err)
+	records, err := s.fetchConvertedRecords(ctx, req.UserID, report, reportCategories, startDate, endDate, userCurrency)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(records) == 0 {
 		return emptyMonthlyDetails(userCurrency, req.Year), nil
 	}
 
-	var historicalRates map[string]float64
-	for _, record := range records {
-		if record.Currency != userCurrency {
-			historicalRates, err = s.currencyService.GetHistoricalRatesForRecords(ctx, records, userCurrency)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get historical exchange rates: %w", err)
-			}
-			break
-		}
-	}
-
 	type groupKey struct {
 		Month      int
 		CategoryID uint
@@ -343,21 +320,6 @@ func (s *TrendReportService) GetMonthlyDetails(ctx context.Context, req requests
 	groupAmounts := make(map[groupKey]float64)
 
 	for _, record := range records {
-		var convertedAmount float64
-		if record.Currency != userCurrency {
-			rateKey := fmt.Sprintf("%s_%s_%s",
-				record.Date.Format("2006-01-02"),
-				record.Currency,
-				userCurrency)
-			rate, exists := historicalRates[rateKey]
-			if !exists {
-				return nil, fmt.Errorf("no rate found for record #%d on %s", record.ID, record.Date.Format("2006-01-02"))
-			}
-			convertedAmount = record.Amount * rate
-		} else {
-			convertedAmount = record.Amount
-		}
-
 		desc := ""
 		if record.Description != nil {
 			desc = strings.TrimSpace(*record.Description)
@@ -368,7 +330,7 @@ func (s *TrendReportService) GetMonthlyDetails(ctx context.Context, req requests
 			CategoryID: record.CategoryID,
 			Desc:       desc,
 		}
-		groupAmounts[key] += convertedAmount
+		groupAmounts[key] += record.ConvertedAmount
 	}
 
 	monthItems := make(map[int][]responses.MonthlyDetailItem)
@@ -377,7 +339,7 @@ func (s *TrendReportService) GetMonthlyDetails(ctx context.Context, req requests
 		label := key.Desc
 		item := responses.MonthlyDetailItem{
 			Label:        label,
-			CategoryName: categoryNameMap[key.CategoryID],
+			CategoryName: reportCategories[key.CategoryID].Name,
 			CategoryID:   key.CategoryID,
 			Amount:       amount,
 			IsUngrouped:  isUngrouped,
@@ -407,6 +369,295 @@ func (s *TrendReportService) GetMonthlyDetails(ctx context.Context, req requests
 	}, nil
 }
 
+func (s *TrendReportService) GetComparison(ctx context.Context, req requests.TrendReportComparisonRequest) (*responses.TrendReportComparisonResponse, error) {
+	if req.Year <= 0 {
+		return nil, errors.New("invalid year")
+	}
+	if req.StartMonth < 1 || req.EndMonth > 12 || req.StartMonth > req.EndMonth {
+		return nil, errors.New("invalid month range")
+	}
+	if req.Periods < 1 || req.Periods > MaxComparisonPeriods {
+		return nil, fmt.Errorf("periods must be between 1 and %d", MaxComparisonPeriods)
+	}
+	if !dtotypes.IsValidTrendComparisonMode(req.Mode) {
+		return nil, errors.New("invalid comparison mode")
+	}
+
+	setting, err := s.settingService.GetByUserID(ctx, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user settings: %w", err)
+	}
+	userCurrency := setting.Currency
+
+	report, err := s.GetByID(ctx, req.ReportID)
+	if err != nil {
+		return nil, err
+	}
+
+	reportCategories := filterReportCategories(report.Categories, req.Type)
+	hasIncome, hasExpense := categoryTypePresence(reportCategories)
+
+	// Build the current period first, then every previous period by shifting
+	// its start either by whole years or by whole period lengths.
+	monthCount := req.EndMonth - req.StartMonth + 1
+	currentStart := time.Date(req.Year, time.Month(req.StartMonth), 1, 0, 0, 0, 0, time.UTC)
+
+	periodStarts := make([]time.Time, 0, req.Periods+1)
+	periodStarts = append(periodStarts, currentStart)
+	for i := 1; i <= req.Periods; i++ {
+		if req.Mode == dtotypes.TrendComparisonYearOverYear {
+			periodStarts = append(periodStarts, currentStart.AddDate(-i, 0, 0))
+		} else {
+			periodStarts = append(periodStarts, currentStart.AddDate(0, -i*monthCount, 0))
+		}
+	}
+
+	earliestStart := periodStarts[len(periodStarts)-1]
+	latestEnd := currentStart.AddDate(0, monthCount, 0)
+
+	var records []convertedRecord
+	if len(reportCategories) > 0 {
+		records, err = s.fetchConvertedRecords(ctx, req.UserID, report, reportCategories, earliestStart, latestEnd, userCurrency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type monthKey struct {
+		Year  int
+		Month time.Month
+	}
+	type monthCategoryKey struct {
+		monthKey
+		CategoryID uint
+	}
+	monthIncome := make(map[monthKey]float64)
+	monthExpense := make(map[monthKey]float64)
+	monthCategory := make(map[monthCategoryKey]float64)
+
+	for _, record := range records {
+		key := monthKey{Year: record.Date.Year(), Month: record.Date.Month()}
+		if reportCategories[record.CategoryID].Type == types.Income {
+			monthIncome[key] += record.ConvertedAmount
+		} else {
+			monthExpense[key] += record.ConvertedAmount
+		}
+		monthCategory[monthCategoryKey{monthKey: key, CategoryID: record.CategoryID}] += record.ConvertedAmount
+	}
+
+	sortedCategories := make([]models.Category, 0, len(reportCategories))
+	for _, cat := range reportCategories {
+		sortedCategories = append(sortedCategories, cat)
+	}
+	sort.Slice(sortedCategories, func(i, j int) bool {
+		return sortedCategories[i].ID < sortedCategories[j].ID
+	})
+
+	buildPeriod := func(start time.Time) responses.ComparisonPeriod {
+		period := responses.ComparisonPeriod{
+			StartDate:  start,
+			EndDate:    start.AddDate(0, monthCount, -1),
+			Buckets:    make([]responses.ComparisonBucket, monthCount),
+			Categories: make([]responses.ComparisonCategoryAmount, len(sortedCategories)),
+		}
+
+		var totalIncome, totalExpense float64
+		categoryTotals := make(map[uint]float64, len(sortedCategories))
+
+		for i := 0; i < monthCount; i++ {
+			month := start.AddDate(0, i, 0)
+			key := monthKey{Year: month.Year(), Month: month.Month()}
+			income := monthIncome[key]
+			expense := monthExpense[key]
+			totalIncome += income
+			totalExpense += expense
+
+			period.Buckets[i] = responses.ComparisonBucket{
+				Year:   key.Year,
+				Month:  int(key.Month),
+				Amount: bucketAmount(income, expense, hasIncome, hasExpense),
+			}
+
+			for _, cat := range sortedCategories {
+				categoryTotals[cat.ID] += monthCategory[monthCategoryKey{monthKey: key, CategoryID: cat.ID}]
+			}
+		}
+
+		period.Total = bucketAmount(totalIncome, totalExpense, hasIncome, hasExpense)
+		for i, cat := range sortedCategories {
+			period.Categories[i] = responses.ComparisonCategoryAmount{
+				CategoryID:   cat.ID,
+				CategoryName: cat.Name,
+				CategoryType: cat.Type,
+				Amount:       categoryTotals[cat.ID],
+			}
+		}
+
+		return period
+	}
+
+	current := buildPeriod(currentStart)
+
+	previous := make([]responses.ComparisonPreviousPeriod, 0, req.Periods)
+	for _, start := range periodStarts[1:] {
+		period := buildPeriod(start)
+
+		bucketDeltas := make([]responses.ComparisonDelta, monthCount)
+		for i := range period.Buckets {
+			bucketDeltas[i] = computeDelta(current.Buckets[i].Amount, period.Buckets[i].Amount)
+		}
+
+		categoryDeltas := make([]responses.ComparisonCategoryDelta, len(sortedCategories))
+		for i, cat := range sortedCategories {
+			categoryDeltas[i] = responses.ComparisonCategoryDelta{
+				CategoryID:   cat.ID,
+				CategoryName: cat.Name,
+				Delta:        computeDelta(current.Categories[i].Amount, period.Categories[i].Amount),
+			}
+		}
+
+		previous = append(previous, responses.ComparisonPreviousPeriod{
+			Period:         period,
+			TotalDelta:     computeDelta(current.Total, period.Total),
+			BucketDeltas:   bucketDeltas,
+			CategoryDeltas: categoryDeltas,
+		})
+	}
+
+	return &responses.TrendReportComparisonResponse{
+		Mode:     req.Mode,
+		Currency: userCurrency,
+		Current:  current,
+		Previous: previous,
+	}, nil
+}
+
+func (s *TrendReportService) RenderChart(ctx context.Context, req requests.TrendReportChartRequest) ([]byte, error) {
+	setting, err := s.settingService.GetByUserID(ctx, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user settings: %w", err)
+	}
+	lang := setting.Language
+	if !types.IsValidLanguageType(lang) {
+		lang = types.EnglishLanguage
+	}
+
+	report, err := s.GetByID(ctx, req.ReportID)
+	if err != nil {
+		return nil, err
+	}
+
+	monthlyData, err := s.GetMonthlyData(ctx, requests.TrendReportMonthlyDataRequest{
+		ReportID: req.ReportID,
+		UserID:   req.UserID,
+		Year:     req.Year,
+		Type:     req.Type,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, len(monthlyData.Data))
+	for i, point := range monthlyData.Data {
+		values[i] = point.Amount
+	}
+
+	title := chartDefaultTitles[lang]
+	if report.Title != nil && *report.Title != "" {
+		title = *report.Title
+	}
+
+	color := defaultColor
+	if report.Color != nil && *report.Color != "" {
+		color = *report.Color
+	}
+
+	chart := charts.Chart{
+		Title:       title,
+		Subtitle:    fmt.Sprintf(chartSubtitleFormats[lang], monthlyData.Year, monthlyData.Currency),
+		Labels:      chartMonthLabels[lang],
+		Values:      values,
+		Color:       color,
+		Kind:        req.Kind,
+		FormatValue: chartAmountFormatter(lang),
+	}
+	opts := charts.Options{Width: req.Width, Height: req.Height, Theme: req.Theme}
+
+	switch req.Format {
+	case charts.FormatPNG:
+		return charts.RenderPNG(chart, opts)
+	case charts.FormatSVG:
+		return charts.RenderSVG(chart, opts)
+	default:
+		return nil, errors.New("invalid chart format")
+	}
+}
+
+type convertedRecord struct {
+	models.Record
+	ConvertedAmount float64
+}
+
+// fetchConvertedRecords loads the user's records for the given report categories
+// in [startDate, endDate), narrowed by the report's saved filters, and converts
+// every amount to the target currency using the historical rate of the record date.
+func (s *TrendReportService) fetchConvertedRecords(ctx context.Context, userID uint, report *models.TrendReport, categories map[uint]models.Category, startDate, endDate time.Time, userCurrency types.CurrencyType) ([]convertedRecord, error) {
+	categoryIDs := make([]uint, 0, len(categories))
+	for id := range categories {
+		categoryIDs = append(categoryIDs, id)
+	}
+
+	query := s.db.WithContext(ctx).
+		Where("records.user_id = ? AND records.category_id IN ? AND records.date >= ? AND records.date < ?", userID, categoryIDs, startDate, endDate)
+	query = applyReportFilters(query, report)
+
+	var records []models.Record
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch records: %w", err)
+	}
+
+	return convertRecords(ctx, s.currencyService, records, userCurrency)
+}
+
+// convertRecords converts every record amount to the target currency using the
+// historical rate of the record date.
+func convertRecords(ctx context.Context, currencyService *CurrencyService, records []models.Record, userCurrency types.CurrencyType) ([]convertedRecord, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	var historicalRates map[string]float64
+	var err error
+	for _, record := range records {
+		if record.Currency != userCurrency {
+			historicalRates, err = currencyService.GetHistoricalRatesForRecords(ctx, records, userCurrency)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get historical exchange rates: %w", err)
+			}
+			break
+		}
+	}
+
+	converted := make([]convertedRecord, 0, len(records))
+	for _, record := range records {
+		convertedAmount := record.Amount
+		if record.Currency != userCurrency {
+			rateKey := fmt.Sprintf("%s_%s_%s",
+				record.Date.Format("2006-01-02"),
+				record.Currency,
+				userCurrency)
+			rate, exists := historicalRates[rateKey]
+			if !exists {
+				return nil, fmt.Errorf("no rate found for record #%d on %s", record.ID, record.Date.Format("2006-01-02"))
+			}
+			convertedAmount = record.Amount * rate
+		}
+		converted = append(converted, convertedRecord{Record: record, ConvertedAmount: convertedAmount})
+	}
+
+	return converted, nil
+}
+
 func (s *TrendReportService) loadCategories(ctx context.Context, categoryIDs []uint) ([]models.Category, error) {
 	var categories []models.Category
 	if err := s.db.WithContext(ctx).
@@ -420,6 +671,68 @@ func (s *TrendReportService) loadCategories(ctx context.Context, categoryIDs []u
 	return categories, nil
 }
 
+func (s *TrendReportService) loadPaymentMethods(ctx context.Context, userID uint, paymentMethodIDs []uint) ([]models.PaymentMethod, error) {
+	if len(paymentMethodIDs) == 0 {
+		return []models.PaymentMethod{}, nil
+	}
+	paymentMethodIDs = uniqueIDs(paymentMethodIDs)
+
+	var paymentMethods []models.PaymentMethod
+	if err := s.db.WithContext(ctx).
+		Where("id IN ? AND user_id = ?", paymentMethodIDs, userID).
+		Find(&paymentMethods).Error; err != nil {
+		return nil, fmt.Errorf("failed to load payment methods: %w", err)
+	}
+	if len(paymentMethods) != len(paymentMethodIDs) {
+		return nil, errors.New("invalid payment methods")
+	}
+	return paymentMethods, nil
+}
+
+// applyReportFilters narrows a records query the same way RecordService.GetAll
+// applies a RecordFilterRequest. The amount range is compared against the amount
+// as stored on the record, in its original currency.
+func applyReportFilters(query *gorm.DB, report *models.TrendReport) *gorm.DB {
+	if len(report.PaymentMethods) > 0 {
+		paymentMethodIDs := make([]uint, 0, len(report.PaymentMethods))
+		for _, pm := range report.PaymentMethods {
+			paymentMethodIDs = append(paymentMethodIDs, pm.ID)
+		}
+		query = query.Where("records.payment_method_id IN ?", uniqueIDs(paymentMethodIDs))
+	}
+
+	if report.Search != nil && *report.Search != "" {
+		searchPattern := "%" + *report.Search + "%"
+		query = query.Select("records.*").
+			Joins("LEFT JOIN categories ON categories.id = records.category_id").
+			Where("records.description ILIKE ? OR categories.name ILIKE ?", searchPattern, searchPattern)
+	}
+
+	if report.MinAmount != nil {
+		query = query.Where("records.amount >= ?", *report.MinAmount)
+	}
+	if report.MaxAmount != nil {
+		query = query.Where("records.amount <= ?", *report.MaxAmount)
+	}
+
+	return query
+}
+
+// uniqueIDs returns the IDs sorted and without repeats, so a repeated ID
+// neither fails the count check of a lookup nor repeats in a filter.
+func uniqueIDs(ids []uint) []uint {
+	unique := slices.Clone(ids)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
+
+func validateAmountRange(minAmount, maxAmount *float64) error {
+	if minAmount != nil && maxAmount != nil && *minAmount > *maxAmount {
+		return errors.New("minimum amount cannot be greater than maximum amount")
+	}
+	return nil
+}
+
 func resolveColor(reqColor *string, categories []models.Category) string {
 	if reqColor != nil && *reqColor != "" {
 		return *reqColor
@@ -430,6 +743,62 @@ func resolveColor(reqColor *string, categories []models.Category) string {
 	return defaultColor
 }
 
+func filterReportCategories(categories []models.Category, categoryType *types.CategoryType) map[uint]models.Category {
+	filtered := make(map[uint]models.Category, len(categories))
+	for _, cat := range categories {
+		if categoryType != nil && cat.Type != *categoryType {
+			continue
+		}
+		filtered[cat.ID] = cat
+	}
+	return filtered
+}
+
+func categoryTypePresence(categories map[uint]models.Category) (hasIncome bool, hasExpense bool) {
+	for _, cat := range categories {
+		if cat.Type == types.Income {
+			hasIncome = true
+		} else {
+			hasExpense = true
+		}
+	}
+	return hasIncome, hasExpense
+}
+
+// bucketAmount reports a net balance for mixed reports and a plain sum when the
+// report only tracks income or only tracks expense categories.
+func bucketAmount(income, expense float64, hasIncome, hasExpense bool) float64 {
+	switch {
+	case hasIncome && hasExpense:
+		return income - expense
+	case hasIncome:
+		return income
+	default:
+		return expense
+	}
+}
+
+func computeDelta(current, previous float64) responses.ComparisonDelta {
+	delta := responses.ComparisonDelta{
+		Current:  current,
+		Previous: previous,
+		Absolute: current - previous,
+	}
+	if previous != 0 {
+		percentage := (current - previous) / math.Abs(previous) * 100
+		delta.Percentage = &percentage
+	}
+	return delta
+}
+
+// chartAmountFormatter formats axis values as whole amounts with the separators
+// used by the given language.
+func chartAmountFormatter(lang types.LanguageType) func(float64) string {
+	return func(v float64) string {
+		return utils.FormatNumber(v, 0, lang)
+	}
+}
+
 func emptyMonthlyDetails(currency types.CurrencyType, year int) *responses.TrendReportMonthlyDetailsResponse {
 	data := make([]responses.MonthlyDetailGroup, 12)
 	for i := 1; i <= 12; i++ {
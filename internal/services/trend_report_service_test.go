@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emilijan-koteski/monexa/internal/models"
+)
+
+func TestLoadPaymentMethodsAcceptsRepeatedIDs(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t, &models.PaymentMethod{})
+	s := NewTrendReportService(db, nil, nil)
+
+	paymentMethods := []models.PaymentMethod{{UserID: 1, Name: "Cash"}, {UserID: 1, Name: "Card"}, {UserID: 2, Name: "Cash"}}
+	if err := db.Create(&paymentMethods).Error; err != nil {
+		t.Fatal(err)
+	}
+	cash, card, otherUsers := paymentMethods[0].ID, paymentMethods[1].ID, paymentMethods[2].ID
+
+	loaded, err := s.loadPaymentMethods(ctx, 1, []uint{card, cash, card})
+	if err != nil {
+		t.Fatalf("loadPaymentMethods() with a repeated ID error = %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("loadPaymentMethods() loaded %d payment methods, want 2", len(loaded))
+	}
+
+	if _, err := s.loadPaymentMethods(ctx, 1, []uint{cash, cash, otherUsers}); err == nil {
+		t.Error("loadPaymentMethods() with another user's payment method succeeded, want an error")
+	}
+}
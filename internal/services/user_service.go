@@ -9,7 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
 	"os"
 	"time"
 
@@ -18,6 +17,7 @@ import (
 	"github.com/emilijan-koteski/monexa/internal/requests"
 	"github.com/emilijan-koteski/monexa/internal/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 const (
@@ -25,20 +25,40 @@ const (
 )
 
 const (
-	PasswordResetTokenDuration = 30 * time.Minute
-	PasswordResetTokenBytes    = 32
+	PasswordResetTokenDuration     = 30 * time.Minute
+	PasswordResetTokenBytes        = 32
+	EmailVerificationTokenDuration = 24 * time.Hour
+	EmailChangeConfirmDuration     = 24 * time.Hour
+	// EmailChangeCancelDuration keeps the cancel link sent to the old address
+	// working for a while after the change, so its owner can take the account
+	// back when someone else changed the email.
+	EmailChangeCancelDuration = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email address is already verified")
+	ErrInvalidEmailChangeToken  = errors.New("invalid or expired email change token")
+	ErrEmailTaken               = errors.New("email already registered")
+	ErrSameEmail                = errors.New("new email is the same as the current one")
+	ErrInvalidEmail             = errors.New("invalid email address")
+	ErrIncorrectPassword        = errors.New("current password is incorrect")
+	ErrEmailNotVerified         = errors.New("verify your email address before changing it")
+	ErrEmailChangeCancellable   = errors.New("the last email change can still be cancelled, try again once its cancel link expires")
 )
 
 type UserService struct {
 	db                   *gorm.DB
 	mailService          *MailService
+	outboxService        *OutboxService
 	legalDocumentService *LegalDocumentService
 }
 
-func NewUserService(db *gorm.DB, mailService *MailService, legalDocumentService *LegalDocumentService) *UserService {
+func NewUserService(db *gorm.DB, mailService *MailService, outboxService *OutboxService, legalDocumentService *LegalDocumentService) *UserService {
 	return &UserService{
 		db:                   db,
 		mailService:          mailService,
+		outboxService:        outboxService,
 		legalDocumentService: legalDocumentService,
 	}
 }
@@ -153,6 +173,11 @@ func (s *UserService) CreateUser(ctx context.Context, req requests.RegisterReque
 		}
 	}
 
+	if err = s.createEmailVerification(tx, user, language); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if err = tx.Commit().Error; err != nil {
 		return nil, err
 	}
@@ -180,20 +205,15 @@ func (s *UserService) DeleteUser(ctx context.Context, userID uint) error {
 		return fmt.Errorf("failed to schedule user deletion: %w", err)
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit account deletion scheduling: %w", err)
+	if err := s.enqueueAccountDeletionEmail(tx, user, "initial", s.getUserLanguage(ctx, userID)); err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	var language types.LanguageType
-	var setting models.Setting
-	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&setting).Error; err == nil {
-		language = setting.Language
-	} else {
-		language = types.EnglishLanguage
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit account deletion scheduling: %w", err)
 	}
 
-	go s.sendAccountDeletionEmail(user.Email, user.Name, "initial", language)
-
 	return nil
 }
 
@@ -206,6 +226,16 @@ func (s *UserService) GetUserByEmailUnscoped(ctx context.Context, email string)
 	return &user, nil
 }
 
+// GetUserByIDUnscoped also finds accounts that are scheduled for deletion, so
+// a login can reactivate them.
+func (s *UserService) GetUserByIDUnscoped(ctx context.Context, userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Unscoped().Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (s *UserService) ReactivateUser(ctx context.Context, userID uint) error {
 	return s.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("id = ?", userID).Update("deleted_at", nil).Error
 }
@@ -221,7 +251,7 @@ func (s *UserService) ChangePassword(ctx context.Context, userID uint, req reque
 	}
 
 	if err := utils.CheckPassword(req.CurrentPassword, user.Password); err != nil {
-		return errors.New("current password is incorrect")
+		return ErrIncorrectPassword
 	}
 
 	hashedPassword, err := utils.HashPassword(req.NewPassword)
@@ -257,11 +287,6 @@ func (s *UserService) RequestPasswordReset(ctx context.Context, email string) er
 		return nil
 	}
 
-	plainToken, tokenHash, err := generateResetToken()
-	if err != nil {
-		return fmt.Errorf("failed to generate reset token: %w", err)
-	}
-
 	tx := s.db.WithContext(ctx).Begin()
 
 	defer func() {
@@ -270,10 +295,29 @@ func (s *UserService) RequestPasswordReset(ctx context.Context, email string) er
 		}
 	}()
 
+	if err := s.createPasswordReset(tx, user, s.getUserLanguage(ctx, user.ID)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit password reset token: %w", err)
+	}
+
+	return nil
+}
+
+// createPasswordReset replaces the unused reset tokens of the user with a new
+// one and enqueues its link to the email of the user.
+func (s *UserService) createPasswordReset(tx *gorm.DB, user models.User, language types.LanguageType) error {
+	plainToken, tokenHash, err := generateResetToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
 	if err := tx.Model(&models.PasswordResetToken{}).
 		Where("user_id = ? AND used_at IS NULL", user.ID).
 		Update("used_at", time.Now()).Error; err != nil {
-		tx.Rollback()
 		return fmt.Errorf("failed to invalidate existing tokens: %w", err)
 	}
 
@@ -283,25 +327,10 @@ func (s *UserService) RequestPasswordReset(ctx context.Context, email string) er
 		ExpiresAt: time.Now().Add(PasswordResetTokenDuration),
 	}
 	if err := tx.Create(&resetToken).Error; err != nil {
-		tx.Rollback()
 		return fmt.Errorf("failed to store reset token: %w", err)
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit password reset token: %w", err)
-	}
-
-	var language types.LanguageType
-	var setting models.Setting
-	if err := s.db.WithContext(ctx).Where("user_id = ?", user.ID).First(&setting).Error; err == nil {
-		language = setting.Language
-	} else {
-		language = types.EnglishLanguage
-	}
-
-	go s.sendPasswordResetEmail(user.Email, user.Name, plainToken, language)
-
-	return nil
+	return s.enqueuePasswordResetEmail(tx, user, plainToken, language)
 }
 
 func (s *UserService) ResetPassword(ctx context.Context, token string, newPassword string) (*models.User, error) {
@@ -375,6 +404,127 @@ func hashResetToken(plainToken string) string {
 	return base64.RawURLEncoding.EncodeToString(h[:])
 }
 
+// ResendEmailVerification replaces the pending verification link of the user
+// with a new one.
+func (s *UserService) ResendEmailVerification(ctx context.Context, userID uint) error {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.createEmailVerification(tx, user, s.getUserLanguage(ctx, user.ID)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit email verification: %w", err)
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the address of the token as verified. A token sent to an
+// address the user no longer has is rejected.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var verificationTokens []models.EmailVerificationToken
+	result := tx.Model(&verificationTokens).
+		Clauses(clause.Returning{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashResetToken(token), time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to consume verification token: %w", result.Error)
+	}
+	if len(verificationTokens) == 0 {
+		tx.Rollback()
+		return nil, ErrInvalidVerificationToken
+	}
+	verificationToken := verificationTokens[0]
+
+	var user models.User
+	if err := tx.Where("id = ?", verificationToken.UserID).First(&user).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if utils.NormalizeEmail(user.Email) != utils.NormalizeEmail(verificationToken.Email) {
+		tx.Rollback()
+		return nil, ErrInvalidVerificationToken
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to verify email: %w", err)
+		}
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit email verification: %w", err)
+	}
+
+	return &user, nil
+}
+
+func (s *UserService) IsEmailVerified(ctx context.Context, userID uint) (bool, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("email_verified_at").Where("id = ?", userID).First(&user).Error; err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
+
+func (s *UserService) CleanupExpiredEmailVerificationTokens(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("expires_at < ? OR used_at IS NOT NULL", time.Now()).
+		Delete(&models.EmailVerificationToken{})
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
+// CleanupExpiredEmailChangeRequests drops the requests that can neither be
+// confirmed nor cancelled anymore.
+func (s *UserService) CleanupExpiredEmailChangeRequests(ctx context.Context) (int64, error) {
+	now := time.Now()
+	result := s.db.WithContext(ctx).
+		Where("cancel_expires_at < ? OR (confirmed_at IS NULL AND confirm_expires_at < ?)", now, now).
+		Delete(&models.EmailChangeRequest{})
+
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	return result.RowsAffected, nil
+}
+
 func (s *UserService) CleanupExpiredResetTokens(ctx context.Context) (int64, error) {
 	result := s.db.WithContext(ctx).
 		Where("expires_at < ? OR used_at IS NOT NULL", time.Now()).
@@ -461,16 +611,8 @@ func (s *UserService) GetUsersForFinalDeletion(ctx context.Context) ([]models.Us
 	return users, nil
 }
 
-func (s *UserService) SendDeletionReminderEmail(ctx context.Context, user models.User) {
-	var language types.LanguageType
-	var setting models.Setting
-	if err := s.db.WithContext(ctx).Where("user_id = ?", user.ID).First(&setting).Error; err == nil {
-		language = setting.Language
-	} else {
-		language = types.EnglishLanguage
-	}
-
-	s.sendAccountDeletionEmail(user.Email, user.Name, "reminder", language)
+func (s *UserService) SendDeletionReminderEmail(ctx context.Context, user models.User) error {
+	return s.enqueueAccountDeletionEmail(s.db.WithContext(ctx), user, "reminder", s.getUserLanguage(ctx, user.ID))
 }
 
 func (s *UserService) FinalizeUserDeletion(ctx context.Context, userID uint) error {
@@ -526,6 +668,7 @@ func (s *UserService) FinalizeUserDeletion(ctx context.Context, userID uint) err
 		"title":       gorm.Expr("CONCAT('[Deleted Trend Report #', id, ']')"),
 		"description": gorm.Expr("CONCAT('[Deleted Trend Report #', id, ']')"),
 		"color":       nil,
+		"search":      nil,
 		"deleted_at":  now,
 	}).Error; err != nil {
 		tx.Rollback()
@@ -564,6 +707,86 @@ func (s *UserService) FinalizeUserDeletion(ctx context.Context, userID uint) err
 		return fmt.Errorf("failed to delete password reset tokens: %w", err)
 	}
 
+	// Hard-delete all email verification tokens for this user
+	if err := tx.Where("user_id = ?", userID).Delete(&models.EmailVerificationToken{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete email verification tokens: %w", err)
+	}
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.EmailChangeRequest{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete email change requests: %w", err)
+	}
+
+	// Hard-delete the two-factor secret and recovery codes for this user
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserTOTP{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+
+	// Hard-delete passkeys and pending passkey ceremonies for this user
+	if err := tx.Where("user_id = ?", userID).Delete(&models.PasskeyCredential{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete passkeys: %w", err)
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.PasskeyCeremony{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete passkey ceremonies: %w", err)
+	}
+
+	// Hard-delete linked identity provider accounts and pending sign ins for this user
+	if err := tx.Where("user_id = ?", userID).Delete(&models.OIDCIdentity{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete oidc identities: %w", err)
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.OIDCState{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete oidc states: %w", err)
+	}
+
+	// Hard-delete personal access tokens for this user
+	if err := tx.Where("user_id = ?", userID).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete personal access tokens: %w", err)
+	}
+
+	// Hard-delete queued and delivered emails for this user, they hold the address
+	if err := tx.Where("user_id = ?", userID).Delete(&models.OutboxEmail{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete outbox emails: %w", err)
+	}
+
+	// Hard-delete the export schedule for this user
+	if err := tx.Where("user_id = ?", userID).Delete(&models.ExportSchedule{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete export schedule: %w", err)
+	}
+
+	// Hard-delete the digest subscription for this user
+	if err := tx.Where("user_id = ?", userID).Delete(&models.DigestSubscription{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete digest subscription: %w", err)
+	}
+
+	// Expire export downloads so the cleanup job deletes their files, and drop
+	// exports that have not been generated yet
+	if err := tx.Model(&models.ExportJob{}).
+		Where("user_id = ? AND status = ?", userID, types.ExportJobCompleted).
+		Update("expires_at", now).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to expire export jobs: %w", err)
+	}
+	if err := tx.Model(&models.ExportJob{}).
+		Where("user_id = ? AND status = ?", userID, types.ExportJobPending).
+		Updates(map[string]any{"status": types.ExportJobFailed, "error": "account deleted"}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to cancel export jobs: %w", err)
+	}
+
 	// Anonymize user and randomize password
 	randomBytes := make([]byte, 32)
 	if _, err := rand.Read(randomBytes); err != nil {
@@ -593,7 +816,17 @@ func (s *UserService) FinalizeUserDeletion(ctx context.Context, userID uint) err
 	return nil
 }
 
-func (s *UserService) sendAccountDeletionEmail(email, name, period string, language types.LanguageType) {
+// getUserLanguage returns the language of the user's emails, English unless
+// their settings say otherwise.
+func (s *UserService) getUserLanguage(ctx context.Context, userID uint) types.LanguageType {
+	var setting models.Setting
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&setting).Error; err == nil {
+		return setting.Language
+	}
+	return types.EnglishLanguage
+}
+
+func (s *UserService) enqueueAccountDeletionEmail(tx *gorm.DB, user models.User, period string, language types.LanguageType) error {
 	var deletePeriod string
 	if period == "reminder" {
 		deletePeriod = "tomorrow"
@@ -612,49 +845,448 @@ func (s *UserService) sendAccountDeletionEmail(email, name, period string, langu
 	templatePath := s.mailService.GetEmailTemplatePath(AccountDeletionTemplate, language)
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
-		log.Printf("failed to parse account deletion email template: %v", err)
-		return
+		return fmt.Errorf("failed to parse account deletion email template: %w", err)
 	}
 
 	var body bytes.Buffer
 	if err := tmpl.Execute(&body, map[string]string{
-		"UserName":      name,
+		"UserName":      user.Name,
 		"DeletePeriod":  deletePeriod,
 		"ReactivateURL": reactivateURL,
 	}); err != nil {
-		log.Printf("failed to render account deletion email template: %v", err)
-		return
+		return fmt.Errorf("failed to render account deletion email template: %w", err)
 	}
 
 	subject := s.mailService.GetEmailSubject(AccountDeletionTemplate, language)
 
-	if err := s.mailService.SendHTML(email, subject, body.String()); err != nil {
-		log.Printf("failed to send account deletion email to %s: %v", email, err)
+	return s.outboxService.Enqueue(tx, &user.ID, AccountDeletionTemplate, user.Email, subject, body.String())
+}
+
+// createEmailVerification invalidates the pending verification tokens of the
+// user and queues a link for the current address.
+func (s *UserService) createEmailVerification(tx *gorm.DB, user models.User, language types.LanguageType) error {
+	plainToken, tokenHash, err := generateResetToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	if err := tx.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to invalidate existing verification tokens: %w", err)
+	}
+
+	verificationToken := models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(EmailVerificationTokenDuration),
+	}
+	if err := tx.Create(&verificationToken).Error; err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	verifyURL := fmt.Sprintf("%s/verify-email?token=%s&lang=%s", os.Getenv("FRONTEND_URL"), plainToken, string(language))
+
+	templatePath := s.mailService.GetEmailTemplatePath(EmailVerificationTemplate, language)
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse email template: %w", err)
 	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, map[string]string{
+		"UserName":  user.Name,
+		"VerifyURL": verifyURL,
+	}); err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	subject := s.mailService.GetEmailSubject(EmailVerificationTemplate, language)
+
+	return s.outboxService.Enqueue(tx, &user.ID, EmailVerificationTemplate, user.Email, subject, body.String())
 }
 
-func (s *UserService) sendPasswordResetEmail(email, name, token string, language types.LanguageType) {
+// SendAccountLockedEmail tells the owner of email, if there is one, that
+// failed sign ins locked their account.
+func (s *UserService) SendAccountLockedEmail(ctx context.Context, email string, failures int, lockDuration time.Duration) error {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("LOWER(email) = ?", utils.NormalizeEmail(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	language := s.getUserLanguage(ctx, user.ID)
+	resetURL := fmt.Sprintf("%s/forgot-password?lang=%s", os.Getenv("FRONTEND_URL"), string(language))
+
+	templatePath := s.mailService.GetEmailTemplatePath(AccountLockedTemplate, language)
+	tmpl, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to parse email template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, map[string]interface{}{
+		"UserName":    user.Name,
+		"Failures":    failures,
+		"LockMinutes": int(lockDuration.Minutes()),
+		"ResetURL":    resetURL,
+	}); err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	subject := s.mailService.GetEmailSubject(AccountLockedTemplate, language)
+
+	return s.outboxService.Enqueue(s.db.WithContext(ctx), &user.ID, AccountLockedTemplate, user.Email, subject, body.String())
+}
+
+func (s *UserService) enqueuePasswordResetEmail(tx *gorm.DB, user models.User, token string, language types.LanguageType) error {
 	resetURL := fmt.Sprintf("%s/reset-password?token=%s&lang=%s", os.Getenv("FRONTEND_URL"), token, string(language))
 
 	templatePath := s.mailService.GetEmailTemplatePath(PasswordResetTemplate, language)
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
-		log.Printf("failed to parse email template: %v", err)
-		return
+		return fmt.Errorf("failed to parse email template: %w", err)
 	}
 
 	var body bytes.Buffer
 	if err := tmpl.Execute(&body, map[string]string{
-		"UserName": name,
+		"UserName": user.Name,
 		"ResetURL": resetURL,
 	}); err != nil {
-		log.Printf("failed to render email template: %v", err)
-		return
+		return fmt.Errorf("failed to render email template: %w", err)
 	}
 
 	subject := s.mailService.GetEmailSubject(PasswordResetTemplate, language)
 
-	if err := s.mailService.SendHTML(email, subject, body.String()); err != nil {
-		log.Printf("failed to send reset email to %s: %v", email, err)
+	return s.outboxService.Enqueue(tx, &user.ID, PasswordResetTemplate, user.Email, subject, body.String())
+}
+
+// RequestEmailChange starts changing the email of the user to req.NewEmail.
+// The new address gets a confirmation link and the current one a notice with
+// a cancel link. A new request replaces the pending one. Only a verified
+// address can be changed, since its cancel link can revert the change, and
+// not again while that link still works, since a second change would leave
+// nothing for it to revert.
+func (s *UserService) RequestEmailChange(ctx context.Context, userID uint, req requests.ChangeEmailRequest) error {
+	newEmail := utils.NormalizeEmail(req.NewEmail)
+	if !utils.IsValidEmail(newEmail) {
+		return ErrInvalidEmail
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		return errors.New("user not found")
+	}
+
+	if err := utils.CheckPassword(req.CurrentPassword, user.Password); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+
+	if newEmail == utils.NormalizeEmail(user.Email) {
+		return ErrSameEmail
+	}
+
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := s.checkEmailAvailable(tx, newEmail); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var cancellable int64
+	if err := tx.Model(&models.EmailChangeRequest{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL AND cancel_expires_at > ?", user.ID, time.Now()).
+		Count(&cancellable).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check email change requests: %w", err)
+	}
+	if cancellable > 0 {
+		tx.Rollback()
+		return ErrEmailChangeCancellable
+	}
+
+	if err := tx.Where("user_id = ? AND confirmed_at IS NULL", user.ID).Delete(&models.EmailChangeRequest{}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete pending email change requests: %w", err)
+	}
+
+	confirmToken, confirmHash, err := generateResetToken()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to generate confirm token: %w", err)
+	}
+	cancelToken, cancelHash, err := generateResetToken()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to generate cancel token: %w", err)
+	}
+
+	now := time.Now()
+	changeRequest := models.EmailChangeRequest{
+		UserID:           user.ID,
+		OldEmail:         user.Email,
+		NewEmail:         newEmail,
+		ConfirmTokenHash: confirmHash,
+		CancelTokenHash:  cancelHash,
+		ConfirmExpiresAt: now.Add(EmailChangeConfirmDuration),
+		CancelExpiresAt:  now.Add(EmailChangeCancelDuration),
+	}
+	if err := tx.Create(&changeRequest).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to store email change request: %w", err)
+	}
+
+	language := s.getUserLanguage(ctx, user.ID)
+	if err := s.enqueueEmailChangeEmails(tx, user, newEmail, confirmToken, cancelToken, language); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("failed to commit email change request: %w", err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange applies the email change of the token. The new address
+// counts as verified, since the link proves access to it.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, token string) (*models.User, error) {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	var changeRequests []models.EmailChangeRequest
+	result := tx.Model(&changeRequests).
+		Clauses(clause.Returning{}).
+		Where("confirm_token_hash = ? AND confirmed_at IS NULL AND confirm_expires_at > ?", hashResetToken(token), now).
+		Update("confirmed_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to consume email change token: %w", result.Error)
+	}
+	if len(changeRequests) == 0 {
+		tx.Rollback()
+		return nil, ErrInvalidEmailChangeToken
+	}
+	changeRequest := changeRequests[0]
+
+	var user models.User
+	if err := tx.Where("id = ?", changeRequest.UserID).First(&user).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidEmailChangeToken
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	// A request made before another change of the email is stale.
+	if utils.NormalizeEmail(user.Email) != utils.NormalizeEmail(changeRequest.OldEmail) {
+		tx.Rollback()
+		return nil, ErrInvalidEmailChangeToken
+	}
+
+	// The address may have been registered since the request.
+	if err := s.checkEmailAvailable(tx, changeRequest.NewEmail); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.setUserEmail(tx, &user, changeRequest.NewEmail, &now); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit email change: %w", err)
+	}
+
+	return &user, nil
+}
+
+// CancelEmailChange drops the email change of the token. A change that was
+// already confirmed is reverted to the old address. Whoever changed it knows
+// the password, so the password is replaced by a reset link sent to the old
+// address, and passkeys, linked providers and personal access tokens added
+// since the change are removed. The returned flag tells the caller to sign
+// out the sessions made since.
+func (s *UserService) CancelEmailChange(ctx context.Context, token string) (*models.User, bool, error) {
+	tx := s.db.WithContext(ctx).Begin()
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var changeRequests []models.EmailChangeRequest
+	result := tx.Clauses(clause.Returning{}).
+		Where("cancel_token_hash = ? AND cancel_expires_at > ?", hashResetToken(token), time.Now()).
+		Delete(&changeRequests)
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, false, fmt.Errorf("failed to consume email change token: %w", result.Error)
+	}
+	if len(changeRequests) == 0 {
+		tx.Rollback()
+		return nil, false, ErrInvalidEmailChangeToken
+	}
+	changeRequest := changeRequests[0]
+
+	var user models.User
+	if err := tx.Where("id = ?", changeRequest.UserID).First(&user).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrInvalidEmailChangeToken
+		}
+		return nil, false, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	reverted := false
+	if changeRequest.ConfirmedAt != nil && utils.NormalizeEmail(user.Email) == utils.NormalizeEmail(changeRequest.NewEmail) {
+		if err := s.checkEmailAvailable(tx, changeRequest.OldEmail); err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		// RequestEmailChange only accepts verified addresses, and the cancel
+		// link proves access to the old one still.
+		now := time.Now()
+		if err := s.setUserEmail(tx, &user, changeRequest.OldEmail, &now); err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		if err := s.revokeCredentialsSince(tx, user, *changeRequest.ConfirmedAt); err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		if err := s.createPasswordReset(tx, user, s.getUserLanguage(ctx, user.ID)); err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		reverted = true
 	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, false, fmt.Errorf("failed to commit email change cancellation: %w", err)
+	}
+
+	return &user, reverted, nil
+}
+
+// revokeCredentialsSince replaces the password of the user with a random one
+// and removes the passkeys, linked providers and personal access tokens added
+// since the given time.
+func (s *UserService) revokeCredentialsSince(tx *gorm.DB, user models.User, since time.Time) error {
+	random, _, err := generateResetToken()
+	if err != nil {
+		return err
+	}
+	password, err := utils.HashPassword(random)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("password", password).Error; err != nil {
+		return fmt.Errorf("failed to replace password: %w", err)
+	}
+
+	for _, credential := range []any{&models.PasskeyCredential{}, &models.OIDCIdentity{}, &models.PersonalAccessToken{}} {
+		if err := tx.Where("user_id = ? AND created_at >= ?", user.ID, since).Delete(credential).Error; err != nil {
+			return fmt.Errorf("failed to remove credentials: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkEmailAvailable returns ErrEmailTaken when email belongs to an account,
+// including accounts waiting for deletion, since they may be reactivated.
+func (s *UserService) checkEmailAvailable(tx *gorm.DB, email string) error {
+	var count int64
+	if err := tx.Unscoped().Model(&models.User{}).
+		Where("LOWER(email) = ?", utils.NormalizeEmail(email)).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// setUserEmail changes the email of the user and invalidates the verification
+// links sent to the previous address.
+func (s *UserService) setUserEmail(tx *gorm.DB, user *models.User, email string, verifiedAt *time.Time) error {
+	if err := tx.Model(user).Updates(map[string]any{
+		"email":             email,
+		"email_verified_at": verifiedAt,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to change email: %w", err)
+	}
+
+	if err := tx.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to invalidate existing verification tokens: %w", err)
+	}
+
+	user.Email = email
+	user.EmailVerifiedAt = verifiedAt
+	return nil
+}
+
+func (s *UserService) enqueueEmailChangeEmails(tx *gorm.DB, user models.User, newEmail, confirmToken, cancelToken string, language types.LanguageType) error {
+	confirmURL := fmt.Sprintf("%s/change-email/confirm?token=%s&lang=%s", os.Getenv("FRONTEND_URL"), confirmToken, string(language))
+	cancelURL := fmt.Sprintf("%s/change-email/cancel?token=%s&lang=%s", os.Getenv("FRONTEND_URL"), cancelToken, string(language))
+
+	confirmTmpl, err := template.ParseFiles(s.mailService.GetEmailTemplatePath(EmailChangeConfirmTemplate, language))
+	if err != nil {
+		return fmt.Errorf("failed to parse email template: %w", err)
+	}
+
+	var confirmBody bytes.Buffer
+	if err := confirmTmpl.Execute(&confirmBody, map[string]string{
+		"UserName":   user.Name,
+		"ConfirmURL": confirmURL,
+	}); err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	subject := s.mailService.GetEmailSubject(EmailChangeConfirmTemplate, language)
+	if err := s.outboxService.Enqueue(tx, &user.ID, EmailChangeConfirmTemplate, newEmail, subject, confirmBody.String()); err != nil {
+		return err
+	}
+
+	noticeTmpl, err := template.ParseFiles(s.mailService.GetEmailTemplatePath(EmailChangeNoticeTemplate, language))
+	if err != nil {
+		return fmt.Errorf("failed to parse email template: %w", err)
+	}
+
+	var noticeBody bytes.Buffer
+	if err := noticeTmpl.Execute(&noticeBody, map[string]interface{}{
+		"UserName":   user.Name,
+		"NewEmail":   newEmail,
+		"CancelURL":  cancelURL,
+		"CancelDays": int(EmailChangeCancelDuration.Hours() / 24),
+	}); err != nil {
+		return fmt.Errorf("failed to render email template: %w", err)
+	}
+
+	subject = s.mailService.GetEmailSubject(EmailChangeNoticeTemplate, language)
+	return s.outboxService.Enqueue(tx, &user.ID, EmailChangeNoticeTemplate, user.Email, subject, noticeBody.String())
 }
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emilijan-koteski/monexa/internal/clients"
+	"github.com/emilijan-koteski/monexa/internal/models"
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+	"github.com/emilijan-koteski/monexa/internal/requests"
+	"github.com/emilijan-koteski/monexa/internal/utils"
+)
+
+func newTestUserService(t *testing.T) (*UserService, *OutboxService, *clients.MemoryMailer) {
+	t.Helper()
+	useRepoRoot(t)
+	t.Setenv("FRONTEND_URL", "https://monexa.test")
+
+	db := newTestDB(t, &models.User{}, &models.Setting{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}, &models.EmailChangeRequest{}, &models.OutboxEmail{},
+		&models.PasskeyCredential{}, &models.OIDCIdentity{}, &models.PersonalAccessToken{})
+	mailer := clients.NewMemoryMailer()
+	mailService := NewMailService(mailer)
+	outboxService := NewOutboxService(db, mailService)
+	return NewUserService(db, mailService, outboxService, nil), outboxService, mailer
+}
+
+func TestRequestPasswordResetSendsEmail(t *testing.T) {
+	ctx := context.Background()
+	userService, outboxService, mailer := newTestUserService(t)
+
+	user := models.User{Email: "ana@example.com", Password: "hash", Name: "Ana", PPID: "ppid-ana"}
+	if err := userService.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := userService.db.Create(&models.Setting{UserID: user.ID, Language: types.EnglishLanguage, Currency: types.Euro}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := userService.RequestPasswordReset(ctx, "  Ana@Example.com "); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	if got := mailer.Messages(); len(got) != 0 {
+		t.Fatalf("sent %d emails before the outbox was delivered", len(got))
+	}
+
+	delivered, err := outboxService.DeliverNext(ctx)
+	if err != nil || !delivered {
+		t.Fatalf("DeliverNext() = %v, %v, want true, nil", delivered, err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(messages))
+	}
+	message := messages[0]
+	if len(message.To) != 1 || message.To[0] != user.Email {
+		t.Errorf("To = %v, want [%s]", message.To, user.Email)
+	}
+	if want := userService.mailService.GetEmailSubject(PasswordResetTemplate, types.EnglishLanguage); message.Subject != want {
+		t.Errorf("Subject = %q, want %q", message.Subject, want)
+	}
+	if !strings.Contains(message.HTML, "Ana") {
+		t.Errorf("HTML does not greet the user:\n%s", message.HTML)
+	}
+	if !strings.Contains(message.HTML, "https://monexa.test/reset-password?token=") {
+		t.Errorf("HTML has no reset link:\n%s", message.HTML)
+	}
+
+	var token models.PasswordResetToken
+	if err := userService.db.Where("user_id = ?", user.ID).First(&token).Error; err != nil {
+		t.Fatalf("no reset token stored: %v", err)
+	}
+	if strings.Contains(message.HTML, token.TokenHash) {
+		t.Error("HTML contains the stored token hash instead of the token")
+	}
+}
+
+func TestRequestPasswordResetUnknownEmailSendsNothing(t *testing.T) {
+	ctx := context.Background()
+	userService, outboxService, mailer := newTestUserService(t)
+
+	if err := userService.RequestPasswordReset(ctx, "nobody@example.com"); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	if delivered, err := outboxService.DeliverNext(ctx); err != nil || delivered {
+		t.Fatalf("DeliverNext() = %v, %v, want false, nil", delivered, err)
+	}
+	if got := mailer.Messages(); len(got) != 0 {
+		t.Fatalf("sent %d emails, want none", len(got))
+	}
+}
+
+func createEmailChangeTestUser(t *testing.T, userService *UserService, verified bool) models.User {
+	t.Helper()
+
+	password, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Email: "ana@example.com", Password: password, Name: "Ana", PPID: "ppid-ana"}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := userService.db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestRequestEmailChangeNeedsVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	userService, outboxService, _ := newTestUserService(t)
+	user := createEmailChangeTestUser(t, userService, false)
+
+	err := userService.RequestEmailChange(ctx, user.ID, requests.ChangeEmailRequest{CurrentPassword: "correct horse", NewEmail: "ana@work.com"})
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("RequestEmailChange() error = %v, want %v", err, ErrEmailNotVerified)
+	}
+	if delivered, err := outboxService.DeliverNext(ctx); err != nil || delivered {
+		t.Errorf("DeliverNext() = %v, %v, want false, nil", delivered, err)
+	}
+}
+
+func TestRequestEmailChangeSendsConfirmationAndNotice(t *testing.T) {
+	ctx := context.Background()
+	userService, outboxService, mailer := newTestUserService(t)
+	user := createEmailChangeTestUser(t, userService, true)
+
+	if err := userService.RequestEmailChange(ctx, user.ID, requests.ChangeEmailRequest{CurrentPassword: "correct horse", NewEmail: "Ana@Work.com"}); err != nil {
+		t.Fatalf("RequestEmailChange() error = %v", err)
+	}
+	deliverAll(t, outboxService)
+
+	sent := map[string]string{}
+	for _, message := range mailer.Messages() {
+		sent[strings.Join(message.To, ",")] = message.HTML
+	}
+	if len(sent) != 2 {
+		t.Fatalf("sent emails to %v, want the new and the old address", sent)
+	}
+	if !strings.Contains(sent["ana@work.com"], "https://monexa.test/change-email/confirm?token=") {
+		t.Errorf("email to the new address has no confirmation link:\n%s", sent["ana@work.com"])
+	}
+	if !strings.Contains(sent[user.Email], "https://monexa.test/change-email/cancel?token=") {
+		t.Errorf("email to the old address has no cancel link:\n%s", sent[user.Email])
+	}
+}
+
+func deliverAll(t *testing.T, outboxService *OutboxService) {
+	t.Helper()
+
+	for {
+		delivered, err := outboxService.DeliverNext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !delivered {
+			return
+		}
+	}
+}
+
+// sentToken returns the token of the last link to path emailed to the address.
+func sentToken(t *testing.T, mailer *clients.MemoryMailer, to, path string) string {
+	t.Helper()
+
+	link := regexp.MustCompile(regexp.QuoteMeta(path) + `\?token=([\w-]+)`)
+	messages := mailer.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if strings.Join(messages[i].To, ",") != to {
+			continue
+		}
+		if match := link.FindStringSubmatch(messages[i].HTML); match != nil {
+			return match[1]
+		}
+	}
+	t.Fatalf("no %s link was sent to %s", path, to)
+	return ""
+}
+
+// changeEmail requests and confirms a change of the email of the user and
+// returns the cancel token sent to the old address.
+func changeEmail(t *testing.T, userService *UserService, outboxService *OutboxService, mailer *clients.MemoryMailer, user models.User, newEmail string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := userService.RequestEmailChange(ctx, user.ID, requests.ChangeEmailRequest{CurrentPassword: "correct horse", NewEmail: newEmail}); err != nil {
+		t.Fatalf("RequestEmailChange() error = %v", err)
+	}
+	deliverAll(t, outboxService)
+
+	if _, err := userService.ConfirmEmailChange(ctx, sentToken(t, mailer, newEmail, "/change-email/confirm")); err != nil {
+		t.Fatalf("ConfirmEmailChange() error = %v", err)
+	}
+	return sentToken(t, mailer, user.Email, "/change-email/cancel")
+}
+
+func TestEmailChangeCannotBeChainedPastTheCancelLink(t *testing.T) {
+	ctx := context.Background()
+	userService, outboxService, mailer := newTestUserService(t)
+	user := createEmailChangeTestUser(t, userService, true)
+
+	changeEmail(t, userService, outboxService, mailer, user, "ana@work.com")
+
+	// A second change would leave the cancel link of the first one nothing
+	// to revert.
+	err := userService.RequestEmailChange(ctx, user.ID, requests.ChangeEmailRequest{CurrentPassword: "correct horse", NewEmail: "ana@elsewhere.com"})
+	if !errors.Is(err, ErrEmailChangeCancellable) {
+		t.Fatalf("RequestEmailChange() while the last change can be cancelled = %v, want %v", err, ErrEmailChangeCancellable)
+	}
+
+	// Once the cancel link expired, the email can be changed again.
+	if err := userService.db.Model(&models.EmailChangeRequest{}).
+		Where("user_id = ?", user.ID).
+		Update("cancel_expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := userService.RequestEmailChange(ctx, user.ID, requests.ChangeEmailRequest{CurrentPassword: "correct horse", NewEmail: "ana@elsewhere.com"}); err != nil {
+		t.Errorf("RequestEmailChange() after the cancel link expired error = %v", err)
+	}
+}
+
+func TestCancelEmailChangeLocksOutWhoeverChangedIt(t *testing.T) {
+	ctx := context.Background()
+	userService, outboxService, mailer := newTestUserService(t)
+	user := createEmailChangeTestUser(t, userService, true)
+
+	ownPasskey := models.PasskeyCredential{UserID: user.ID, CredentialID: []byte("own"), Name: "Own", CreatedAt: time.Now().Add(-time.Hour)}
+	if err := userService.db.Create(&ownPasskey).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cancelToken := changeEmail(t, userService, outboxService, mailer, user, "attacker@example.com")
+
+	// Whoever changed the email adds ways back in.
+	addedPasskey := models.PasskeyCredential{UserID: user.ID, CredentialID: []byte("added"), Name: "Added"}
+	addedIdentity := models.OIDCIdentity{UserID: user.ID, Provider: "google", Subject: "attacker"}
+	addedToken := models.PersonalAccessToken{UserID: user.ID, Name: "Added", Prefix: "mnx_pat_added", TokenHash: "added", ExpiresAt: time.Now().Add(time.Hour)}
+	for _, credential := range []any{&addedPasskey, &addedIdentity, &addedToken} {
+		if err := userService.db.Create(credential).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reverted, wasReverted, err := userService.CancelEmailChange(ctx, cancelToken)
+	if err != nil {
+		t.Fatalf("CancelEmailChange() error = %v", err)
+	}
+	if !wasReverted || reverted.Email != user.Email {
+		t.Fatalf("CancelEmailChange() = reverted %v to %s, want reverted to %s", wasReverted, reverted.Email, user.Email)
+	}
+
+	var stored models.User
+	if err := userService.db.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if utils.CheckPassword("correct horse", stored.Password) == nil {
+		t.Error("the password still works after the change was reverted")
+	}
+
+	remaining := map[string]int64{}
+	for name, model := range map[string]any{"passkeys": &models.PasskeyCredential{}, "identities": &models.OIDCIdentity{}, "tokens": &models.PersonalAccessToken{}} {
+		var count int64
+		if err := userService.db.Model(model).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		remaining[name] = count
+	}
+	if remaining["passkeys"] != 1 || remaining["identities"] != 0 || remaining["tokens"] != 0 {
+		t.Errorf("credentials left after the revert = %v, want only the passkey added before the change", remaining)
+	}
+
+	// The owner of the old address sets a new password through the link sent
+	// there.
+	deliverAll(t, outboxService)
+	resetToken := sentToken(t, mailer, user.Email, "/reset-password")
+	if _, err := userService.ResetPassword(ctx, resetToken, "new correct horse"); err != nil {
+		t.Errorf("ResetPassword() with the link sent after the revert error = %v", err)
+	}
+}
@@ -13,12 +13,18 @@ type TokenType string
 const (
 	TokenTypeAccess  TokenType = "access"
 	TokenTypeRefresh TokenType = "refresh"
+	TokenTypeMFA     TokenType = "mfa"
+	// TokenTypePersonalAccess marks claims built from a personal access token.
+	// They are never signed, AuthMiddleware creates them after a lookup.
+	TokenTypePersonalAccess TokenType = "pat"
 )
 
 type UserClaims struct {
 	UserID          uint       `json:"userId"`
 	TokenType       TokenType  `json:"tokenType"`
 	LegalAcceptedAt *time.Time `json:"legalAcceptedAt,omitempty"`
+	Scopes          []string   `json:"scopes,omitempty"`
+	TokenFamily     string     `json:"tokenFamily,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -11,17 +11,32 @@ import (
 const (
 	DefaultAccessTokenDuration  = 7 * 24 * time.Hour  // 7 days
 	DefaultRefreshTokenDuration = 30 * 24 * time.Hour // 30 days
+	MFATokenDuration            = 5 * time.Minute
 )
 
+// JWTMaker signs tokens with the current key of its KeyStore and verifies
+// them with the key named by their kid header.
 type JWTMaker struct {
-	secretKey            string
+	keys                 KeyStore
+	legacySecret         []byte
 	accessTokenDuration  time.Duration
 	refreshTokenDuration time.Duration
 }
 
-func NewJWTMaker() *JWTMaker {
-	secretKey := os.Getenv("JWT_SECRET")
+func NewJWTMaker(keys KeyStore) *JWTMaker {
+	accessDuration, refreshDuration := TokenDurations()
 
+	return &JWTMaker{
+		keys:                 keys,
+		legacySecret:         []byte(os.Getenv("JWT_SECRET")),
+		accessTokenDuration:  accessDuration,
+		refreshTokenDuration: refreshDuration,
+	}
+}
+
+// TokenDurations returns the access and refresh token lifetimes configured
+// with ACCESS_TOKEN_DURATION and REFRESH_TOKEN_DURATION.
+func TokenDurations() (time.Duration, time.Duration) {
 	accessDuration := DefaultAccessTokenDuration
 	refreshDuration := DefaultRefreshTokenDuration
 
@@ -37,11 +52,7 @@ func NewJWTMaker() *JWTMaker {
 		}
 	}
 
-	return &JWTMaker{
-		secretKey:            secretKey,
-		accessTokenDuration:  accessDuration,
-		refreshTokenDuration: refreshDuration,
-	}
+	return accessDuration, refreshDuration
 }
 
 func (maker *JWTMaker) GetAccessTokenDuration() time.Duration {
@@ -52,16 +63,18 @@ func (maker *JWTMaker) GetRefreshTokenDuration() time.Duration {
 	return maker.refreshTokenDuration
 }
 
-func (maker *JWTMaker) CreateAccessToken(userID uint, ppid string, legalAcceptedAt *time.Time) (string, *UserClaims, error) {
+// CreateAccessToken issues an access token for the session family
+// tokenFamily, which lets the session list mark the current session.
+func (maker *JWTMaker) CreateAccessToken(userID uint, ppid string, tokenFamily string, legalAcceptedAt *time.Time) (string, *UserClaims, error) {
 	claims, err := NewUserClaims(userID, ppid, maker.accessTokenDuration, TokenTypeAccess, legalAcceptedAt)
 	if err != nil {
 		return "", nil, err
 	}
+	claims.TokenFamily = tokenFamily
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(maker.secretKey))
+	tokenString, err := maker.sign(claims)
 	if err != nil {
-		return "", nil, fmt.Errorf("error signing token: %w", err)
+		return "", nil, err
 	}
 
 	return tokenString, claims, nil
@@ -73,24 +86,32 @@ func (maker *JWTMaker) CreateRefreshToken(userID uint, ppid string) (string, *Re
 		return "", nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(maker.secretKey))
+	tokenString, err := maker.sign(claims)
 	if err != nil {
-		return "", nil, fmt.Errorf("error signing token: %w", err)
+		return "", nil, err
 	}
 
 	return tokenString, claims, nil
 }
 
-func (maker *JWTMaker) VerifyToken(tokenString string) (*UserClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
+// CreateMFAToken issues the short-lived challenge token of a login that still
+// needs its second factor. It is not accepted as an access token.
+func (maker *JWTMaker) CreateMFAToken(userID uint, ppid string) (string, *UserClaims, error) {
+	claims, err := NewUserClaims(userID, ppid, MFATokenDuration, TokenTypeMFA, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tokenString, err := maker.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, claims, nil
+}
 
-		return []byte(maker.secretKey), nil
-	})
+func (maker *JWTMaker) VerifyToken(tokenString string) (*UserClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &UserClaims{}, maker.Keyfunc)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing token: %w", err)
 	}
@@ -114,14 +135,19 @@ func (maker *JWTMaker) VerifyAccessToken(tokenString string) (*UserClaims, error
 	return claims, nil
 }
 
+func (maker *JWTMaker) VerifyMFAToken(tokenString string) (*UserClaims, error) {
+	claims, err := maker.VerifyToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeMFA {
+		return nil, fmt.Errorf("invalid token type: expected mfa token")
+	}
+	return claims, nil
+}
+
 func (maker *JWTMaker) VerifyRefreshToken(tokenString string) (*RefreshClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &RefreshClaims{}, func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return []byte(maker.secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &RefreshClaims{}, maker.Keyfunc)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing token: %w", err)
 	}
@@ -135,3 +161,55 @@ func (maker *JWTMaker) VerifyRefreshToken(tokenString string) (*RefreshClaims, e
 	}
 	return claims, nil
 }
+
+func (maker *JWTMaker) sign(claims jwt.Claims) (string, error) {
+	key, err := maker.keys.SigningKey()
+	if err != nil {
+		return "", fmt.Errorf("error loading signing key: %w", err)
+	}
+
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("error signing token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// Keyfunc returns the public key named by the kid header of a token. Tokens
+// signed with JWT_SECRET before the first signing key was created are
+// accepted until the longest token lifetime has passed since then, so
+// switching to signing keys does not sign anyone out.
+func (maker *JWTMaker) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return maker.legacyKey(token)
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, err := maker.keys.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method")
+	}
+
+	return key.PrivateKey.Public(), nil
+}
+
+func (maker *JWTMaker) legacyKey(token *jwt.Token) (interface{}, error) {
+	cutoff := maker.keys.FirstKeyCreatedAt()
+	if len(maker.legacySecret) == 0 || cutoff.IsZero() ||
+		time.Now().After(cutoff.Add(max(maker.accessTokenDuration, maker.refreshTokenDuration))) {
+		return nil, fmt.Errorf("unexpected signing method")
+	}
+
+	issuedAt, err := token.Claims.GetIssuedAt()
+	if err != nil || issuedAt == nil || !issuedAt.Before(cutoff) {
+		return nil, fmt.Errorf("unexpected signing method")
+	}
+
+	return maker.legacySecret, nil
+}
@@ -0,0 +1,158 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmES256 = "ES256"
+)
+
+var ErrSigningKeyNotFound = errors.New("signing key not found")
+
+// SigningKey is an asymmetric key identified by the kid header of the tokens
+// it signs.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	ActivatesAt time.Time
+}
+
+// KeyStore supplies the keys JWTMaker signs and verifies tokens with.
+type KeyStore interface {
+	// SigningKey returns the key new tokens are signed with.
+	SigningKey() (*SigningKey, error)
+	// VerificationKey returns the key with the given kid, including keys that
+	// no longer sign new tokens. It returns ErrSigningKeyNotFound otherwise.
+	VerificationKey(kid string) (*SigningKey, error)
+	// FirstKeyCreatedAt returns when the oldest key was created, or the zero
+	// time without keys. Tokens signed with JWT_SECRET predate it.
+	FirstKeyCreatedAt() time.Time
+}
+
+// JWK is the public part of a signing key as published in the JWKS.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+func IsValidSigningAlgorithm(algorithm string) bool {
+	return algorithm == AlgorithmEdDSA || algorithm == AlgorithmES256
+}
+
+// GenerateSigningKey creates a new key with a random kid.
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var signer crypto.Signer
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("error generating ed25519 key: %w", err)
+		}
+		signer = private
+	case AlgorithmES256:
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("error generating ecdsa key: %w", err)
+		}
+		signer = private
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	kid := make([]byte, 12)
+	if _, err := rand.Read(kid); err != nil {
+		return nil, fmt.Errorf("error generating key id: %w", err)
+	}
+
+	return &SigningKey{
+		ID:          base64.RawURLEncoding.EncodeToString(kid),
+		Algorithm:   algorithm,
+		PrivateKey:  signer,
+		ActivatesAt: time.Now(),
+	}, nil
+}
+
+// MarshalPrivateKey encodes the private key as a PKCS #8 PEM block.
+func (k *SigningKey) MarshalPrivateKey() (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("error encoding private key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseSigningKey is the counterpart of MarshalPrivateKey. The key has to
+// match the algorithm.
+func ParseSigningKey(kid, algorithm, privateKeyPEM string, activatesAt time.Time) (*SigningKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("error decoding private key of %s", kid)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key of %s: %w", kid, err)
+	}
+
+	var signer crypto.Signer
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		if algorithm == AlgorithmEdDSA {
+			signer = key
+		}
+	case *ecdsa.PrivateKey:
+		if algorithm == AlgorithmES256 && key.Curve == elliptic.P256() {
+			signer = key
+		}
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("private key of %s does not match algorithm %s", kid, algorithm)
+	}
+
+	return &SigningKey{ID: kid, Algorithm: algorithm, PrivateKey: signer, ActivatesAt: activatesAt}, nil
+}
+
+func (k *SigningKey) signingMethod() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmES256 {
+		return jwt.SigningMethodES256
+	}
+	return jwt.SigningMethodEdDSA
+}
+
+// PublicJWK returns the public key in JWK form.
+func (k *SigningKey) PublicJWK() JWK {
+	jwk := JWK{Kid: k.ID, Alg: k.Algorithm, Use: "sig"}
+
+	switch public := k.PrivateKey.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		jwk.Crv = "P-256"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, 32)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, 32)))
+	}
+
+	return jwk
+}
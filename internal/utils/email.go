@@ -1,7 +1,17 @@
 package utils
 
-import "strings"
+import (
+	"net/mail"
+	"strings"
+)
 
 func NormalizeEmail(email string) string {
 	return strings.ToLower(strings.TrimSpace(email))
 }
+
+// IsValidEmail reports whether email is a bare address such as
+// name@example.com, without a display name.
+func IsValidEmail(email string) bool {
+	address, err := mail.ParseAddress(email)
+	return err == nil && address.Address == email
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/emilijan-koteski/monexa/internal/models/types"
+)
+
+// FormatNumber formats a value with the thousands and decimal separators used by
+// the given language, e.g. 1,234.56 in English and 1.234,56 in Macedonian.
+func FormatNumber(value float64, decimals int, lang types.LanguageType) string {
+	thousandsSeparator, decimalSeparator := ",", "."
+	if lang == types.MacedonianLanguage {
+		thousandsSeparator, decimalSeparator = ".", ","
+	}
+
+	formatted := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	integerPart, fractionPart, hasFraction := strings.Cut(formatted, ".")
+
+	var b strings.Builder
+	if value < 0 && strings.Trim(formatted, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, d := range integerPart {
+		if i > 0 && (len(integerPart)-i)%3 == 0 {
+			b.WriteString(thousandsSeparator)
+		}
+		b.WriteRune(d)
+	}
+	if hasFraction {
+		b.WriteString(decimalSeparator)
+		b.WriteString(fractionPart)
+	}
+	return b.String()
+}
@@ -3,6 +3,7 @@ import type { User, LoginResponse, RenewTokenResponse } from "./types.js";
 const BASE_URL =
   process.env.MONEXA_API_BASE_URL ?? "https://api.monexa.world/api/v1";
 const REFRESH_THRESHOLD_MS = 24 * 60 * 60 * 1000; // 24 hours
+const PERSONAL_ACCESS_TOKEN = process.env.MONEXA_TOKEN;
 
 interface TokenState {
   accessToken: string;
@@ -14,21 +15,47 @@ interface TokenState {
 
 let tokenState: TokenState | null = null;
 let refreshPromise: Promise<boolean> | null = null;
+let tokenUser: User | null = null;
 
 export function isAuthenticated(): boolean {
-  return tokenState !== null;
+  return tokenState !== null || tokenUser !== null;
 }
 
 export function getCachedUser(): User | null {
-  return tokenState?.user ?? null;
+  return tokenState?.user ?? tokenUser;
 }
 
 export function updateCachedUser(user: User): void {
   if (tokenState) {
     tokenState.user = user;
+  } else if (tokenUser) {
+    tokenUser = user;
   }
 }
 
+export function hasPersonalAccessToken(): boolean {
+  return Boolean(PERSONAL_ACCESS_TOKEN);
+}
+
+// Personal access tokens do not expire during a session and are not renewed,
+// so only the user has to be loaded once.
+export async function loadPersonalAccessTokenUser(): Promise<User> {
+  const response = await fetch(`${BASE_URL}/users/me`, {
+    headers: { Authorization: `Bearer ${PERSONAL_ACCESS_TOKEN}` },
+  });
+
+  if (!response.ok) {
+    const body = await response.json().catch(() => ({}));
+    throw new Error(
+      body.message ?? body.error ?? `Token check failed with status ${response.status}`
+    );
+  }
+
+  const result = await response.json();
+  tokenUser = result.data;
+  return result.data;
+}
+
 export async function login(
   email: string,
   password: string
@@ -120,6 +147,10 @@ async function renewAccessToken(): Promise<boolean> {
 }
 
 export async function getValidAccessToken(): Promise<string> {
+  if (!tokenState && PERSONAL_ACCESS_TOKEN) {
+    return PERSONAL_ACCESS_TOKEN;
+  }
+
   if (!tokenState) {
     throw new Error("Not authenticated. Call the login or register tool first.");
   }
@@ -2,15 +2,29 @@
 
 import { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
 import { StdioServerTransport } from "@modelcontextprotocol/sdk/server/stdio.js";
-import { login } from "./auth.js";
+import {
+  hasPersonalAccessToken,
+  loadPersonalAccessTokenUser,
+  login,
+} from "./auth.js";
 import { registerAllTools } from "./tools/index.js";
 
 async function main() {
   const email = process.env.MONEXA_EMAIL;
   const password = process.env.MONEXA_PASSWORD;
 
-  // Auto-login if credentials are provided
-  if (email && password) {
+  // A personal access token takes precedence over email and password
+  if (hasPersonalAccessToken()) {
+    try {
+      const user = await loadPersonalAccessTokenUser();
+      console.error(`Authenticated as ${user.email} with a personal access token`);
+    } catch (err) {
+      console.error(
+        "Personal access token rejected:",
+        err instanceof Error ? err.message : err
+      );
+    }
+  } else if (email && password) {
     try {
       await login(email, password);
       console.error(`Authenticated as ${email}`);